@@ -0,0 +1,157 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultModel is the model used when none is configured.
+const DefaultModel = "claude-sonnet-4-0"
+
+const (
+	defaultAPIURL    = "https://api.anthropic.com/v1/messages"
+	defaultMaxTokens = 1024
+	anthropicVersion = "2023-06-01"
+	contentTypeText  = "text"
+)
+
+// Client holds the configuration for the Anthropic Messages API client.
+type Client struct {
+	APIKey string
+	APIURL string
+	Model  string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// messagesRequest represents the data sent to the Messages API.
+type messagesRequest struct {
+	Model       string              `json:"model"`
+	System      string              `json:"system,omitempty"`
+	Messages    []types.ChatMessage `json:"messages"`
+	MaxTokens   int                 `json:"max_tokens"`
+	Temperature float64             `json:"temperature"`
+}
+
+// messagesResponse represents the structure of data received from the Messages API.
+type messagesResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewClient creates a new Anthropic client with the given API key.
+func NewClient(apiKey string, apiURL ...string) *Client {
+	url := defaultAPIURL
+	if len(apiURL) > 0 {
+		url = apiURL[0]
+	}
+	return &Client{
+		APIKey: apiKey,
+		APIURL: url,
+		Model:  DefaultModel,
+	}
+}
+
+// Complete sends the conversation to the Messages API and returns the concatenated text blocks.
+// System messages are moved into the top-level system prompt as the API requires.
+// It implements the llm.Client interface.
+func (c *Client) Complete(ctx context.Context, request types.CompletionRequest) (*types.CompletionResponse, error) {
+	payload := messagesRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+	}
+	if payload.Model == "" {
+		payload.Model = c.Model
+	}
+	if payload.MaxTokens == 0 {
+		payload.MaxTokens = defaultMaxTokens
+	}
+
+	var system []string
+	for _, message := range request.Messages {
+		if message.Role == types.RoleSystem {
+			system = append(system, message.Content)
+			continue
+		}
+		payload.Messages = append(payload.Messages, message)
+	}
+	// The Messages API has no structured output mode, so the schema becomes part of the instructions
+	if request.JSONSchema != nil {
+		system = append(system, "Respond only with a JSON document, without any other text, matching this JSON schema:\n"+
+			string(request.JSONSchema.Schema))
+	}
+	payload.System = strings.Join(system, "\n\n")
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.APIURL, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var response messagesResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, types.NewAPIError(resp.StatusCode, "", strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("failed to unmarshal response: %v, body: %s", err, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		if response.Error != nil {
+			return nil, types.NewAPIError(resp.StatusCode, response.Error.Type, response.Error.Message)
+		}
+		return nil, types.NewAPIError(resp.StatusCode, "", strings.TrimSpace(string(body)))
+	}
+
+	var text []string
+	for _, block := range response.Content {
+		if block.Type == contentTypeText {
+			text = append(text, block.Text)
+		}
+	}
+	usage := types.Usage{
+		PromptTokens:     response.Usage.InputTokens,
+		CompletionTokens: response.Usage.OutputTokens,
+		TotalTokens:      response.Usage.InputTokens + response.Usage.OutputTokens,
+	}
+	return &types.CompletionResponse{Content: strings.Join(text, ""), Model: response.Model, Usage: usage}, nil
+}
@@ -0,0 +1,74 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCompleteSuccess tests that system messages are lifted into the system prompt and text blocks are returned
+func TestCompleteSuccess(t *testing.T) {
+	var received messagesRequest
+
+	// Mock the server and capture the request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "fake-api-key" {
+			t.Errorf("Expected x-api-key header, got '%s'", r.Header.Get("x-api-key"))
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Error("Expected anthropic-version header to be set")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"model":"claude-test","content":[{"type":"text","text":"Consider "},{"type":"text","text":"renaming x."}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("fake-api-key", server.URL)
+
+	response, err := client.Complete(context.Background(), types.CompletionRequest{
+		Messages: []types.ChatMessage{
+			{Role: types.RoleSystem, Content: "You are a code reviewer."},
+			{Role: types.RoleUser, Content: "Review this."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Content != "Consider renaming x." {
+		t.Errorf("Expected concatenated text, got '%s'", response.Content)
+	}
+	if received.System != "You are a code reviewer." {
+		t.Errorf("Expected system prompt, got '%s'", received.System)
+	}
+	if len(received.Messages) != 1 || received.Messages[0].Role != types.RoleUser {
+		t.Errorf("Expected a single user message, got %v", received.Messages)
+	}
+	if received.MaxTokens != defaultMaxTokens || received.Model != DefaultModel {
+		t.Errorf("Expected default model and max tokens, got %s and %d", received.Model, received.MaxTokens)
+	}
+}
+
+// TestCompleteError tests the case where the API returns an error object
+func TestCompleteError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("fake-api-key", server.URL)
+
+	_, err := client.Complete(context.Background(), types.CompletionRequest{
+		Messages: []types.ChatMessage{{Role: types.RoleUser, Content: "Review this."}},
+	})
+	if err == nil {
+		t.Fatal("Expected an error, but got none")
+	}
+}
@@ -0,0 +1,111 @@
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// credentials authenticate the requests with an access token, or with basic authentication using the
+// token as password when a username is set.
+type credentials struct {
+	username string
+	token    string
+}
+
+// send sends a request, encoding the body and decoding the response into result when they are not nil.
+// A *string result receives the raw response body. The response is returned along with API errors so
+// callers can inspect the status.
+func send(ctx context.Context, httpClient *http.Client, creds credentials, method, url string, body, result interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if creds.username != "" {
+		req.SetBasicAuth(creds.username, creds.token)
+	} else if creds.token != "" {
+		req.Header.Set("Authorization", "Bearer "+creds.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if _, raw := result.(*string); !raw {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, newAPIError(resp.StatusCode, data)
+	}
+	switch result := result.(type) {
+	case nil:
+	case *string:
+		*result = string(data)
+	default:
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// newAPIError converts an error response into a typed error carrying the API's message. Bitbucket Cloud
+// answers with an "error" object, Data Center with a list of "errors".
+func newAPIError(statusCode int, body []byte) error {
+	var response struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return types.NewAPIError(statusCode, "", strings.TrimSpace(string(body)))
+	}
+
+	message := response.Error.Message
+	for _, e := range response.Errors {
+		if message != "" {
+			message += "; "
+		}
+		message += e.Message
+	}
+	return types.NewAPIError(statusCode, "", message)
+}
+
+// escapePath escapes the segments of a file path for use in a URL, keeping its slashes.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,99 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCloudDiffStat tests that every page of the diffstat is fetched with basic authentication
+func TestCloudDiffStat(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "reviewer" || password != "app-password" {
+			t.Errorf("Expected basic authentication, got %v", r.Header)
+		}
+		if r.URL.Path != "/repositories/team/service/pullrequests/4/diffstat" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") == "" {
+			fmt.Fprintf(w, `{"values":[{"status":"modified","lines_added":2,"lines_removed":1,"old":{"path":"a.go"},"new":{"path":"a.go"}}],"next":"%s/repositories/team/service/pullrequests/4/diffstat?page=2"}`, server.URL)
+			return
+		}
+		fmt.Fprint(w, `{"values":[{"status":"removed","lines_added":0,"lines_removed":5,"old":{"path":"b.go"},"new":null}]}`)
+	}))
+	defer server.Close()
+
+	client := NewCloudClient("reviewer", "app-password")
+	client.BaseURL = server.URL
+	stats, err := client.DiffStat(context.Background(), "team", "service", 4)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(stats) != 2 || stats[0].Path() != "a.go" || stats[1].Path() != "b.go" || stats[1].LinesRemoved != 5 {
+		t.Errorf("Expected a.go and the removed b.go, got %+v", stats)
+	}
+}
+
+// TestServerDiff tests that the structured diff of Data Center is converted into unified patches
+func TestServerDiff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bitbucket/rest/api/1.0/projects/PROJ/repos/service/pull-requests/9/diff" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer http-token" {
+			t.Errorf("Expected the access token, got %v", r.Header)
+		}
+		fmt.Fprint(w, `{"diffs":[{"source":{"toString":"a.go"},"destination":{"toString":"a.go"},"hunks":[{
+			"sourceLine":3,"sourceSpan":2,"destinationLine":3,"destinationSpan":2,"segments":[
+			{"type":"CONTEXT","lines":[{"line":"func a() {"}]},
+			{"type":"REMOVED","lines":[{"line":"\treturn"}]},
+			{"type":"ADDED","lines":[{"line":"\treturn nil"}]}]}]}],"truncated":true}`)
+	}))
+	defer server.Close()
+
+	files, truncated, err := NewServerClient(server.URL+"/bitbucket/", "", "http-token").Diff(context.Background(), "PROJ", "service", 9)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := types.FileDiff{Path: "a.go", Patch: "@@ -3,2 +3,2 @@\n func a() {\n-\treturn\n+\treturn nil", Additions: 1, Deletions: 1}
+	if len(files) != 1 || files[0] != expected || !truncated {
+		t.Errorf("Expected %+v of a truncated diff, got %+v (truncated %v)", expected, files, truncated)
+	}
+}
+
+// TestServerSetStatus tests that the reviewer status is set for the user the access token belongs to
+func TestServerSetStatus(t *testing.T) {
+	var status map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plugins/servlet/applinks/whoami":
+			fmt.Fprint(w, "Review-Bot\n")
+		case "/rest/api/1.0/projects/PROJ/repos/service/pull-requests/9/participants/review-bot":
+			json.NewDecoder(r.Body).Decode(&status)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"message":"Not found"}]}`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewServerClient(server.URL, "", "http-token")
+	if err := client.SetStatus(context.Background(), "PROJ", "service", 9, StatusNeedsWork); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status["status"] != StatusNeedsWork || status["approved"] != false {
+		t.Errorf("Expected the needs work status, got %v", status)
+	}
+
+	err := client.CreateComment(context.Background(), "PROJ", "missing", 9, "Hello", nil)
+	var apiErr *types.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Not found" {
+		t.Errorf("Expected the API's error message, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultCloudURL is the API of Bitbucket Cloud.
+const DefaultCloudURL = "https://api.bitbucket.org/2.0"
+
+// CloudClient talks to the REST API of Bitbucket Cloud.
+type CloudClient struct {
+	BaseURL string
+	// Username selects basic authentication with Token as app password; without it Token is sent as
+	// a repository, project or workspace access token.
+	Username string
+	Token    string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// DiffStat describes a changed file of a pull request.
+type DiffStat struct {
+	Status       string `json:"status"`
+	LinesAdded   int    `json:"lines_added"`
+	LinesRemoved int    `json:"lines_removed"`
+	Old          *File  `json:"old"`
+	New          *File  `json:"new"`
+}
+
+// File names a file of a diffstat entry.
+type File struct {
+	Path string `json:"path"`
+}
+
+// Inline anchors a comment to a line of the new version of a file.
+type Inline struct {
+	Path string `json:"path"`
+	To   int    `json:"to,omitempty"`
+	From int    `json:"from,omitempty"`
+}
+
+// NewCloudClient creates a client for Bitbucket Cloud.
+func NewCloudClient(username, token string) *CloudClient {
+	return &CloudClient{BaseURL: DefaultCloudURL, Username: username, Token: token}
+}
+
+// Path returns the path of the file in the new version, or in the old one for deleted files.
+func (d DiffStat) Path() string {
+	if d.New != nil {
+		return d.New.Path
+	}
+	if d.Old != nil {
+		return d.Old.Path
+	}
+	return ""
+}
+
+// DiffStat fetches the changed files of the pull request, following every page of the listing.
+func (c *CloudClient) DiffStat(ctx context.Context, workspace, repo string, id int) ([]DiffStat, error) {
+	var all []DiffStat
+	next := c.pullRequestURL(workspace, repo, id) + "/diffstat?pagelen=100"
+	for next != "" {
+		var page struct {
+			Values []DiffStat `json:"values"`
+			Next   string     `json:"next"`
+		}
+		if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, next, nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to retrieve pull request diffstat: %w", err)
+		}
+		all = append(all, page.Values...)
+		next = page.Next
+	}
+	return all, nil
+}
+
+// Diff fetches the unified diff of the pull request.
+func (c *CloudClient) Diff(ctx context.Context, workspace, repo string, id int) (string, error) {
+	var diff string
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, c.pullRequestURL(workspace, repo, id)+"/diff", nil, &diff); err != nil {
+		return "", fmt.Errorf("failed to retrieve pull request diff: %w", err)
+	}
+	return diff, nil
+}
+
+// CompareDiff fetches the unified diff of the changes from base to head, computed from their merge base.
+func (c *CloudClient) CompareDiff(ctx context.Context, workspace, repo, base, head string) (string, error) {
+	var diff string
+	u := fmt.Sprintf("%s/diff/%s..%s", c.repositoryURL(workspace, repo), url.PathEscape(head), url.PathEscape(base))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, u, nil, &diff); err != nil {
+		return "", fmt.Errorf("failed to compare %s...%s: %w", base, head, err)
+	}
+	return diff, nil
+}
+
+// MergeBase returns the hash of the best common ancestor of the commits.
+func (c *CloudClient) MergeBase(ctx context.Context, workspace, repo, a, b string) (string, error) {
+	var commit struct {
+		Hash string `json:"hash"`
+	}
+	u := fmt.Sprintf("%s/merge-base/%s..%s", c.repositoryURL(workspace, repo), url.PathEscape(a), url.PathEscape(b))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, u, nil, &commit); err != nil {
+		return "", fmt.Errorf("failed to retrieve merge base: %w", err)
+	}
+	return commit.Hash, nil
+}
+
+// HeadCommit returns the hash of the head commit of the pull request.
+func (c *CloudClient) HeadCommit(ctx context.Context, workspace, repo string, id int) (string, error) {
+	var pr struct {
+		Source struct {
+			Commit struct {
+				Hash string `json:"hash"`
+			} `json:"commit"`
+		} `json:"source"`
+	}
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, c.pullRequestURL(workspace, repo, id), nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to retrieve pull request: %w", err)
+	}
+	return pr.Source.Commit.Hash, nil
+}
+
+// FileContent returns the content of the file at path in the commit.
+func (c *CloudClient) FileContent(ctx context.Context, workspace, repo, commit, path string) (string, error) {
+	var content string
+	u := fmt.Sprintf("%s/src/%s/%s", c.repositoryURL(workspace, repo), url.PathEscape(commit), escapePath(path))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, u, nil, &content); err != nil {
+		return "", fmt.Errorf("failed to retrieve %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// CreateComment comments on the pull request, on the line of the inline anchor or on the pull request
+// itself when it is nil.
+func (c *CloudClient) CreateComment(ctx context.Context, workspace, repo string, id int, body string, inline *Inline) error {
+	type content struct {
+		Raw string `json:"raw"`
+	}
+	request := struct {
+		Content content `json:"content"`
+		Inline  *Inline `json:"inline,omitempty"`
+	}{content{body}, inline}
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodPost, c.pullRequestURL(workspace, repo, id)+"/comments", request, nil); err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+// Approve approves the pull request.
+func (c *CloudClient) Approve(ctx context.Context, workspace, repo string, id int) error {
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodPost, c.pullRequestURL(workspace, repo, id)+"/approve", nil, nil); err != nil {
+		return fmt.Errorf("failed to approve pull request: %w", err)
+	}
+	return nil
+}
+
+// RequestChanges requests changes on the pull request.
+func (c *CloudClient) RequestChanges(ctx context.Context, workspace, repo string, id int) error {
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodPost, c.pullRequestURL(workspace, repo, id)+"/request-changes", nil, nil); err != nil {
+		return fmt.Errorf("failed to request changes: %w", err)
+	}
+	return nil
+}
+
+// repositoryURL returns the API URL of the repository.
+func (c *CloudClient) repositoryURL(workspace, repo string) string {
+	return fmt.Sprintf("%s/repositories/%s/%s", c.BaseURL, url.PathEscape(workspace), url.PathEscape(repo))
+}
+
+// pullRequestURL returns the API URL of the pull request.
+func (c *CloudClient) pullRequestURL(workspace, repo string, id int) string {
+	return fmt.Sprintf("%s/pullrequests/%d", c.repositoryURL(workspace, repo), id)
+}
+
+// credentials returns the credentials of the client's requests.
+func (c *CloudClient) credentials() credentials {
+	return credentials{username: c.Username, token: c.Token}
+}
@@ -0,0 +1,236 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Participant statuses of a reviewer on Bitbucket Data Center.
+const (
+	StatusApproved  = "APPROVED"
+	StatusNeedsWork = "NEEDS_WORK"
+)
+
+// ServerClient talks to the REST API of Bitbucket Data Center (formerly Bitbucket Server).
+type ServerClient struct {
+	// BaseURL is the URL of the instance including its context path, e.g. https://bitbucket.example.com.
+	BaseURL string
+	// Username selects basic authentication with Token as password; without it Token is sent as an
+	// HTTP access token.
+	Username string
+	Token    string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// Anchor attaches a comment to a line of a pull request diff.
+type Anchor struct {
+	Path     string `json:"path"`
+	SrcPath  string `json:"srcPath,omitempty"`
+	Line     int    `json:"line"`
+	LineType string `json:"lineType"`
+	FileType string `json:"fileType"`
+	DiffType string `json:"diffType"`
+}
+
+// serverDiff is the structured diff of a pull request returned by Data Center.
+type serverDiff struct {
+	Diffs []struct {
+		Source      *serverPath  `json:"source"`
+		Destination *serverPath  `json:"destination"`
+		Hunks       []serverHunk `json:"hunks"`
+		Truncated   bool         `json:"truncated"`
+	} `json:"diffs"`
+	Truncated bool `json:"truncated"`
+}
+
+// serverPath is a file path of a structured diff.
+type serverPath struct {
+	ToString string `json:"toString"`
+}
+
+// serverHunk is a hunk of a structured diff, split into segments of added, removed and context lines.
+type serverHunk struct {
+	SourceLine      int `json:"sourceLine"`
+	SourceSpan      int `json:"sourceSpan"`
+	DestinationLine int `json:"destinationLine"`
+	DestinationSpan int `json:"destinationSpan"`
+	Segments        []struct {
+		Type  string `json:"type"`
+		Lines []struct {
+			Line string `json:"line"`
+		} `json:"lines"`
+	} `json:"segments"`
+}
+
+// segmentPrefixes are the unified diff prefixes of the segment types.
+var segmentPrefixes = map[string]string{"ADDED": "+", "REMOVED": "-", "CONTEXT": " "}
+
+// NewServerClient creates a client for the Data Center instance at baseURL.
+func NewServerClient(baseURL, username, token string) *ServerClient {
+	return &ServerClient{BaseURL: strings.TrimSuffix(baseURL, "/"), Username: username, Token: token}
+}
+
+// Diff fetches the diff of the pull request and converts it into code host independent diffs. The
+// returned flag reports whether Data Center truncated the diff.
+func (c *ServerClient) Diff(ctx context.Context, project, repo string, id int) ([]types.FileDiff, bool, error) {
+	var diff serverDiff
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, c.pullRequestURL(project, repo, id)+"/diff?contextLines=3", nil, &diff); err != nil {
+		return nil, false, fmt.Errorf("failed to retrieve pull request diff: %w", err)
+	}
+	files, truncated := diff.fileDiffs()
+	return files, truncated, nil
+}
+
+// CompareDiff fetches the changes of the commit from relative to the commit to, computed from their
+// merge base like the diff of a pull request from from into to. The returned flag reports whether Data
+// Center truncated the diff.
+func (c *ServerClient) CompareDiff(ctx context.Context, project, repo, from, to string) ([]types.FileDiff, bool, error) {
+	var diff serverDiff
+	u := fmt.Sprintf("%s/compare/diff?from=%s&to=%s&contextLines=3", c.repositoryURL(project, repo), url.QueryEscape(from), url.QueryEscape(to))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, u, nil, &diff); err != nil {
+		return nil, false, fmt.Errorf("failed to compare %s...%s: %w", to, from, err)
+	}
+	files, truncated := diff.fileDiffs()
+	return files, truncated, nil
+}
+
+// Ancestor reports whether the commit is an ancestor of head, that is head contains every commit
+// reachable from it.
+func (c *ServerClient) Ancestor(ctx context.Context, project, repo, commit, head string) (bool, error) {
+	var page struct {
+		Values []json.RawMessage `json:"values"`
+	}
+	u := fmt.Sprintf("%s/compare/commits?from=%s&to=%s&limit=1", c.repositoryURL(project, repo), url.QueryEscape(commit), url.QueryEscape(head))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, u, nil, &page); err != nil {
+		return false, fmt.Errorf("failed to compare %s...%s: %w", head, commit, err)
+	}
+	return len(page.Values) == 0, nil
+}
+
+// fileDiffs converts the structured diff into code host independent diffs and reports whether Data
+// Center truncated it.
+func (diff serverDiff) fileDiffs() ([]types.FileDiff, bool) {
+	truncated := diff.Truncated
+	files := make([]types.FileDiff, 0, len(diff.Diffs))
+	for _, d := range diff.Diffs {
+		truncated = truncated || d.Truncated
+		file := types.FileDiff{}
+		if d.Destination != nil {
+			file.Path = d.Destination.ToString
+		} else if d.Source != nil {
+			file.Path = d.Source.ToString
+		}
+
+		var patch []string
+		for _, hunk := range d.Hunks {
+			patch = append(patch, fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.SourceLine, hunk.SourceSpan, hunk.DestinationLine, hunk.DestinationSpan))
+			for _, segment := range hunk.Segments {
+				prefix := segmentPrefixes[segment.Type]
+				for _, line := range segment.Lines {
+					patch = append(patch, prefix+line.Line)
+					switch segment.Type {
+					case "ADDED":
+						file.Additions++
+					case "REMOVED":
+						file.Deletions++
+					}
+				}
+			}
+		}
+		file.Patch = strings.Join(patch, "\n")
+		files = append(files, file)
+	}
+	return files, truncated
+}
+
+// HeadCommit returns the ID of the latest commit of the pull request's source branch.
+func (c *ServerClient) HeadCommit(ctx context.Context, project, repo string, id int) (string, error) {
+	var pr struct {
+		FromRef struct {
+			LatestCommit string `json:"latestCommit"`
+		} `json:"fromRef"`
+	}
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, c.pullRequestURL(project, repo, id), nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to retrieve pull request: %w", err)
+	}
+	return pr.FromRef.LatestCommit, nil
+}
+
+// FileContent returns the content of the file at path in the commit.
+func (c *ServerClient) FileContent(ctx context.Context, project, repo, commit, path string) (string, error) {
+	var content string
+	u := fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s/raw/%s?at=%s", c.BaseURL, url.PathEscape(project), url.PathEscape(repo), escapePath(path), url.QueryEscape(commit))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, u, nil, &content); err != nil {
+		return "", fmt.Errorf("failed to retrieve %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// CreateComment comments on the pull request, on the line of the anchor or on the pull request itself
+// when it is nil.
+func (c *ServerClient) CreateComment(ctx context.Context, project, repo string, id int, text string, anchor *Anchor) error {
+	request := struct {
+		Text   string  `json:"text"`
+		Anchor *Anchor `json:"anchor,omitempty"`
+	}{text, anchor}
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodPost, c.pullRequestURL(project, repo, id)+"/comments", request, nil); err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+// SetStatus sets the reviewer status of the authenticated user on the pull request, see the Status
+// constants.
+func (c *ServerClient) SetStatus(ctx context.Context, project, repo string, id int, status string) error {
+	user, err := c.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+	type participant struct {
+		Name string `json:"name"`
+	}
+	request := struct {
+		User     participant `json:"user"`
+		Approved bool        `json:"approved"`
+		Status   string      `json:"status"`
+	}{participant{user}, status == StatusApproved, status}
+	u := fmt.Sprintf("%s/participants/%s", c.pullRequestURL(project, repo, id), url.PathEscape(user))
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodPut, u, request, nil); err != nil {
+		return fmt.Errorf("failed to set reviewer status: %w", err)
+	}
+	return nil
+}
+
+// currentUser returns the slug of the authenticated user, which is the configured username or, for
+// access tokens, asked from the instance.
+func (c *ServerClient) currentUser(ctx context.Context) (string, error) {
+	if c.Username != "" {
+		return strings.ToLower(c.Username), nil
+	}
+	var user string
+	if _, err := send(ctx, c.HTTPClient, c.credentials(), http.MethodGet, c.BaseURL+"/plugins/servlet/applinks/whoami", nil, &user); err != nil {
+		return "", fmt.Errorf("failed to retrieve the authenticated user: %w", err)
+	}
+	return strings.ToLower(strings.TrimSpace(user)), nil
+}
+
+// repositoryURL returns the API URL of the repository.
+func (c *ServerClient) repositoryURL(project, repo string) string {
+	return fmt.Sprintf("%s/rest/api/1.0/projects/%s/repos/%s", c.BaseURL, url.PathEscape(project), url.PathEscape(repo))
+}
+
+// pullRequestURL returns the API URL of the pull request.
+func (c *ServerClient) pullRequestURL(project, repo string, id int) string {
+	return fmt.Sprintf("%s/pull-requests/%d", c.repositoryURL(project, repo), id)
+}
+
+// credentials returns the credentials of the client's requests.
+func (c *ServerClient) credentials() credentials {
+	return credentials{username: c.Username, token: c.Token}
+}
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// suffix is the file name extension of cache entries; other files in the directory are left alone.
+const suffix = ".json"
+
+// Cache is a content-addressed store of model answers on disk. Every entry is a file named after its
+// key, so that concurrent runs can share the directory: entries are written atomically and a missing or
+// damaged entry only costs another request.
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+
+	hits, misses atomic.Int64
+}
+
+// Stats describes the entries of a cache directory.
+type Stats struct {
+	Dir     string
+	Entries int
+	Size    int64
+	Expired int
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// Open returns the cache in dir, creating the directory. Entries older than ttl are not returned and
+// the oldest entries are evicted by Prune while the cache is larger than maxSize bytes; zero values
+// disable the limits.
+func Open(dir string, ttl time.Duration, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, ttl: ttl, maxSize: maxSize}, nil
+}
+
+// Key returns the key of the parts, a hash that changes with any of them.
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		// Prefix each part with its length so that moving text between parts changes the key
+		fmt.Fprintf(hash, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Get returns the entry of the key, if it exists and has not expired.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || c.expired(info, time.Now()) {
+		c.misses.Add(1)
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+// Put stores the entry of the key.
+func (c *Cache) Put(key string, data []byte) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so that readers never see a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+key+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Hits returns the number of Get calls answered from the cache.
+func (c *Cache) Hits() int {
+	return int(c.hits.Load())
+}
+
+// Misses returns the number of Get calls that found no entry.
+func (c *Cache) Misses() int {
+	return int(c.misses.Load())
+}
+
+// Prune removes the expired entries and then the oldest entries until the cache fits into its size.
+func (c *Cache) Prune() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var size int64
+	var kept []entry
+	for _, e := range entries {
+		if c.expired(e.info, now) {
+			os.Remove(e.path)
+			continue
+		}
+		size += e.info.Size()
+		kept = append(kept, e)
+	}
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].info.ModTime().Before(kept[j].info.ModTime()) })
+	for _, e := range kept {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to evict cache entry: %w", err)
+		}
+		size -= e.info.Size()
+	}
+	return nil
+}
+
+// Stats returns the statistics of the entries on disk.
+func (c *Cache) Stats() (Stats, error) {
+	stats := Stats{Dir: c.dir}
+	entries, err := c.entries()
+	if err != nil {
+		return stats, err
+	}
+	now := time.Now()
+	for _, e := range entries {
+		stats.Entries++
+		stats.Size += e.info.Size()
+		if c.expired(e.info, now) {
+			stats.Expired++
+		}
+		if modified := e.info.ModTime(); stats.Oldest.IsZero() || modified.Before(stats.Oldest) {
+			stats.Oldest = modified
+		}
+		if modified := e.info.ModTime(); modified.After(stats.Newest) {
+			stats.Newest = modified
+		}
+	}
+	return stats, nil
+}
+
+// Clear removes every entry and returns how many were removed.
+func (c *Cache) Clear() (int, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if err := os.Remove(e.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// entry is a cache file found on disk.
+type entry struct {
+	path string
+	info fs.FileInfo
+}
+
+// entries lists the entries on disk.
+func (c *Cache) entries() ([]entry, error) {
+	var entries []entry
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), suffix) || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Removed by a concurrent run
+			return nil
+		}
+		entries = append(entries, entry{path: path, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cache entries: %w", err)
+	}
+	return entries, nil
+}
+
+// path returns the file of the key, spread over subdirectories by the first two characters.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+suffix)
+}
+
+// expired reports whether an entry is older than the time to live.
+func (c *Cache) expired(info fs.FileInfo, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(info.ModTime()) > c.ttl
+}
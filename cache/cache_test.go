@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// age sets the modification time of the entry of the key to the past.
+func age(t *testing.T, c *Cache, key string, by time.Duration) {
+	t.Helper()
+	past := time.Now().Add(-by)
+	if err := os.Chtimes(c.path(key), past, past); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+// TestGetPut tests storing, reading and expiring entries.
+func TestGetPut(t *testing.T) {
+	c, err := Open(filepath.Join(t.TempDir(), "cache"), time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	key := Key("openai", "gpt-4o", "prompt")
+	if key == Key("openai", "gpt-4o", "prompt2") || key == Key("openai", "gpt-4ox", "prompt") {
+		t.Error("Expected different keys for different parts")
+	}
+
+	if _, ok := c.Get(key); ok {
+		t.Error("Expected no entry in an empty cache")
+	}
+	if err := c.Put(key, []byte("answer")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, ok := c.Get(key); !ok || string(data) != "answer" {
+		t.Errorf("Expected the stored entry, got %q (%v)", data, ok)
+	}
+
+	age(t, c, key, 2*time.Hour)
+	if _, ok := c.Get(key); ok {
+		t.Error("Expected an expired entry to be ignored")
+	}
+	if c.Hits() != 1 || c.Misses() != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %d and %d", c.Hits(), c.Misses())
+	}
+}
+
+// TestPrune tests that expired entries and then the oldest entries are evicted.
+func TestPrune(t *testing.T) {
+	c, err := Open(t.TempDir(), time.Hour, 25)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	keys := []string{Key("a"), Key("b"), Key("c"), Key("d")}
+	for i, key := range keys {
+		if err := c.Put(key, []byte("0123456789")); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		age(t, c, key, time.Duration(len(keys)-i)*time.Minute)
+	}
+	age(t, c, keys[1], 2*time.Hour)
+
+	stats, err := c.Stats()
+	if err != nil || stats.Entries != 4 || stats.Size != 40 || stats.Expired != 1 {
+		t.Fatalf("Expected 4 entries of 40 bytes with 1 expired, got %+v (%v)", stats, err)
+	}
+
+	if err := c.Prune(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, kept := range []bool{false, false, true, true} {
+		if _, ok := c.Get(keys[i]); ok != kept {
+			t.Errorf("Entry %d: expected kept %v, got %v", i, kept, ok)
+		}
+	}
+
+	removed, err := c.Clear()
+	if err != nil || removed != 2 {
+		t.Errorf("Expected 2 entries to be removed, got %d (%v)", removed, err)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 {
+		t.Errorf("Expected an empty cache, got %+v", stats)
+	}
+}
@@ -2,14 +2,23 @@ package chatgpt
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"strings"
 )
 
-const defaultAPIURL = "https://api.openai.com/v1/engines/gpt-3.5-turbo-instruct/completions"
+// DefaultModel is the model used when none is configured.
+const DefaultModel = "gpt-4o"
+
+const (
+	defaultAPIURL          = "https://api.openai.com/v1/chat/completions"
+	defaultTemperature     = 0.2
+	defaultAzureAPIVersion = "2024-10-21"
+)
 
 // ChatGPTClient holds the configuration for the API client
 type ChatGPTClient struct {
@@ -17,6 +26,21 @@ type ChatGPTClient struct {
 	OrganizationID string
 	ProjectID      string
 	APIURL         string
+	Model          string
+	Temperature    float64
+	// Azure switches authentication to the "api-key" header used by Azure OpenAI deployments.
+	Azure bool
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// errorResponse represents the error object returned by the API on failed requests.
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
 }
 
 // NewChatGPTClient creates a new client with the given API key
@@ -30,64 +54,132 @@ func NewChatGPTClient(apiKey, organizationID, projectID string, apiURL ...string
 		OrganizationID: organizationID,
 		ProjectID:      projectID,
 		APIURL:         url,
+		Model:          DefaultModel,
+		Temperature:    defaultTemperature,
+	}
+}
+
+// NewAzureClient creates a client for an Azure OpenAI deployment. The endpoint is the resource URL,
+// e.g. https://my-resource.openai.azure.com, and the deployment name selects the model.
+func NewAzureClient(apiKey, endpoint, deployment, apiVersion string) *ChatGPTClient {
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	url := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		strings.TrimSuffix(endpoint, "/"), deployment, apiVersion)
+	return &ChatGPTClient{
+		APIKey:      apiKey,
+		APIURL:      url,
+		Model:       deployment,
+		Temperature: defaultTemperature,
+		Azure:       true,
 	}
 }
 
-// SendRequest sends a code review request to ChatGPT and returns the response
-func (c *ChatGPTClient) SendRequest(payload types.Payload) (string, error) {
-	payloadBytes, err := json.Marshal(payload)
+// SendRequest sends a single prompt to ChatGPT as a user message and returns the response.
+// It is kept for callers built around the legacy completions payload.
+func (c *ChatGPTClient) SendRequest(ctx context.Context, payload types.Payload) (string, error) {
+	messages := []types.ChatMessage{
+		{Role: types.RoleUser, Content: payload.Prompt},
+	}
+	return c.SendChatRequest(ctx, messages, payload.MaxTokens)
+}
+
+// SendChatRequest sends the given conversation to the Chat Completions API and returns the content
+// of the first choice. Cancelling the context aborts the in-flight HTTP request.
+func (c *ChatGPTClient) SendChatRequest(ctx context.Context, messages []types.ChatMessage, maxTokens int) (string, error) {
+	resp, err := c.Complete(ctx, types.CompletionRequest{
+		Messages:    messages,
+		Temperature: c.Temperature,
+		MaxTokens:   maxTokens,
+	})
 	if err != nil {
-		log.Printf("Error marshaling payload: %v", err)
 		return "", err
 	}
+	return resp.Content, nil
+}
+
+// Complete sends a chat completion request and returns the content of the first choice.
+// It implements the llm.Client interface.
+func (c *ChatGPTClient) Complete(ctx context.Context, request types.CompletionRequest) (*types.CompletionResponse, error) {
+	model := request.Model
+	if model == "" {
+		model = c.Model
+	}
+
+	chatRequest := types.ChatRequest{
+		Model:       model,
+		Messages:    request.Messages,
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+	}
+	if request.JSONSchema != nil {
+		chatRequest.ResponseFormat = &types.ResponseFormat{Type: "json_schema", JSONSchema: request.JSONSchema}
+	}
 
-	req, err := http.NewRequest("POST", c.APIURL, bytes.NewReader(payloadBytes))
+	payloadBytes, err := json.Marshal(chatRequest)
 	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		return "", err
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.APIURL, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
-	req.Header.Set("OpenAI-Organization", c.OrganizationID)
-	req.Header.Set("OpenAI-Project", c.ProjectID)
+	if c.Azure {
+		req.Header.Set("api-key", c.APIKey)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+		req.Header.Set("OpenAI-Organization", c.OrganizationID)
+		req.Header.Set("OpenAI-Project", c.ProjectID)
+	}
 
-	client := &http.Client{}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Error sending request: %v", err)
-		return "", err
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
-
 	if err != nil {
-		log.Printf("Error reading response body: %v", err)
-		return "", err
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	var response types.Response
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, body)
+	}
+
+	var response types.ChatResponse
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		log.Printf("Error unmarshaling response: %v. Body: %s", err, string(body))
-		return "", err
-	}
-	if resp.StatusCode == 200 {
-		var apiWarning struct {
-			Warnings []string `json:"warnings"`
-		}
-		if err := json.Unmarshal(body, &apiWarning); err == nil {
-			for _, warning := range apiWarning.Warnings {
-				log.Printf("API Warning: %s", warning)
-			}
-		}
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	if response.Model == "" {
+		response.Model = model
+	}
 	if len(response.Choices) > 0 {
-		log.Printf("Response received: %s", response.Choices[0].Text)
-		return response.Choices[0].Text, nil
+		content := response.Choices[0].Message.Content
+		return &types.CompletionResponse{Content: content, Model: response.Model, Usage: response.Usage}, nil
+	}
+	return &types.CompletionResponse{Model: response.Model, Usage: response.Usage}, nil
+}
+
+// newAPIError converts an error response into a typed error carrying the API's message.
+func newAPIError(statusCode int, body []byte) error {
+	var response errorResponse
+	if err := json.Unmarshal(body, &response); err != nil || response.Error.Message == "" {
+		return types.NewAPIError(statusCode, "", strings.TrimSpace(string(body)))
+	}
+	code := response.Error.Code
+	if code == "" {
+		code = response.Error.Type
 	}
-	log.Println("No choices in response")
-	return "", nil
+	return types.NewAPIError(statusCode, code, response.Error.Message)
 }
@@ -1,7 +1,9 @@
 package chatgpt
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
 	"net/http"
 	"net/http/httptest"
@@ -11,14 +13,7 @@ import (
 // TestSendRequestSuccess tests the successful case of sending a request
 func TestSendRequestSuccess(t *testing.T) {
 	// Mock the server
-	mockResponse := types.Response{
-		Choices: []struct {
-			Text string `json:"text"`
-		}{
-			{Text: "This is a test response from ChatGPT."},
-		},
-	}
-	responseBody, _ := json.Marshal(mockResponse)
+	responseBody := []byte(`{"choices":[{"message":{"role":"assistant","content":"This is a test response from ChatGPT."},"finish_reason":"stop"}]}`)
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -37,7 +32,7 @@ func TestSendRequestSuccess(t *testing.T) {
 	}
 
 	// Call the method
-	response, err := client.SendRequest(payload)
+	response, err := client.SendRequest(context.Background(), payload)
 
 	// Validate the response
 	if err != nil {
@@ -68,7 +63,7 @@ func TestSendRequestError(t *testing.T) {
 	}
 
 	// Call the method
-	_, err := client.SendRequest(payload)
+	_, err := client.SendRequest(context.Background(), payload)
 
 	// Validate the error
 	if err == nil {
@@ -96,7 +91,7 @@ func TestSendRequestInvalidJSON(t *testing.T) {
 	}
 
 	// Call the method
-	_, err := client.SendRequest(payload)
+	_, err := client.SendRequest(context.Background(), payload)
 
 	// Validate the error
 	if err == nil {
@@ -124,7 +119,7 @@ func TestSendRequestNoChoices(t *testing.T) {
 	}
 
 	// Call the method
-	response, err := client.SendRequest(payload)
+	response, err := client.SendRequest(context.Background(), payload)
 
 	// Validate the response
 	if err != nil {
@@ -135,3 +130,135 @@ func TestSendRequestNoChoices(t *testing.T) {
 		t.Errorf("Expected empty response, got '%s'", response)
 	}
 }
+
+// TestSendChatRequest tests that the chat request carries the model, temperature and messages
+func TestSendChatRequest(t *testing.T) {
+	var received types.ChatRequest
+
+	// Mock the server and capture the request body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Looks good."}}]}`))
+	}))
+	defer server.Close()
+
+	// Create a client with the mock server URL
+	client := NewChatGPTClient("fake-api-key", "fake-org-id", "fake-project-id", server.URL)
+	client.Model = "gpt-4.1"
+	client.Temperature = 0.5
+
+	messages := []types.ChatMessage{
+		{Role: types.RoleSystem, Content: "You are a code reviewer."},
+		{Role: types.RoleUser, Content: "Review this."},
+	}
+
+	// Call the method
+	response, err := client.SendChatRequest(context.Background(), messages, 100)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response != "Looks good." {
+		t.Errorf("Expected response 'Looks good.', got '%s'", response)
+	}
+	if received.Model != "gpt-4.1" {
+		t.Errorf("Expected model 'gpt-4.1', got '%s'", received.Model)
+	}
+	if received.Temperature != 0.5 {
+		t.Errorf("Expected temperature 0.5, got %v", received.Temperature)
+	}
+	if received.MaxTokens != 100 {
+		t.Errorf("Expected max tokens 100, got %d", received.MaxTokens)
+	}
+	if len(received.Messages) != 2 || received.Messages[0].Role != types.RoleSystem {
+		t.Errorf("Expected system and user messages, got %v", received.Messages)
+	}
+}
+
+// TestAzureClientHeaders tests that the Azure client authenticates with the api-key header
+func TestAzureClientHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/review/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("api-version") != defaultAzureAPIVersion {
+			t.Errorf("Expected api-version query parameter, got '%s'", r.URL.RawQuery)
+		}
+		if r.Header.Get("api-key") != "fake-azure-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("Expected only the api-key header, got %v", r.Header)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Fine."}}]}`))
+	}))
+	defer server.Close()
+
+	client := NewAzureClient("fake-azure-key", server.URL, "review", "")
+
+	response, err := client.Complete(context.Background(), types.CompletionRequest{
+		Messages: []types.ChatMessage{{Role: types.RoleUser, Content: "Review this."}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.Content != "Fine." {
+		t.Errorf("Expected response 'Fine.', got '%s'", response.Content)
+	}
+}
+
+// TestSendRequestCancelled tests that cancelling the context aborts the request
+func TestSendRequestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+
+	// Mock a server that never answers before the context is cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := NewChatGPTClient("fake-api-key", "fake-org-id", "fake-project-id", server.URL)
+
+	_, err := client.SendRequest(ctx, types.Payload{Prompt: "Test Prompt", MaxTokens: 50})
+	if err == nil {
+		t.Fatal("Expected a cancellation error, but got none")
+	}
+}
+
+// TestSendRequestTypedErrors tests that API errors are classified and carry the API's message
+func TestSendRequestTypedErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		expected error
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`, types.ErrAuth},
+		{http.StatusTooManyRequests, `{"error":{"message":"Rate limit reached for gpt-4o","type":"requests","code":"rate_limit_exceeded"}}`, types.ErrRateLimited},
+		{http.StatusBadRequest, `{"error":{"message":"This model's maximum context length is 128000 tokens","type":"invalid_request_error","code":"context_length_exceeded"}}`, types.ErrContextLength},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+
+		client := NewChatGPTClient("fake-api-key", "fake-org-id", "fake-project-id", server.URL)
+		_, err := client.SendRequest(context.Background(), types.Payload{Prompt: "Test Prompt", MaxTokens: 50})
+		server.Close()
+
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Expected %v for status %d, got %v", test.expected, test.status, err)
+		}
+		var apiErr *types.APIError
+		if !errors.As(err, &apiErr) || apiErr.Message == "" {
+			t.Errorf("Expected an APIError with the API's message, got %v", err)
+		}
+	}
+}
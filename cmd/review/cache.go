@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/cache"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newCacheCmd creates the "cache" command group.
+func newCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect or clear the cache of model answers",
+	}
+
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the number, size and age of the cached answers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			answers, err := openCache(cmd)
+			if err != nil {
+				return err
+			}
+			stats, err := answers.Stats()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Directory: %s\n", stats.Dir)
+			fmt.Printf("Entries: %d (%d expired)\n", stats.Entries, stats.Expired)
+			fmt.Printf("Size: %.1f MB\n", float64(stats.Size)/(1<<20))
+			if stats.Entries > 0 {
+				fmt.Printf("Oldest: %s\n", stats.Oldest.Format(time.RFC3339))
+				fmt.Printf("Newest: %s\n", stats.Newest.Format(time.RFC3339))
+			}
+			return nil
+		},
+	}
+
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove every cached answer",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			answers, err := openCache(cmd)
+			if err != nil {
+				return err
+			}
+			removed, err := answers.Clear()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d cached answers\n", removed)
+			return nil
+		},
+	}
+
+	cacheCmd.AddCommand(statsCmd, clearCmd)
+	return cacheCmd
+}
+
+// openCache opens the cache directory of the configuration, even if the cache is disabled.
+func openCache(cmd *cobra.Command) (*cache.Cache, error) {
+	cfg, err := loadConfig(cmd, localDir)
+	if err != nil {
+		return nil, err
+	}
+	dir := config.CacheDir(cfg.Cache.Dir)
+	if dir == "" {
+		return nil, fmt.Errorf("no cache directory, set cache.dir or REVIEW_CACHE_DIR")
+	}
+	return cache.Open(dir, cfg.Cache.TTL, int64(cfg.Cache.MaxSizeMB)<<20)
+}
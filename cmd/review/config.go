@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// newConfigCmd creates the "config" command group.
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration files",
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Report unknown keys and bad values in the config files",
+		Args:  cobra.NoArgs,
+		// Validation errors are the output of this command, not a usage problem
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files := config.Files(localDir)
+			if len(files) == 0 {
+				fmt.Fprintln(os.Stderr, "No config file found, checking the defaults and the environment")
+			}
+
+			// Check every file on its own so that all of them are reported
+			valid := true
+			for _, path := range files {
+				cfg := config.Default()
+				if err := config.LoadFile(path, &cfg); err != nil {
+					fmt.Println(err)
+					valid = false
+					continue
+				}
+				fmt.Printf("%s: ok\n", path)
+			}
+			if !valid {
+				return fmt.Errorf("invalid config files")
+			}
+
+			cfg, err := config.Load(localDir)
+			if err != nil {
+				return err
+			}
+			if err := cfg.Validate(); err != nil {
+				return fmt.Errorf("invalid configuration:\n%w", err)
+			}
+			fmt.Println("Configuration is valid")
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(validateCmd)
+	return configCmd
+}
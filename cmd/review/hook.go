@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/hook"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// newHookCmd creates the "hook" command group managing the git hooks.
+func newHookCmd() *cobra.Command {
+	hookCmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Review changes in git pre-commit or pre-push hooks",
+	}
+
+	var force bool
+	installCmd := &cobra.Command{
+		Use:       "install [pre-commit|pre-push]",
+		Short:     "Install a git hook reviewing the changes before they are committed or pushed",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{hook.PreCommit, hook.PrePush},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to locate the review binary: %w", err)
+			}
+			path, err := hook.Install(cmd.Context(), localDir, hookName(args), executable, force)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Installed %s\n", path)
+			return nil
+		},
+	}
+	installCmd.Flags().BoolVar(&force, "force", false, "Replace an existing hook that was not installed by pr-reviewer")
+
+	uninstallCmd := &cobra.Command{
+		Use:       "uninstall [pre-commit|pre-push]",
+		Short:     "Remove a git hook installed by pr-reviewer",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{hook.PreCommit, hook.PrePush},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := hook.Uninstall(cmd.Context(), localDir, hookName(args))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", path)
+			return nil
+		},
+	}
+
+	var failOn string
+	runCmd := &cobra.Command{
+		Use:   "run pre-commit|pre-push [hook arguments]",
+		Short: "Review the staged or pushed changes, called by the installed hooks",
+		Args:  cobra.MinimumNArgs(1),
+		// Findings blocking the commit are the output of this command, not a usage problem
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := hook.Validate(name); err != nil {
+				return err
+			}
+			if os.Getenv(hook.SkipEnv) != "" {
+				return nil
+			}
+			cfg, err := loadConfig(cmd, localDir)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("fail-on") {
+				cfg.FailOn = failOn
+			}
+			threshold, ok := types.ParseSeverity(cfg.FailOn)
+			if !ok {
+				return fmt.Errorf("unknown severity %q for --fail-on", cfg.FailOn)
+			}
+			if err := checkFormat(); err != nil {
+				return err
+			}
+			ctx, cancel := runContext(cfg)
+			defer cancel()
+
+			files, source, warnings, err := hook.Changes(ctx, localDir, name, os.Stdin)
+			if err != nil {
+				return err
+			}
+			for _, warning := range warnings {
+				fmt.Fprintf(os.Stderr, "pr-reviewer: %s\n", warning)
+			}
+			if len(files) == 0 {
+				return nil
+			}
+
+			opts := review.OptionsFromConfig(cfg)
+			opts.LocalDir = localDir
+			if name == hook.PreCommit {
+				opts.Source = func(ctx context.Context, path string) (string, error) {
+					return git.FileContent(ctx, localDir, git.DiffOptions{Staged: true}, path)
+				}
+			}
+			report, err := review.ReviewDiff(ctx, opts, files)
+			if err != nil {
+				// An unavailable model must not keep developers from committing
+				fmt.Fprintf(os.Stderr, "pr-reviewer: review skipped: %v\n", err)
+				return nil
+			}
+			report.Source = source
+			if err := writeReport(report); err != nil {
+				return err
+			}
+			if len(report.Failures) > 0 {
+				fmt.Fprintf(os.Stderr, "pr-reviewer: %d hunks could not be reviewed\n", len(report.Failures))
+			}
+			if len(report.Unreviewed) > 0 {
+				fmt.Fprintf(os.Stderr, "pr-reviewer: review budget exceeded, not reviewed: %s\n", strings.Join(report.Unreviewed, ", "))
+			}
+
+			if blocking := hook.Blocking(report, threshold); len(blocking) > 0 {
+				return fmt.Errorf("pr-reviewer: %d findings at or above %s, %s blocked; fix them, or bypass with --no-verify or %s=1",
+					len(blocking), threshold, strings.TrimPrefix(name, "pre-"), hook.SkipEnv)
+			}
+			return nil
+		},
+	}
+	runCmd.Flags().StringVar(&failOn, "fail-on", "", "Fail when a finding is at or above this severity (default: major)")
+
+	hookCmd.AddCommand(installCmd, uninstallCmd, runCmd)
+	return hookCmd
+}
+
+// hookName returns the hook named by the arguments, pre-commit by default.
+func hookName(args []string) string {
+	if len(args) == 0 {
+		return hook.PreCommit
+	}
+	return args[0]
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+
+	"github.com/spf13/cobra"
+)
+
+// newLocalCmd creates the "local" command reviewing uncommitted or unpushed changes without GitHub.
+func newLocalCmd() *cobra.Command {
+	var diffOpts git.DiffOptions
+	localCmd := &cobra.Command{
+		Use:   "local",
+		Short: "Review local changes of the repository without a pull request",
+		Long: "Review local changes of the repository without a pull request. By default the uncommitted " +
+			"changes against HEAD are reviewed; --base, --staged, --commit and --range select other changes.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := diffOpts.Validate(); err != nil {
+				return err
+			}
+			cfg, err := loadConfig(cmd, localDir)
+			if err != nil {
+				return err
+			}
+			if err := checkFormat(); err != nil {
+				return err
+			}
+			ctx, cancel := runContext(cfg)
+			defer cancel()
+
+			opts := review.OptionsFromConfig(cfg)
+			opts.LocalDir = localDir
+			report, err := review.RunLocal(ctx, opts, diffOpts)
+			if report != nil {
+				if writeErr := writeReport(report); writeErr != nil {
+					return writeErr
+				}
+			}
+			return err
+		},
+	}
+
+	localCmd.Flags().StringVar(&diffOpts.Base, "base", "", "Review the changes since the merge base with this branch, e.g. main")
+	localCmd.Flags().BoolVar(&diffOpts.Staged, "staged", false, "Review the staged changes")
+	localCmd.Flags().StringVar(&diffOpts.Commit, "commit", "", "Review a single commit")
+	localCmd.Flags().StringVar(&diffOpts.Range, "range", "", "Review a revision range, e.g. main..feature")
+	return localCmd
+}
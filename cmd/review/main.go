@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/output"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -11,8 +18,52 @@ import (
 // Global variables to store flag values
 var (
 	localDir     string
+	remote       string
+	repository   string
 	prNumber     int
 	postComments bool // Default is false
+	postMode     string
+	provider     string
+	model        string
+	temperature  float64
+	// Context window of models unknown to the registry
+	contextWindow int
+	// Spending caps of a run
+	maxCost        float64
+	maxTokensTotal int
+	// Cache of model answers
+	noCache bool
+	// Review only the commits since the last review
+	incremental bool
+	// Severity rules for the review event
+	requestChangesAt string
+	approveBelow     string
+	// Filtering of files and findings
+	include           []string
+	exclude           []string
+	severityThreshold string
+	maxComments       int
+	// Code around each hunk sent to the model
+	contextLines     int
+	contextMaxTokens int
+	// GitHub Enterprise Server
+	githubURL       string
+	githubUploadURL string
+	githubCABundle  string
+	// Code host selection and self-hosted instances
+	codeHost     string
+	gitlabURL    string
+	bitbucketURL string
+	giteaURL     string
+	// Execution limits
+	concurrency int
+	timeout     time.Duration
+	// Retry policy for OpenAI and GitHub requests
+	maxRetries    int
+	retryMaxDelay time.Duration
+	// Report output
+	format     string
+	outputPath string
 )
 
 func main() {
@@ -20,21 +71,266 @@ func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "review",
 		Short: "review is a CLI tool to review GitHub PRs using ChatGPT",
-		Run: func(cmd *cobra.Command, args []string) {
-			review.RunReview(localDir, prNumber, postComments)
+		// Errors are printed once by main
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd, localDir)
+			if err != nil {
+				return err
+			}
+			rules, err := review.ParseEventRules(cfg.RequestChangesAt, cfg.ApproveBelow)
+			if err != nil {
+				return err
+			}
+			if err := checkFormat(); err != nil {
+				return err
+			}
+			ctx, cancel := runContext(cfg)
+			defer cancel()
+
+			opts := review.OptionsFromConfig(cfg)
+			opts.LocalDir = localDir
+			opts.PRNumber = prNumber
+			if repository != "" {
+				if opts.Owner, opts.Repo, err = splitRepository(repository); err != nil {
+					return err
+				}
+			}
+			report, err := review.Run(ctx, opts)
+			if report != nil {
+				if writeErr := writeReport(report); writeErr != nil {
+					return writeErr
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if len(report.Unreviewed) > 0 {
+				fmt.Fprintf(os.Stderr, "Review budget exceeded, %d files not reviewed: %s\n", len(report.Unreviewed), strings.Join(report.Unreviewed, ", "))
+			}
+			if report.UpToDate() {
+				fmt.Fprintln(os.Stderr, "No new commits since the last review")
+				return nil
+			}
+
+			if cfg.PostMode != config.PostModeOff {
+				event, err := review.Publish(ctx, opts, report, rules)
+				if err != nil {
+					return fmt.Errorf("failed to submit review: %w", err)
+				}
+				fmt.Fprintf(os.Stderr, "Submitted review with %d findings (%s)\n", len(report.Findings()), event)
+				// Only posted reviews count for the next incremental review
+				if err := review.Record(opts, report); err != nil {
+					return fmt.Errorf("failed to record the reviewed commit: %w", err)
+				}
+			}
+			return nil
 		},
 	}
 
-	// Define flags
-	rootCmd.Flags().StringVar(&localDir, "local", "", "Local git repository directory")
+	// Define flags; unset flags fall back to the config files and the environment. The persistent
+	// flags are shared with the subcommands.
+	defaults := config.Default()
+	rootCmd.PersistentFlags().StringVar(&localDir, "local", ".", "Local git repository directory")
+	rootCmd.Flags().StringVar(&remote, "remote", defaults.Remote, "Git remote whose URL names the repository")
+	rootCmd.Flags().StringVar(&repository, "repo", "", "Repository as owner/name, skipping the git remote lookup")
 	rootCmd.Flags().IntVar(&prNumber, "pr", 0, "Pull Request number to review")
-	rootCmd.Flags().BoolVar(&postComments, "post-comments", false, "Post review comments to GitHub (default: false)")
-	rootCmd.MarkFlagRequired("local")
+	rootCmd.Flags().BoolVar(&postComments, "post-comments", false, "Post review comments to GitHub, same as --post-mode review (default: false)")
+	rootCmd.Flags().StringVar(&postMode, "post-mode", defaults.PostMode, "What to post to GitHub: off, review (summary and inline comments) or summary")
+	rootCmd.Flags().StringVar(&codeHost, "code-host", "", "Code host of the repository: github, gitlab, bitbucket, bitbucket-server, gitea or forgejo (default: detected from the remote URL)")
+	rootCmd.Flags().StringVar(&gitlabURL, "gitlab-url", "", "URL of a self-managed GitLab instance (default: the host of the remote URL)")
+	rootCmd.Flags().StringVar(&bitbucketURL, "bitbucket-url", "", "URL of a Bitbucket Data Center instance (default: the host of the remote URL)")
+	rootCmd.Flags().StringVar(&giteaURL, "gitea-url", "", "URL of a Gitea or Forgejo instance (default: the host of the remote URL)")
+	rootCmd.PersistentFlags().StringVar(&githubURL, "github-url", "", "URL of a GitHub Enterprise Server instance (default: github.com)")
+	rootCmd.PersistentFlags().StringVar(&githubUploadURL, "github-upload-url", "", "Upload API URL of the GitHub Enterprise Server instance (default: derived from --github-url)")
+	rootCmd.PersistentFlags().StringVar(&githubCABundle, "github-ca-bundle", "", "PEM file with CA certificates trusted for the GitHub connection")
+	rootCmd.PersistentFlags().StringVar(&provider, "provider", defaults.Provider, "LLM provider: openai, azure, anthropic or ollama")
+	rootCmd.PersistentFlags().StringVar(&model, "model", "", "Model used for the review (default: the provider's configured model)")
+	rootCmd.PersistentFlags().Float64Var(&temperature, "temperature", defaults.Temperature, "Sampling temperature for the model")
+	rootCmd.Flags().StringVar(&requestChangesAt, "request-changes-at", "", "Request changes when a finding is at or above this severity (info, minor, major, critical)")
+	rootCmd.Flags().StringVar(&approveBelow, "approve-below", "", "Approve the PR when every finding is below this severity")
+	rootCmd.PersistentFlags().StringSliceVar(&include, "include", nil, "Only review files matching these glob patterns")
+	rootCmd.PersistentFlags().StringSliceVar(&exclude, "exclude", nil, "Skip files matching these glob patterns")
+	rootCmd.PersistentFlags().StringVar(&severityThreshold, "severity-threshold", "", "Drop findings below this severity from the report")
+	rootCmd.Flags().IntVar(&maxComments, "max-comments", 0, "Post at most this many inline comments, the most severe first (default: no limit)")
+	rootCmd.PersistentFlags().IntVar(&contextWindow, "context-window", 0, "Context window of the model in tokens (default: from the model registry)")
+	rootCmd.PersistentFlags().Float64Var(&maxCost, "max-cost", 0, "Stop sending requests once the run could cost more US dollars (0 disables the cap)")
+	rootCmd.PersistentFlags().IntVar(&maxTokensTotal, "max-tokens-total", 0, "Stop sending requests once the run could use more tokens (0 disables the cap)")
+	rootCmd.Flags().BoolVar(&incremental, "incremental", false, "Review only the commits pushed since the last review of the pull request")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Send every hunk to the model instead of reusing cached answers")
+	rootCmd.PersistentFlags().IntVar(&contextLines, "context-lines", defaults.Context.Lines, "Lines of the changed file shown before and after each hunk")
+	rootCmd.PersistentFlags().IntVar(&contextMaxTokens, "context-max-tokens", defaults.Context.MaxTokens, "Estimated token budget of the code sent around each hunk (0 sends no context)")
+	rootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", defaults.Concurrency, "Number of hunks reviewed in parallel")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Abort the whole review after this duration, e.g. 5m (default: no timeout)")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", defaults.MaxRetries, "Retries for rate limited or failed API requests (0 disables retries)")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxDelay, "retry-max-delay", defaults.RetryMaxDelay, "Longest backoff between retries; longer Retry-After waits are not retried")
+	rootCmd.PersistentFlags().StringVar(&format, "format", "text", "Report format: "+strings.Join(output.Formats(), ", "))
+	rootCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Write the report to this file instead of stdout")
 	rootCmd.MarkFlagRequired("pr")
 
+	rootCmd.AddCommand(newConfigCmd(), newLocalCmd(), newHookCmd(), newServeCmd(), newCacheCmd())
+
 	// Execute the command
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+// loadConfig loads the configuration of the repository in dir, applies the flags set on the command
+// line on top of it and validates the result.
+func loadConfig(cmd *cobra.Command, dir string) (config.Config, error) {
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return cfg, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("post-comments") {
+		cfg.PostMode = config.PostModeOff
+		if postComments {
+			cfg.PostMode = config.PostModeReview
+		}
+	}
+	if flags.Changed("post-mode") {
+		cfg.PostMode = postMode
+	}
+	if flags.Changed("remote") {
+		cfg.Remote = remote
+	}
+	if flags.Changed("code-host") {
+		cfg.CodeHost = codeHost
+	}
+	if flags.Changed("gitlab-url") {
+		cfg.GitlabURL = gitlabURL
+	}
+	if flags.Changed("bitbucket-url") {
+		cfg.BitbucketURL = bitbucketURL
+	}
+	if flags.Changed("gitea-url") {
+		cfg.GiteaURL = giteaURL
+	}
+	if flags.Changed("github-url") {
+		cfg.GithubURL = githubURL
+	}
+	if flags.Changed("github-upload-url") {
+		cfg.GithubUploadURL = githubUploadURL
+	}
+	if flags.Changed("github-ca-bundle") {
+		cfg.GithubCABundle = githubCABundle
+	}
+	if flags.Changed("provider") {
+		cfg.Provider = provider
+	}
+	if flags.Changed("model") {
+		cfg.Model = model
+	}
+	if flags.Changed("temperature") {
+		cfg.Temperature = temperature
+	}
+	if flags.Changed("request-changes-at") {
+		cfg.RequestChangesAt = requestChangesAt
+	}
+	if flags.Changed("approve-below") {
+		cfg.ApproveBelow = approveBelow
+	}
+	if flags.Changed("include") {
+		cfg.Include = include
+	}
+	if flags.Changed("exclude") {
+		cfg.Exclude = exclude
+	}
+	if flags.Changed("severity-threshold") {
+		cfg.SeverityThreshold = severityThreshold
+	}
+	if flags.Changed("max-comments") {
+		cfg.MaxComments = maxComments
+	}
+	if flags.Changed("context-window") {
+		cfg.ContextWindow = contextWindow
+	}
+	if flags.Changed("max-cost") {
+		cfg.MaxCost = maxCost
+	}
+	if flags.Changed("max-tokens-total") {
+		cfg.MaxTokensTotal = maxTokensTotal
+	}
+	if flags.Changed("incremental") {
+		cfg.Incremental = incremental
+	}
+	if flags.Changed("no-cache") {
+		cfg.Cache.Enabled = !noCache
+	}
+	if flags.Changed("context-lines") {
+		cfg.Context.Lines = contextLines
+	}
+	if flags.Changed("context-max-tokens") {
+		cfg.Context.MaxTokens = contextMaxTokens
+	}
+	if flags.Changed("concurrency") {
+		cfg.Concurrency = concurrency
+	}
+	if flags.Changed("timeout") {
+		cfg.Timeout = timeout
+	}
+	if flags.Changed("max-retries") {
+		cfg.MaxRetries = maxRetries
+	}
+	if flags.Changed("retry-max-delay") {
+		cfg.RetryMaxDelay = retryMaxDelay
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// splitRepository splits an "owner/name" repository argument. The owner may contain slashes for the
+// nested groups of other hosts.
+func splitRepository(repository string) (string, string, error) {
+	slash := strings.LastIndex(repository, "/")
+	if slash <= 0 || slash == len(repository)-1 {
+		return "", "", fmt.Errorf("invalid repository %q, expected owner/name", repository)
+	}
+	return repository[:slash], repository[slash+1:], nil
+}
+
+// checkFormat reports an unknown report format before any request is made.
+func checkFormat() error {
+	if !output.Supports(format) {
+		return fmt.Errorf("unknown output format %q, supported formats: %s", format, strings.Join(output.Formats(), ", "))
+	}
+	return nil
+}
+
+// runContext returns the context of a review run, cancelled on SIGINT/SIGTERM or when the configured
+// timeout expires so that in-flight requests are aborted.
+func runContext(cfg config.Config) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if cfg.Timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// writeReport renders the report in the selected format to stdout or the output file.
+func writeReport(report *review.Report) error {
+	if outputPath == "" {
+		return output.Write(os.Stdout, format, report)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := output.Write(file, format, report); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/server"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newServeCmd creates the "serve" command reviewing pull requests on GitHub webhooks.
+func newServeCmd() *cobra.Command {
+	var addr string
+	var opts server.Options
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Review pull requests automatically on GitHub pull_request webhooks",
+		Long: "Review pull requests automatically on GitHub pull_request webhooks. Point the webhook of the " +
+			"repository or organization at /webhook and set the same secret in GITHUB_WEBHOOK_SECRET.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(cmd, localDir)
+			if err != nil {
+				return err
+			}
+			if cfg.WebhookSecret == "" {
+				return fmt.Errorf("GITHUB_WEBHOOK_SECRET is required to verify webhook deliveries")
+			}
+			rules, err := review.ParseEventRules(cfg.RequestChangesAt, cfg.ApproveBelow)
+			if err != nil {
+				return err
+			}
+			if cfg.PostMode == config.PostModeOff {
+				log.Printf("Post mode is off, reviews are only logged; set post_mode or --post-mode to publish them")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			opts.Secret = cfg.WebhookSecret
+			srv := server.New(opts, func(ctx context.Context, job server.Job) error {
+				return reviewJob(ctx, cfg, rules, job)
+			})
+			go srv.Run(ctx)
+
+			httpServer := &http.Server{Addr: addr, Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				httpServer.Shutdown(shutdownCtx)
+			}()
+
+			log.Printf("Listening for webhooks on %s", addr)
+			if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	serveCmd.Flags().StringVar(&addr, "addr", ":8080", "Address the webhook server listens on")
+	serveCmd.Flags().IntVar(&opts.QueueSize, "queue-size", 100, "Pending reviews before deliveries are rejected")
+	serveCmd.Flags().IntVar(&opts.Workers, "workers", 2, "Pull requests reviewed in parallel")
+	serveCmd.Flags().StringVar(&postMode, "post-mode", config.Default().PostMode, "What to post to GitHub: off, review (summary and inline comments) or summary")
+	return serveCmd
+}
+
+// reviewJob reviews the pull request of a webhook delivery and publishes the result.
+func reviewJob(ctx context.Context, cfg config.Config, rules review.EventRules, job server.Job) error {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	// Deliveries of a GitHub App name the installation to authenticate as
+	if job.InstallationID != 0 && cfg.GithubAppID != 0 {
+		cfg.GithubAppInstallationID = job.InstallationID
+	}
+
+	opts := review.OptionsFromConfig(cfg)
+	opts.Owner, opts.Repo, opts.PRNumber, opts.HeadSHA = job.Owner, job.Repo, job.PRNumber, job.HeadSHA
+	report, err := review.Run(ctx, opts)
+	if errors.Is(err, review.ErrHeadMoved) {
+		// The delivery of the push that moved the head reviews the new commits
+		log.Printf("Skipped %s/%s#%d: %v", job.Owner, job.Repo, job.PRNumber, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Reviewed %s/%s#%d: %d findings, %d failures", job.Owner, job.Repo, job.PRNumber, len(report.Findings()), len(report.Failures))
+	if report.UpToDate() {
+		return nil
+	}
+
+	if cfg.PostMode != config.PostModeOff {
+		event, err := review.Publish(ctx, opts, report, rules)
+		if err != nil {
+			return fmt.Errorf("failed to submit review: %w", err)
+		}
+		log.Printf("Submitted review of %s/%s#%d (%s)", job.Owner, job.Repo, job.PRNumber, event)
+		// Only posted reviews count for the next incremental review
+		if err := review.Record(opts, report); err != nil {
+			return fmt.Errorf("failed to record the reviewed commit: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/bitbucket"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"strings"
+)
+
+// bitbucketCloudHost reviews the pull requests of a Bitbucket Cloud repository.
+type bitbucketCloudHost struct {
+	client    *bitbucket.CloudClient
+	workspace string
+	repo      string
+}
+
+// bitbucketServerHost reviews the pull requests of a Bitbucket Data Center repository.
+type bitbucketServerHost struct {
+	client  *bitbucket.ServerClient
+	project string
+	repo    string
+}
+
+// newBitbucketCloud creates the Bitbucket Cloud client of the repository.
+func newBitbucketCloud(cfg config.Config, remote git.Remote, policy retry.Policy) *bitbucketCloudHost {
+	client := bitbucket.NewCloudClient(cfg.BitbucketUsername, cfg.BitbucketToken)
+	client.HTTPClient = retry.NewClient(policy)
+	return &bitbucketCloudHost{client: client, workspace: remote.Owner, repo: remote.Repo}
+}
+
+// newBitbucketServer creates the Bitbucket Data Center client of the repository. HTTP clone URLs have
+// the form https://host/scm/PROJECT/repo.git; personal repositories belong to the project ~user.
+func newBitbucketServer(cfg config.Config, remote git.Remote, policy retry.Policy) (*bitbucketServerHost, error) {
+	project := strings.TrimPrefix(remote.Owner, "scm/")
+	if project == "" || strings.Contains(project, "/") {
+		return nil, fmt.Errorf("invalid Bitbucket repository %s: Bitbucket repositories are named PROJECT/repo", remote.FullName())
+	}
+	if !strings.HasPrefix(project, "~") {
+		project = strings.ToUpper(project)
+	}
+
+	client := bitbucket.NewServerClient(instanceURL(cfg.BitbucketURL, remote), cfg.BitbucketUsername, cfg.BitbucketToken)
+	client.HTTPClient = retry.NewClient(policy)
+	return &bitbucketServerHost{client: client, project: project, repo: remote.Repo}, nil
+}
+
+// Changes lists the changed files with the diffstat of the pull request and takes their patches from
+// its diff. Binary files have an empty patch.
+func (h *bitbucketCloudHost) Changes(ctx context.Context, number int) (*Changes, error) {
+	stats, err := h.client.DiffStat(ctx, h.workspace, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := h.client.Diff(ctx, h.workspace, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := h.client.HeadCommit(ctx, h.workspace, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := patchesByPath(diff)
+	changes := &Changes{HeadSHA: head}
+	for _, stat := range stats {
+		changes.Files = append(changes.Files, types.FileDiff{
+			Path:      stat.Path(),
+			Patch:     patches[stat.Path()].Patch,
+			Additions: stat.LinesAdded,
+			Deletions: stat.LinesRemoved,
+		})
+	}
+	return changes, nil
+}
+
+// File fetches the raw file from the repository.
+func (h *bitbucketCloudHost) File(ctx context.Context, ref, path string) (string, error) {
+	return h.client.FileContent(ctx, h.workspace, h.repo, ref, path)
+}
+
+// Compare checks with the merge base that base is an ancestor of head and diffs the commits.
+func (h *bitbucketCloudHost) Compare(ctx context.Context, base, head string) (*Changes, error) {
+	mergeBase, err := h.client.MergeBase(ctx, h.workspace, h.repo, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	if !sameCommit(mergeBase, base) {
+		return nil, fmt.Errorf("%w: %s is not an ancestor of %s", ErrDiverged, base, head)
+	}
+	diff, err := h.client.CompareDiff(ctx, h.workspace, h.repo, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	return &Changes{Files: git.ParseDiff(diff), HeadSHA: head}, nil
+}
+
+// SubmitReview posts the review body and every comment inline on its line. Bitbucket Cloud has no review
+// object, so approving reviews approve the pull request and others requesting changes request them.
+// Comments cannot name a commit, so when the pull request moved on from the reviewed commit they are
+// posted without a line and the pull request is not approved.
+func (h *bitbucketCloudHost) SubmitReview(ctx context.Context, number int, review types.Review) error {
+	moved, err := headMoved(ctx, review.CommitID, func(ctx context.Context) (string, error) {
+		return h.client.HeadCommit(ctx, h.workspace, h.repo, number)
+	})
+	if err != nil {
+		return err
+	}
+	if err := h.client.CreateComment(ctx, h.workspace, h.repo, number, review.Body, nil); err != nil {
+		return err
+	}
+	for _, comment := range review.Comments {
+		if moved {
+			if err := h.client.CreateComment(ctx, h.workspace, h.repo, number, unpositioned(comment), nil); err != nil {
+				return err
+			}
+			continue
+		}
+		inline := &bitbucket.Inline{Path: comment.Path, To: comment.Line}
+		if comment.Side == types.SideLeft {
+			inline = &bitbucket.Inline{Path: comment.Path, From: comment.Line}
+		}
+		err := h.client.CreateComment(ctx, h.workspace, h.repo, number, comment.Body, inline)
+		if rejected(err) {
+			err = h.client.CreateComment(ctx, h.workspace, h.repo, number, unpositioned(comment), nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case review.Event == types.ReviewEventApprove && !moved:
+		return h.client.Approve(ctx, h.workspace, h.repo, number)
+	case review.Event == types.ReviewEventRequestChanges:
+		return h.client.RequestChanges(ctx, h.workspace, h.repo, number)
+	}
+	return nil
+}
+
+// Changes fetches the diff of the pull request.
+func (h *bitbucketServerHost) Changes(ctx context.Context, number int) (*Changes, error) {
+	files, truncated, err := h.client.Diff(ctx, h.project, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+	head, err := h.client.HeadCommit(ctx, h.project, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return &Changes{Files: files, HeadSHA: head, Truncated: truncated}, nil
+}
+
+// File fetches the raw file from the repository.
+func (h *bitbucketServerHost) File(ctx context.Context, ref, path string) (string, error) {
+	return h.client.FileContent(ctx, h.project, h.repo, ref, path)
+}
+
+// Compare checks that head contains every commit of base and diffs the commits.
+func (h *bitbucketServerHost) Compare(ctx context.Context, base, head string) (*Changes, error) {
+	ancestor, err := h.client.Ancestor(ctx, h.project, h.repo, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	if !ancestor {
+		return nil, fmt.Errorf("%w: %s is not an ancestor of %s", ErrDiverged, base, head)
+	}
+	files, truncated, err := h.client.CompareDiff(ctx, h.project, h.repo, head, base)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	return &Changes{Files: files, HeadSHA: head, Truncated: truncated}, nil
+}
+
+// SubmitReview posts the review body and every comment anchored to its added or removed line, then sets
+// the reviewer status for approving reviews and reviews requesting changes. Anchors are on the current
+// diff, so when the pull request moved on from the reviewed commit comments are posted without a line
+// and the pull request is not approved.
+func (h *bitbucketServerHost) SubmitReview(ctx context.Context, number int, review types.Review) error {
+	moved, err := headMoved(ctx, review.CommitID, func(ctx context.Context) (string, error) {
+		return h.client.HeadCommit(ctx, h.project, h.repo, number)
+	})
+	if err != nil {
+		return err
+	}
+	if err := h.client.CreateComment(ctx, h.project, h.repo, number, review.Body, nil); err != nil {
+		return err
+	}
+	for _, comment := range review.Comments {
+		if moved {
+			if err := h.client.CreateComment(ctx, h.project, h.repo, number, unpositioned(comment), nil); err != nil {
+				return err
+			}
+			continue
+		}
+		anchor := &bitbucket.Anchor{
+			Path:     comment.Path,
+			Line:     comment.Line,
+			LineType: "ADDED",
+			FileType: "TO",
+			DiffType: "EFFECTIVE",
+		}
+		if comment.Side == types.SideLeft {
+			anchor.LineType, anchor.FileType = "REMOVED", "FROM"
+		}
+		err := h.client.CreateComment(ctx, h.project, h.repo, number, comment.Body, anchor)
+		if rejected(err) {
+			err = h.client.CreateComment(ctx, h.project, h.repo, number, unpositioned(comment), nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case review.Event == types.ReviewEventApprove && !moved:
+		return h.client.SetStatus(ctx, h.project, h.repo, number, bitbucket.StatusApproved)
+	case review.Event == types.ReviewEventRequestChanges:
+		return h.client.SetStatus(ctx, h.project, h.repo, number, bitbucket.StatusNeedsWork)
+	}
+	return nil
+}
+
+// rejected reports whether the code host refused a comment because of its position, which Bitbucket
+// answers with a bad request or, on Data Center, a conflict.
+func rejected(err error) bool {
+	var apiErr *types.APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusConflict)
+}
@@ -0,0 +1,166 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Supported code hosts.
+const (
+	GitHub          = "github"
+	GitLab          = "gitlab"
+	Bitbucket       = "bitbucket"
+	BitbucketServer = "bitbucket-server"
+	// Gitea also serves Forgejo, which keeps the Gitea API.
+	Gitea = "gitea"
+)
+
+// forgejo is accepted as code_host for Forgejo instances.
+const forgejo = "forgejo"
+
+// giteaHosts are public Gitea and Forgejo instances.
+var giteaHosts = map[string]bool{"gitea.com": true, "codeberg.org": true}
+
+// ErrDiverged is returned by Compare when the base commit is not an ancestor of the head commit, as after
+// a force-push, or no longer exists.
+var ErrDiverged = errors.New("commits have diverged")
+
+// Changes holds the changed files of a pull or merge request.
+type Changes struct {
+	Files []types.FileDiff
+	// HeadSHA is the head commit of the request, holding the new version of the files.
+	HeadSHA string
+	// Truncated reports that the code host cut the file list at its limit; SkippedFiles counts the
+	// files left out when the host reports the total.
+	Truncated    bool
+	SkippedFiles int
+}
+
+// Host is implemented by every code host the review loop fetches changes from and submits reviews to.
+// Pull and merge requests are identified by their number within the repository.
+type Host interface {
+	Changes(ctx context.Context, number int) (*Changes, error)
+	// File returns the content of the file at path in the commit ref.
+	File(ctx context.Context, ref, path string) (string, error)
+	// Compare returns the changes from the commit base to the commit head, or ErrDiverged if base is not
+	// an ancestor of head. Hosts that cannot diff commits list the changed files with empty patches.
+	Compare(ctx context.Context, base, head string) (*Changes, error)
+	// SubmitReview posts the review body and its inline comments. Hosts without comments on line ranges
+	// anchor a comment to its last line. Comments the host rejects because their lines are outside of
+	// the diff are posted without a position instead.
+	SubmitReview(ctx context.Context, number int, review types.Review) error
+}
+
+// New creates the client of the code host serving the repository, selected with Detect, using the
+// credentials from the configuration. Failed requests are retried according to the policy.
+func New(ctx context.Context, cfg config.Config, remote git.Remote, policy retry.Policy) (Host, error) {
+	switch kind := Detect(cfg, remote.Host); kind {
+	case GitHub:
+		return newGitHub(ctx, cfg, remote, policy)
+	case GitLab:
+		return newGitLab(cfg, remote, policy), nil
+	case Bitbucket:
+		return newBitbucketCloud(cfg, remote, policy), nil
+	case BitbucketServer:
+		return newBitbucketServer(cfg, remote, policy)
+	case Gitea:
+		return newGitea(cfg, remote, policy), nil
+	default:
+		return nil, fmt.Errorf("unknown code host %q", kind)
+	}
+}
+
+// Detect returns the kind of code host serving the remote host. The code_host setting wins; otherwise
+// the host is matched against the public instances, the configured instance URLs and host names such as
+// gitlab.example.com. Every other host, including an unknown one, is GitHub or a GitHub Enterprise Server.
+func Detect(cfg config.Config, host string) string {
+	if cfg.CodeHost != "" {
+		if kind := strings.ToLower(cfg.CodeHost); kind != forgejo {
+			return kind
+		}
+		return Gitea
+	}
+
+	host = strings.ToLower(host)
+	switch {
+	case host == "":
+		return GitHub
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab.") || host == urlHost(cfg.GitlabURL):
+		return GitLab
+	case host == "bitbucket.org":
+		return Bitbucket
+	case strings.HasPrefix(host, "bitbucket.") || host == urlHost(cfg.BitbucketURL):
+		return BitbucketServer
+	case giteaHosts[host] || strings.HasPrefix(host, "gitea.") || strings.HasPrefix(host, "forgejo.") || host == urlHost(cfg.GiteaURL):
+		return Gitea
+	}
+	return GitHub
+}
+
+// instanceURL returns the configured URL of a self-hosted instance, or the HTTPS URL of the remote's
+// host when none is configured.
+func instanceURL(configured string, remote git.Remote) string {
+	if configured == "" && remote.Host != "" {
+		return "https://" + remote.Host
+	}
+	return configured
+}
+
+// unpositioned renders a comment the code host rejected on its line as a comment on the whole pull
+// request, naming the file and lines it refers to.
+func unpositioned(comment types.ReviewComment) string {
+	return fmt.Sprintf("`%s` %s:\n%s", comment.Path, comment.Lines(), comment.Body)
+}
+
+// compareError converts the error of a comparison with a missing commit into ErrDiverged.
+func compareError(err error) error {
+	var apiErr *types.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %v", ErrDiverged, err)
+	}
+	return err
+}
+
+// sameCommit reports whether the hashes name the same commit; either may be abbreviated.
+func sameCommit(a, b string) bool {
+	return a != "" && b != "" && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a))
+}
+
+// headMoved reports whether the head of the pull request, returned by head, is no longer the reviewed
+// commit. Reviews without a commit are of the current head.
+func headMoved(ctx context.Context, commit string, head func(context.Context) (string, error)) (bool, error) {
+	if commit == "" {
+		return false, nil
+	}
+	current, err := head(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !sameCommit(current, commit), nil
+}
+
+// urlHost returns the lower case host name of the URL, empty if it does not parse.
+func urlHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// patchesByPath splits a unified diff into the patches of its files, keyed by the new path.
+func patchesByPath(diff string) map[string]types.FileDiff {
+	patches := make(map[string]types.FileDiff)
+	for _, file := range git.ParseDiff(diff) {
+		patches[file.Path] = file
+	}
+	return patches
+}
@@ -0,0 +1,320 @@
+package codehost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDetect tests selecting the code host from the remote host and the configuration.
+func TestDetect(t *testing.T) {
+	cfg := config.Default()
+	cfg.GitlabURL = "https://code.example.com"
+	cfg.BitbucketURL = "https://stash.example.com/bitbucket"
+	cfg.GiteaURL = "https://git.example.com"
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"github.com", GitHub},
+		{"github.example.com", GitHub},
+		{"", GitHub},
+		{"gitlab.com", GitLab},
+		{"gitlab.example.com", GitLab},
+		{"CODE.example.com", GitLab},
+		{"bitbucket.org", Bitbucket},
+		{"bitbucket.example.com", BitbucketServer},
+		{"stash.example.com", BitbucketServer},
+		{"codeberg.org", Gitea},
+		{"forgejo.example.com", Gitea},
+		{"git.example.com", Gitea},
+	}
+	for _, test := range tests {
+		if kind := Detect(cfg, test.host); kind != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.host, test.expected, kind)
+		}
+	}
+
+	cfg.CodeHost = "GitLab"
+	if kind := Detect(cfg, "github.com"); kind != GitLab {
+		t.Errorf("Expected the configured code host to win, got %s", kind)
+	}
+	cfg.CodeHost = "forgejo"
+	if kind := Detect(cfg, "github.com"); kind != Gitea {
+		t.Errorf("Expected Forgejo to use the Gitea API, got %s", kind)
+	}
+}
+
+// TestGitLabSubmitReview tests that comments become positioned discussions, falling back to plain
+// discussions for lines GitLab rejects and old lines for deleted code, and that approving reviews approve
+// the merge request.
+func TestGitLabSubmitReview(t *testing.T) {
+	var discussions []map[string]interface{}
+	var note string
+	approved := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/api/v4/projects/group/sub/repo/merge_requests/5"
+		var body map[string]interface{}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+		switch r.URL.Path {
+		case prefix:
+			w.Write([]byte(`{"iid":5,"sha":"head","diff_refs":{"base_sha":"base","start_sha":"start","head_sha":"head"}}`))
+		case prefix + "/diffs":
+			w.Write([]byte(`[{"old_path":"old.go","new_path":"new.go","diff":"@@ -1 +1,2 @@\n a\n+b\n"}]`))
+		case prefix + "/notes":
+			note = body["body"].(string)
+			w.WriteHeader(http.StatusCreated)
+		case prefix + "/discussions":
+			if position, ok := body["position"].(map[string]interface{}); ok && position["new_line"] != nil && position["new_line"].(float64) > 2 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"message":"400 Bad request - Note {:line_code=>[\"can't be blank\"]}"}`))
+				return
+			}
+			discussions = append(discussions, body)
+			w.WriteHeader(http.StatusCreated)
+		case prefix + "/approve":
+			approved = body["sha"].(string)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.GitlabURL = server.URL
+	host, err := New(context.Background(), cfg, git.Remote{Host: "gitlab.com", Owner: "group/sub", Repo: "repo"}, retry.Policy{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	err = host.SubmitReview(context.Background(), 5, types.Review{
+		Body:  "Summary",
+		Event: types.ReviewEventApprove,
+		Comments: []types.ReviewComment{
+			{Path: "new.go", Line: 2, Body: "Inline"},
+			{Path: "new.go", Line: 9, Body: "Outside"},
+			{Path: "new.go", Line: 1, Side: types.SideLeft, Body: "Deleted"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if note != "Summary" || approved != "head" {
+		t.Errorf("Expected the summary note and an approval of the head, got %q and %q", note, approved)
+	}
+	if len(discussions) != 3 {
+		t.Fatalf("Expected 3 discussions, got %d", len(discussions))
+	}
+	position := discussions[0]["position"].(map[string]interface{})
+	if position["old_path"] != "old.go" || position["base_sha"] != "base" || position["start_sha"] != "start" || position["head_sha"] != "head" {
+		t.Errorf("Expected a position on the latest diff of the renamed file, got %v", position)
+	}
+	if _, ok := discussions[1]["position"]; ok || !strings.Contains(discussions[1]["body"].(string), "`new.go` line 9") {
+		t.Errorf("Expected an unpositioned discussion naming the line, got %v", discussions[1])
+	}
+	position = discussions[2]["position"].(map[string]interface{})
+	if _, ok := position["new_line"]; ok || position["old_line"] != 1.0 {
+		t.Errorf("Expected a position on the old line of a deleted line, got %v", position)
+	}
+}
+
+// TestGitLabSubmitReviewMoved tests that comments are positioned on the diff version of the reviewed commit
+// after the merge request moved on, and that the newer head is not approved.
+func TestGitLabSubmitReviewMoved(t *testing.T) {
+	var positions []interface{}
+	approved := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/api/v4/projects/owner/repo/merge_requests/5"
+		var body map[string]interface{}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+		switch r.URL.Path {
+		case prefix:
+			w.Write([]byte(`{"iid":5,"sha":"newer","diff_refs":{"base_sha":"base","start_sha":"start","head_sha":"newer"}}`))
+		case prefix + "/versions":
+			w.Write([]byte(`[{"head_commit_sha":"newer","base_commit_sha":"base","start_commit_sha":"start"},` +
+				`{"head_commit_sha":"reviewed","base_commit_sha":"base1","start_commit_sha":"start1"}]`))
+		case prefix + "/diffs":
+			w.Write([]byte(`[{"old_path":"a.go","new_path":"a.go","diff":"@@ -1 +1,2 @@\n a\n+b\n"}]`))
+		case prefix + "/notes":
+			w.WriteHeader(http.StatusCreated)
+		case prefix + "/discussions":
+			positions = append(positions, body["position"])
+			w.WriteHeader(http.StatusCreated)
+		case prefix + "/approve":
+			approved = true
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.GitlabURL = server.URL
+	host, err := New(context.Background(), cfg, git.Remote{Host: "gitlab.com", Owner: "owner", Repo: "repo"}, retry.Policy{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	review := types.Review{
+		CommitID: "reviewed",
+		Body:     "Summary",
+		Event:    types.ReviewEventApprove,
+		Comments: []types.ReviewComment{{Path: "a.go", Line: 2, Body: "Inline"}},
+	}
+	if err := host.SubmitReview(context.Background(), 5, review); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if approved {
+		t.Error("Expected a head the review did not see not to be approved")
+	}
+	position, _ := positions[0].(map[string]interface{})
+	if position["head_sha"] != "reviewed" || position["base_sha"] != "base1" || position["start_sha"] != "start1" {
+		t.Errorf("Expected a position on the version of the reviewed commit, got %v", positions[0])
+	}
+
+	// Without a version of the reviewed commit the comment is not positioned
+	review.CommitID = "rebased"
+	if err := host.SubmitReview(context.Background(), 5, review); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(positions) != 2 || positions[1] != nil {
+		t.Errorf("Expected an unpositioned discussion, got %v", positions)
+	}
+}
+
+// TestGiteaSubmitReviewFallback tests that comments Gitea rejects are posted on the pull request after
+// the review was submitted without them.
+func TestGiteaSubmitReviewFallback(t *testing.T) {
+	var reviews []map[string]interface{}
+	var comments []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/pulls/8/reviews":
+			if body["comments"] != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"message":"line is not part of the diff"}`))
+				return
+			}
+			reviews = append(reviews, body)
+		case "/api/v1/repos/owner/repo/issues/8/comments":
+			comments = append(comments, body["body"].(string))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.GiteaURL = server.URL
+	host, err := New(context.Background(), cfg, git.Remote{Host: "codeberg.org", Owner: "owner", Repo: "repo"}, retry.Policy{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	err = host.SubmitReview(context.Background(), 8, types.Review{
+		Body:     "Summary",
+		Event:    types.ReviewEventRequestChanges,
+		Comments: []types.ReviewComment{{Path: "a.go", Line: 3, Body: "Inline"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reviews) != 1 || reviews[0]["event"] != "REQUEST_CHANGES" {
+		t.Errorf("Expected a review requesting changes, got %v", reviews)
+	}
+	if len(comments) != 1 || !strings.HasPrefix(comments[0], "`a.go` line 3:") {
+		t.Errorf("Expected the rejected comment on the pull request, got %v", comments)
+	}
+}
+
+// TestGitLabCompare tests that commits are compared from an ancestor and that diverged or missing
+// commits are reported as ErrDiverged.
+func TestGitLabCompare(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/api/v4/projects/group/repo/repository"
+		switch r.URL.Path {
+		case prefix + "/merge_base":
+			switch refs := r.URL.Query()["refs[]"]; refs[0] {
+			case "gone":
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"404 Commit Not Found"}`))
+			case "rebased":
+				w.Write([]byte(`{"id":"main"}`))
+			default:
+				w.Write([]byte(`{"id":"` + refs[0] + `"}`))
+			}
+		case prefix + "/compare":
+			if r.URL.Query().Get("from") != "last" || r.URL.Query().Get("to") != "head" {
+				t.Errorf("Unexpected comparison %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"diffs":[{"old_path":"a.go","new_path":"a.go","diff":"@@ -1 +1,2 @@\n a\n+b\n"}]}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.GitlabURL = server.URL
+	host, err := New(context.Background(), cfg, git.Remote{Host: "gitlab.com", Owner: "group", Repo: "repo"}, retry.Policy{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	changes, err := host.Compare(context.Background(), "last", "head")
+	if err != nil || len(changes.Files) != 1 || changes.Files[0].Additions != 1 || changes.HeadSHA != "head" {
+		t.Errorf("Expected the diff of a.go, got %+v (%v)", changes, err)
+	}
+	for _, base := range []string{"rebased", "gone"} {
+		if _, err := host.Compare(context.Background(), base, "head"); !errors.Is(err, ErrDiverged) {
+			t.Errorf("%s: expected ErrDiverged, got %v", base, err)
+		}
+	}
+}
+
+// TestGiteaCompare tests that the files of the commits since an ancestor are listed without patches.
+func TestGiteaCompare(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/compare/head...last":
+			w.Write([]byte(`{"commits":[]}`))
+		case "/api/v1/repos/owner/repo/compare/last...head":
+			w.Write([]byte(`{"commits":[{"sha":"c1","files":[{"filename":"a.go"}]},{"sha":"c2","files":[{"filename":"a.go"},{"filename":"b.go"}]}]}`))
+		case "/api/v1/repos/owner/repo/compare/head...rebased":
+			w.Write([]byte(`{"commits":[{"sha":"old","files":[]}]}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.GiteaURL = server.URL
+	host, err := New(context.Background(), cfg, git.Remote{Host: "codeberg.org", Owner: "owner", Repo: "repo"}, retry.Policy{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	changes, err := host.Compare(context.Background(), "last", "head")
+	if err != nil || len(changes.Files) != 2 || changes.Files[0].Path != "a.go" || changes.Files[1].Path != "b.go" || changes.Files[0].Patch != "" {
+		t.Errorf("Expected a.go and b.go without patches, got %+v (%v)", changes, err)
+	}
+	if _, err := host.Compare(context.Background(), "rebased", "head"); !errors.Is(err, ErrDiverged) {
+		t.Errorf("Expected ErrDiverged, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/gitea"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+)
+
+// giteaEvents maps the review events to the events of Gitea reviews.
+var giteaEvents = map[string]string{
+	types.ReviewEventComment:        gitea.EventComment,
+	types.ReviewEventApprove:        gitea.EventApprove,
+	types.ReviewEventRequestChanges: gitea.EventRequestChanges,
+}
+
+// giteaHost reviews the pull requests of a Gitea or Forgejo repository.
+type giteaHost struct {
+	client *gitea.Client
+	owner  string
+	repo   string
+}
+
+// newGitea creates the Gitea client of the repository. Without a configured Gitea URL the instance
+// serving the remote is used.
+func newGitea(cfg config.Config, remote git.Remote, policy retry.Policy) *giteaHost {
+	client := gitea.NewClient(instanceURL(cfg.GiteaURL, remote), cfg.GiteaToken)
+	client.HTTPClient = retry.NewClient(policy)
+	return &giteaHost{client: client, owner: remote.Owner, repo: remote.Repo}
+}
+
+// Changes lists the changed files of the pull request and takes their patches from its diff. Binary
+// files have an empty patch.
+func (h *giteaHost) Changes(ctx context.Context, number int) (*Changes, error) {
+	files, err := h.client.PullFiles(ctx, h.owner, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := h.client.PullDiff(ctx, h.owner, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := h.client.HeadCommit(ctx, h.owner, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := patchesByPath(diff)
+	changes := &Changes{HeadSHA: head}
+	for _, file := range files {
+		changes.Files = append(changes.Files, types.FileDiff{
+			Path:      file.Filename,
+			Patch:     patches[file.Filename].Patch,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+		})
+	}
+	return changes, nil
+}
+
+// File fetches the raw file from the repository.
+func (h *giteaHost) File(ctx context.Context, ref, path string) (string, error) {
+	return h.client.FileContent(ctx, h.owner, h.repo, ref, path)
+}
+
+// Compare lists the files changed by the commits from base to head. The compare API of Gitea has no
+// patches, so the files are returned with empty patches. Comparing the other way round lists the commits
+// of base missing in head, which are none when base is an ancestor of head.
+func (h *giteaHost) Compare(ctx context.Context, base, head string) (*Changes, error) {
+	missing, err := h.client.Compare(ctx, h.owner, h.repo, head, base)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s is not an ancestor of %s", ErrDiverged, base, head)
+	}
+	commits, err := h.client.Compare(ctx, h.owner, h.repo, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+
+	changes := &Changes{HeadSHA: head}
+	seen := make(map[string]bool)
+	for _, commit := range commits {
+		for _, file := range commit.Files {
+			if !seen[file.Filename] {
+				seen[file.Filename] = true
+				changes.Files = append(changes.Files, types.FileDiff{Path: file.Filename})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// SubmitReview submits the review with its inline comments. When Gitea rejects the comments, the review
+// is submitted without them and the comments are posted on the pull request instead.
+func (h *giteaHost) SubmitReview(ctx context.Context, number int, review types.Review) error {
+	request := gitea.Review{
+		Body:     review.Body,
+		Event:    giteaEvents[review.Event],
+		CommitID: review.CommitID,
+	}
+	if request.Event == "" {
+		request.Event = gitea.EventComment
+	}
+	for _, comment := range review.Comments {
+		inline := gitea.ReviewComment{Path: comment.Path, Body: comment.Body, NewPosition: comment.Line}
+		if comment.Side == types.SideLeft {
+			inline = gitea.ReviewComment{Path: comment.Path, Body: comment.Body, OldPosition: comment.Line}
+		}
+		request.Comments = append(request.Comments, inline)
+	}
+
+	err := h.client.CreateReview(ctx, h.owner, h.repo, number, request)
+	var apiErr *types.APIError
+	if len(request.Comments) == 0 || !errors.As(err, &apiErr) ||
+		(apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity) {
+		return err
+	}
+
+	request.Comments = nil
+	if err := h.client.CreateReview(ctx, h.owner, h.repo, number, request); err != nil {
+		return err
+	}
+	for _, comment := range review.Comments {
+		if err := h.client.CreateComment(ctx, h.owner, h.repo, number, unpositioned(comment)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
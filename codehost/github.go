@@ -0,0 +1,96 @@
+package codehost
+
+import (
+	"context"
+	"fmt"
+	gogithub "github.com/google/go-github/v42/github"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/github"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"os"
+	"strings"
+)
+
+// githubHost reviews the pull requests of a GitHub repository.
+type githubHost struct {
+	client *gogithub.Client
+	owner  string
+	repo   string
+}
+
+// newGitHub creates the GitHub client of the repository with the configured token or GitHub App,
+// talking to github.com or the configured GitHub Enterprise Server.
+func newGitHub(ctx context.Context, cfg config.Config, remote git.Remote, policy retry.Policy) (*githubHost, error) {
+	if strings.Contains(remote.Owner, "/") {
+		return nil, fmt.Errorf("invalid GitHub repository %s: GitHub repositories are named owner/repo", remote.FullName())
+	}
+
+	creds := github.Credentials{
+		Token:          cfg.GithubToken,
+		AppID:          cfg.GithubAppID,
+		PrivateKey:     []byte(cfg.GithubAppPrivateKey),
+		InstallationID: cfg.GithubAppInstallationID,
+	}
+	if creds.AppID != 0 && len(creds.PrivateKey) == 0 && cfg.GithubAppPrivateKeyPath != "" {
+		key, err := os.ReadFile(cfg.GithubAppPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+		creds.PrivateKey = key
+	}
+
+	endpoint := github.Endpoint{BaseURL: cfg.GithubURL, UploadURL: cfg.GithubUploadURL}
+	if cfg.GithubCABundle != "" {
+		bundle, err := os.ReadFile(cfg.GithubCABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub CA bundle: %w", err)
+		}
+		endpoint.CABundle = bundle
+	}
+
+	client, err := github.NewClient(ctx, creds, endpoint, remote.Owner, remote.Repo, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &githubHost{client: client, owner: remote.Owner, repo: remote.Repo}, nil
+}
+
+// Changes fetches the files of the pull request and counts the files GitHub left out of the listing.
+func (h *githubHost) Changes(ctx context.Context, number int) (*Changes, error) {
+	files, total, err := github.GetPRChanges(ctx, h.client, h.owner, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := github.GetPRHeadSHA(ctx, h.client, h.owner, h.repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := total - len(files)
+	return &Changes{Files: github.FileDiffs(files), HeadSHA: head, Truncated: skipped > 0, SkippedFiles: skipped}, nil
+}
+
+// File fetches the file with the contents API.
+func (h *githubHost) File(ctx context.Context, ref, path string) (string, error) {
+	return github.GetFileContent(ctx, h.client, h.owner, h.repo, path, ref)
+}
+
+// Compare compares the commits with the compare API, whose status tells whether head is ahead of base.
+func (h *githubHost) Compare(ctx context.Context, base, head string) (*Changes, error) {
+	files, status, truncated, err := github.CompareCommits(ctx, h.client, h.owner, h.repo, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	if status != "ahead" && status != "identical" {
+		return nil, fmt.Errorf("%w: %s is %s of %s", ErrDiverged, head, status, base)
+	}
+	return &Changes{Files: github.FileDiffs(files), HeadSHA: head, Truncated: truncated}, nil
+}
+
+// SubmitReview submits the review as a single pull request review.
+func (h *githubHost) SubmitReview(ctx context.Context, number int, review types.Review) error {
+	return github.SubmitReview(ctx, h.client, h.owner, h.repo, number, review)
+}
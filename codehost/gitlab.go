@@ -0,0 +1,166 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/gitlab"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+)
+
+// gitlabHost reviews the merge requests of a GitLab project.
+type gitlabHost struct {
+	client  *gitlab.Client
+	project string
+}
+
+// newGitLab creates the GitLab client of the project. Without a configured GitLab URL the instance
+// serving the remote is used.
+func newGitLab(cfg config.Config, remote git.Remote, policy retry.Policy) *gitlabHost {
+	client := gitlab.NewClient(instanceURL(cfg.GitlabURL, remote), cfg.GitlabToken)
+	client.HTTPClient = retry.NewClient(policy)
+	return &gitlabHost{client: client, project: remote.FullName()}
+}
+
+// Changes fetches the diffs of the merge request. Files whose diff GitLab left out as too large are
+// counted as skipped.
+func (h *gitlabHost) Changes(ctx context.Context, number int) (*Changes, error) {
+	mr, err := h.client.MergeRequest(ctx, h.project, number)
+	if err != nil {
+		return nil, err
+	}
+	diffs, truncated, err := h.client.MergeRequestDiffs(ctx, h.project, number)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &Changes{HeadSHA: mr.DiffRefs.HeadSHA, Truncated: truncated}
+	var reviewed []gitlab.Diff
+	for _, diff := range diffs {
+		if diff.TooLarge {
+			changes.SkippedFiles++
+			continue
+		}
+		reviewed = append(reviewed, diff)
+	}
+	changes.Files = gitlab.FileDiffs(reviewed)
+	return changes, nil
+}
+
+// File fetches the raw file from the repository.
+func (h *gitlabHost) File(ctx context.Context, ref, path string) (string, error) {
+	return h.client.FileContent(ctx, h.project, path, ref)
+}
+
+// Compare checks with the merge base that base is an ancestor of head and compares the commits.
+func (h *gitlabHost) Compare(ctx context.Context, base, head string) (*Changes, error) {
+	mergeBase, err := h.client.MergeBase(ctx, h.project, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	if !sameCommit(mergeBase, base) {
+		return nil, fmt.Errorf("%w: %s is not an ancestor of %s", ErrDiverged, base, head)
+	}
+	comparison, err := h.client.Compare(ctx, h.project, base, head)
+	if err != nil {
+		return nil, compareError(err)
+	}
+	return &Changes{Files: gitlab.FileDiffs(comparison.Diffs), HeadSHA: head, Truncated: comparison.CompareTimeout}, nil
+}
+
+// SubmitReview posts the review body as a note and every comment as a discussion on its line of the diff
+// version of the reviewed commit, or of the latest version when the review names no commit. GitLab has no
+// review event to request changes through the REST API, so such reviews are marked in the note; approving
+// reviews also approve the merge request unless it moved on from the reviewed commit.
+func (h *gitlabHost) SubmitReview(ctx context.Context, number int, review types.Review) error {
+	mr, err := h.client.MergeRequest(ctx, h.project, number)
+	if err != nil {
+		return err
+	}
+	refs, positioned, moved := mr.DiffRefs, true, false
+	if review.CommitID != "" && !sameCommit(refs.HeadSHA, review.CommitID) {
+		moved = true
+		if refs, positioned, err = h.versionRefs(ctx, number, review.CommitID); err != nil {
+			return err
+		}
+	}
+
+	// Positions name the old path of renamed files
+	oldPaths := make(map[string]string)
+	if len(review.Comments) > 0 {
+		diffs, _, err := h.client.MergeRequestDiffs(ctx, h.project, number)
+		if err != nil {
+			return err
+		}
+		for _, diff := range diffs {
+			oldPaths[diff.NewPath] = diff.OldPath
+		}
+	}
+
+	body := review.Body
+	if review.Event == types.ReviewEventRequestChanges {
+		body = "**Changes requested.**\n\n" + body
+	}
+	if err := h.client.CreateNote(ctx, h.project, number, body); err != nil {
+		return err
+	}
+
+	for _, comment := range review.Comments {
+		if !positioned {
+			if err := h.client.CreateDiscussion(ctx, h.project, number, unpositioned(comment), nil); err != nil {
+				return err
+			}
+			continue
+		}
+		oldPath := oldPaths[comment.Path]
+		if oldPath == "" {
+			oldPath = comment.Path
+		}
+		position := &gitlab.Position{
+			PositionType: "text",
+			BaseSHA:      refs.BaseSHA,
+			StartSHA:     refs.StartSHA,
+			HeadSHA:      refs.HeadSHA,
+			OldPath:      oldPath,
+			NewPath:      comment.Path,
+		}
+		if comment.Side == types.SideLeft {
+			position.OldLine = comment.Line
+		} else {
+			position.NewLine = comment.Line
+		}
+		err := h.client.CreateDiscussion(ctx, h.project, number, comment.Body, position)
+		var apiErr *types.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			// GitLab rejects positions outside of the diff
+			err = h.client.CreateDiscussion(ctx, h.project, number, unpositioned(comment), nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if review.Event == types.ReviewEventApprove && !moved {
+		return h.client.Approve(ctx, h.project, number, mr.DiffRefs.HeadSHA)
+	}
+	return nil
+}
+
+// versionRefs returns the diff refs of the version of the merge request at the commit. Without such a
+// version, as after a force-push, comments cannot be positioned on the reviewed lines.
+func (h *gitlabHost) versionRefs(ctx context.Context, number int, commit string) (gitlab.DiffRefs, bool, error) {
+	versions, err := h.client.Versions(ctx, h.project, number)
+	if err != nil {
+		return gitlab.DiffRefs{}, false, err
+	}
+	for _, version := range versions {
+		if sameCommit(version.HeadCommitSHA, commit) {
+			return version.DiffRefs(), true, nil
+		}
+	}
+	return gitlab.DiffRefs{}, false, nil
+}
@@ -3,27 +3,234 @@ package config
 import (
 	"github.com/joho/godotenv"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/utils"
+	"time"
 )
 
+// Posting modes of a review.
+const (
+	// PostModeOff only prints the report.
+	PostModeOff = "off"
+	// PostModeReview submits a pull request review with inline comments.
+	PostModeReview = "review"
+	// PostModeSummary submits a pull request review with the summary only.
+	PostModeSummary = "summary"
+)
+
+// Config holds the settings of a review run. Credentials are only read from the environment; every
+// other setting can also be set in a config file.
 type Config struct {
-	OpenAIApiKey   string
-	OrganizationId string
-	ProjectId      string
-	GithubToken    string
+	OpenAIApiKey   string `yaml:"-"`
+	OrganizationId string `yaml:"-"`
+	ProjectId      string `yaml:"-"`
+	GithubToken    string `yaml:"-"`
+	GitlabToken    string `yaml:"-"`
+	// BitbucketToken is an access token, or an app password or password when BitbucketUsername is set
+	BitbucketToken string `yaml:"-"`
+	GiteaToken     string `yaml:"-"`
+	// GithubAppPrivateKey is the PEM encoded key of the GitHub App, an alternative to the key file
+	GithubAppPrivateKey string `yaml:"-"`
+	AnthropicApiKey     string `yaml:"-"`
+	AzureApiKey         string `yaml:"-"`
+	// WebhookSecret verifies the deliveries of the webhook server
+	WebhookSecret string `yaml:"-"`
+
+	// GitHub App authentication, used instead of GithubToken when the app ID is set. Without an
+	// installation ID the installation of the reviewed repository is looked up.
+	GithubAppID             int64  `yaml:"github_app_id"`
+	GithubAppPrivateKeyPath string `yaml:"github_app_private_key_path"`
+	GithubAppInstallationID int64  `yaml:"github_app_installation_id"`
+	// GithubURL points at a GitHub Enterprise Server instance; GithubCABundle is a PEM file of
+	// certificates trusted for its TLS connection
+	GithubURL       string `yaml:"github_url"`
+	GithubUploadURL string `yaml:"github_upload_url"`
+	GithubCABundle  string `yaml:"github_ca_bundle"`
+	// GitlabURL points at a self-managed GitLab instance, derived from the remote URL when empty
+	GitlabURL string `yaml:"gitlab_url"`
+	// BitbucketURL points at a Bitbucket Data Center instance and GiteaURL at a Gitea or Forgejo
+	// instance; both are derived from the remote URL when empty
+	BitbucketURL      string `yaml:"bitbucket_url"`
+	BitbucketUsername string `yaml:"bitbucket_username"`
+	GiteaURL          string `yaml:"gitea_url"`
+	// CodeHost selects the code host, see codehost.Detect; it is detected from the remote URL when empty
+	CodeHost string `yaml:"code_host"`
+	// Remote is the git remote whose URL names the reviewed repository
+	Remote string `yaml:"remote"`
+
+	Provider string `yaml:"provider"`
+	// Model overrides the provider specific model settings below.
+	Model       string  `yaml:"model"`
+	Temperature float64 `yaml:"temperature"`
+	// ContextWindow overrides the context window of the model in tokens, for models such as Azure
+	// deployments and Ollama models that the registry does not know; 0 uses the registry.
+	ContextWindow   int     `yaml:"context_window"`
+	OpenAIModel     string  `yaml:"openai_model"`
+	AnthropicModel  string  `yaml:"anthropic_model"`
+	AzureEndpoint   string  `yaml:"azure_endpoint"`
+	AzureDeployment string  `yaml:"azure_deployment"`
+	AzureApiVersion string  `yaml:"azure_api_version"`
+	OllamaURL       string  `yaml:"ollama_url"`
+	OllamaModel     string  `yaml:"ollama_model"`
+	Prompts         Prompts `yaml:"prompts"`
+	// Context selects the surrounding code sent with each hunk
+	Context Context `yaml:"context"`
+
+	// Include and Exclude select the reviewed files by glob pattern
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// SeverityThreshold drops findings below this severity from the report
+	SeverityThreshold string `yaml:"severity_threshold"`
+	// MaxComments caps the inline comments of a posted review, 0 means no limit
+	MaxComments int    `yaml:"max_comments"`
+	PostMode    string `yaml:"post_mode"`
+	// FailOn is the lowest severity of findings that makes the git hooks fail
+	FailOn string `yaml:"fail_on"`
+	// Severity rules deciding the submitted review event
+	RequestChangesAt string `yaml:"request_changes_at"`
+	ApproveBelow     string `yaml:"approve_below"`
+
+	// Prices maps model names to their price, overriding the built-in prices; a name also matches the
+	// models it is a prefix of, e.g. dated snapshots
+	Prices map[string]Price `yaml:"prices"`
+	// MaxCost and MaxTokensTotal cap the spending of a review run in US dollars and tokens, 0 means no limit
+	MaxCost        float64 `yaml:"max_cost"`
+	MaxTokensTotal int     `yaml:"max_tokens_total"`
+	// Cache stores the answers of the model to skip unchanged hunks on later runs
+	Cache Cache `yaml:"cache"`
+	// Incremental reviews only the commits pushed since the last review of a pull request, whose head is
+	// recorded in StateDir; empty uses $XDG_STATE_HOME/pr-reviewer or ~/.local/state/pr-reviewer
+	Incremental bool   `yaml:"incremental"`
+	StateDir    string `yaml:"state_dir"`
+
+	Concurrency   int           `yaml:"concurrency"`
+	Timeout       time.Duration `yaml:"timeout"`
+	MaxRetries    int           `yaml:"max_retries"`
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay"`
 }
 
-var Envs = initConfig()
+// Prompts overrides the prompts sent to the model.
+type Prompts struct {
+	// System replaces the default review instructions.
+	System string `yaml:"system"`
+	// Review is a text/template rendering a single hunk, with the fields .Path, .Code and .Context.
+	Review string `yaml:"review"`
+}
 
-func initConfig() Config {
+// Price is the price of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
 
-	// Load env variables
-	godotenv.Load()
+// Cache configures the on-disk cache of model answers.
+type Cache struct {
+	Enabled bool `yaml:"enabled"`
+	// Dir is the cache directory; empty uses $XDG_CACHE_HOME/pr-reviewer or ~/.cache/pr-reviewer.
+	Dir string `yaml:"dir"`
+	// TTL is how long answers are reused; 0 keeps them until they are evicted.
+	TTL time.Duration `yaml:"ttl"`
+	// MaxSizeMB caps the size of the directory, evicting the oldest answers first; 0 means no limit.
+	MaxSizeMB int `yaml:"max_size_mb"`
+}
 
+// Context selects the code of the reviewed version of a file that is sent along with each hunk.
+type Context struct {
+	// Lines is the number of lines shown before and after the hunk.
+	Lines int `yaml:"lines"`
+	// Enclosing adds the function or type declaration enclosing the hunk.
+	Enclosing bool `yaml:"enclosing"`
+	// Imports adds the import block of the file.
+	Imports bool `yaml:"imports"`
+	// MaxTokens caps the estimated tokens of the context of a hunk; 0 sends no context.
+	MaxTokens int `yaml:"max_tokens"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
 	return Config{
+		Remote:        "origin",
+		Provider:      "openai",
+		Temperature:   0.2,
+		OpenAIModel:   "gpt-4o",
+		Context:       Context{Lines: 10, Enclosing: true, Imports: true, MaxTokens: 2000},
+		PostMode:      PostModeOff,
+		Cache:         Cache{Enabled: true, TTL: 7 * 24 * time.Hour, MaxSizeMB: 100},
+		FailOn:        "major",
+		Concurrency:   4,
+		MaxRetries:    3,
+		RetryMaxDelay: time.Minute,
+	}
+}
+
+// Load builds the configuration of the repository in dir. Settings are applied in increasing order
+// of precedence: the defaults, the user config file, the repository config file and the environment.
+// Command line flags are applied on top by the caller.
+func Load(dir string) (Config, error) {
+	// Load env variables
+	godotenv.Load()
 
-		OpenAIApiKey:   utils.GetEnv("OPENAI_API_KEY", ""),
-		OrganizationId: utils.GetEnv("ORGANIZATION_ID", ""),
-		ProjectId:      utils.GetEnv("PROJECT_ID", ""),
-		GithubToken:    utils.GetEnv("GITHUB_TOKEN", ""),
+	cfg := Default()
+	for _, path := range Files(dir) {
+		if err := LoadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
 	}
+	applyEnv(&cfg)
+	return cfg, nil
+}
+
+// applyEnv overrides the configuration with the environment variables that are set.
+func applyEnv(cfg *Config) {
+	cfg.OpenAIApiKey = utils.GetEnv("OPENAI_API_KEY", cfg.OpenAIApiKey)
+	cfg.OrganizationId = utils.GetEnv("ORGANIZATION_ID", cfg.OrganizationId)
+	cfg.ProjectId = utils.GetEnv("PROJECT_ID", cfg.ProjectId)
+	cfg.GithubToken = utils.GetEnv("GITHUB_TOKEN", cfg.GithubToken)
+	cfg.GithubAppID = utils.GetEnvAsInt("GITHUB_APP_ID", cfg.GithubAppID)
+	cfg.GithubAppPrivateKey = utils.GetEnv("GITHUB_APP_PRIVATE_KEY", cfg.GithubAppPrivateKey)
+	cfg.GithubAppPrivateKeyPath = utils.GetEnv("GITHUB_APP_PRIVATE_KEY_PATH", cfg.GithubAppPrivateKeyPath)
+	cfg.GithubAppInstallationID = utils.GetEnvAsInt("GITHUB_APP_INSTALLATION_ID", cfg.GithubAppInstallationID)
+	cfg.GithubURL = utils.GetEnv("GITHUB_URL", cfg.GithubURL)
+	cfg.GithubUploadURL = utils.GetEnv("GITHUB_UPLOAD_URL", cfg.GithubUploadURL)
+	cfg.GithubCABundle = utils.GetEnv("GITHUB_CA_BUNDLE", cfg.GithubCABundle)
+	cfg.GitlabToken = utils.GetEnv("GITLAB_TOKEN", cfg.GitlabToken)
+	cfg.GitlabURL = utils.GetEnv("GITLAB_URL", cfg.GitlabURL)
+	cfg.BitbucketToken = utils.GetEnv("BITBUCKET_TOKEN", cfg.BitbucketToken)
+	cfg.BitbucketUsername = utils.GetEnv("BITBUCKET_USERNAME", cfg.BitbucketUsername)
+	cfg.BitbucketURL = utils.GetEnv("BITBUCKET_URL", cfg.BitbucketURL)
+	cfg.GiteaToken = utils.GetEnv("GITEA_TOKEN", cfg.GiteaToken)
+	cfg.GiteaURL = utils.GetEnv("GITEA_URL", cfg.GiteaURL)
+	cfg.CodeHost = utils.GetEnv("REVIEW_CODE_HOST", cfg.CodeHost)
+	cfg.Remote = utils.GetEnv("REVIEW_REMOTE", cfg.Remote)
+	cfg.AnthropicApiKey = utils.GetEnv("ANTHROPIC_API_KEY", cfg.AnthropicApiKey)
+	cfg.AzureApiKey = utils.GetEnv("AZURE_OPENAI_API_KEY", cfg.AzureApiKey)
+	cfg.WebhookSecret = utils.GetEnv("GITHUB_WEBHOOK_SECRET", cfg.WebhookSecret)
+
+	cfg.Provider = utils.GetEnv("LLM_PROVIDER", cfg.Provider)
+	cfg.Model = utils.GetEnv("REVIEW_MODEL", cfg.Model)
+	cfg.Temperature = utils.GetEnvAsFloat("OPENAI_TEMPERATURE", cfg.Temperature)
+	cfg.ContextWindow = int(utils.GetEnvAsInt("REVIEW_CONTEXT_WINDOW", int64(cfg.ContextWindow)))
+	cfg.OpenAIModel = utils.GetEnv("OPENAI_MODEL", cfg.OpenAIModel)
+	cfg.AnthropicModel = utils.GetEnv("ANTHROPIC_MODEL", cfg.AnthropicModel)
+	cfg.AzureEndpoint = utils.GetEnv("AZURE_OPENAI_ENDPOINT", cfg.AzureEndpoint)
+	cfg.AzureDeployment = utils.GetEnv("AZURE_OPENAI_DEPLOYMENT", cfg.AzureDeployment)
+	cfg.AzureApiVersion = utils.GetEnv("AZURE_OPENAI_API_VERSION", cfg.AzureApiVersion)
+	cfg.OllamaURL = utils.GetEnv("OLLAMA_URL", cfg.OllamaURL)
+	cfg.OllamaModel = utils.GetEnv("OLLAMA_MODEL", cfg.OllamaModel)
+
+	cfg.Context.Lines = int(utils.GetEnvAsInt("REVIEW_CONTEXT_LINES", int64(cfg.Context.Lines)))
+	cfg.Context.MaxTokens = int(utils.GetEnvAsInt("REVIEW_CONTEXT_MAX_TOKENS", int64(cfg.Context.MaxTokens)))
+
+	cfg.Include = utils.GetEnvAsList("REVIEW_INCLUDE", cfg.Include)
+	cfg.Exclude = utils.GetEnvAsList("REVIEW_EXCLUDE", cfg.Exclude)
+	cfg.SeverityThreshold = utils.GetEnv("REVIEW_SEVERITY_THRESHOLD", cfg.SeverityThreshold)
+	cfg.MaxComments = int(utils.GetEnvAsInt("REVIEW_MAX_COMMENTS", int64(cfg.MaxComments)))
+	cfg.MaxCost = utils.GetEnvAsFloat("REVIEW_MAX_COST", cfg.MaxCost)
+	cfg.MaxTokensTotal = int(utils.GetEnvAsInt("REVIEW_MAX_TOKENS_TOTAL", int64(cfg.MaxTokensTotal)))
+	cfg.Cache.Dir = utils.GetEnv("REVIEW_CACHE_DIR", cfg.Cache.Dir)
+	cfg.StateDir = utils.GetEnv("REVIEW_STATE_DIR", cfg.StateDir)
+	cfg.PostMode = utils.GetEnv("REVIEW_POST_MODE", cfg.PostMode)
+	cfg.FailOn = utils.GetEnv("REVIEW_FAIL_ON", cfg.FailOn)
+	cfg.RequestChangesAt = utils.GetEnv("REVIEW_REQUEST_CHANGES_AT", cfg.RequestChangesAt)
+	cfg.ApproveBelow = utils.GetEnv("REVIEW_APPROVE_BELOW", cfg.ApproveBelow)
+	cfg.Concurrency = int(utils.GetEnvAsInt("REVIEW_CONCURRENCY", int64(cfg.Concurrency)))
+	cfg.MaxRetries = int(utils.GetEnvAsInt("REVIEW_MAX_RETRIES", int64(cfg.MaxRetries)))
 }
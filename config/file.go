@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RepoFileNames are the names of the config file looked up in the repository root, in order.
+var RepoFileNames = []string{".prreviewer.yml", ".prreviewer.yaml"}
+
+// UserFile returns the path of the user config file, $XDG_CONFIG_HOME/pr-reviewer/config.yaml or
+// ~/.config/pr-reviewer/config.yaml. It returns an empty string when no home directory is known.
+func UserFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "pr-reviewer", "config.yaml")
+}
+
+// CacheDir returns the directory of the answer cache, dir if set, otherwise $XDG_CACHE_HOME/pr-reviewer
+// or ~/.cache/pr-reviewer. It returns an empty string when no home directory is known.
+func CacheDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	dir = os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "pr-reviewer")
+}
+
+// StateDir returns the directory of the review state, dir if set, otherwise $XDG_STATE_HOME/pr-reviewer
+// or ~/.local/state/pr-reviewer. It returns an empty string when no home directory is known.
+func StateDir(dir string) string {
+	if dir != "" {
+		return dir
+	}
+	dir = os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "pr-reviewer")
+}
+
+// RepoFile returns the path of the config file in the repository root dir, or an empty string if
+// the repository has none.
+func RepoFile(dir string) string {
+	for _, name := range RepoFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Files returns the existing config files applying to the repository in dir, lowest precedence first.
+func Files(dir string) []string {
+	var files []string
+	if path := UserFile(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	if path := RepoFile(dir); path != "" {
+		files = append(files, path)
+	}
+	return files
+}
+
+// LoadFile applies the settings of the YAML file at path on top of cfg. Keys missing from the file
+// keep their current value; unknown keys are reported as errors.
+func LoadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes a config file for the test, creating its directory.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLoadPrecedence tests that the repository file overrides the user file and the environment
+// overrides both.
+func TestLoadPrecedence(t *testing.T) {
+	home := t.TempDir()
+	repo := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("REVIEW_CONCURRENCY", "2")
+	os.Unsetenv("LLM_PROVIDER")
+	os.Unsetenv("REVIEW_MODEL")
+	os.Unsetenv("REVIEW_MAX_COMMENTS")
+
+	writeFile(t, filepath.Join(home, "pr-reviewer", "config.yaml"), `
+provider: anthropic
+model: claude-sonnet-4-0
+max_comments: 5
+concurrency: 8
+`)
+	writeFile(t, filepath.Join(repo, ".prreviewer.yml"), `
+model: claude-opus-4-1
+exclude: ["vendor/**", "*_test.go"]
+prompts:
+  review: "File {{.Path}}:\n{{.Code}}"
+context:
+  lines: 5
+timeout: 5m
+`)
+
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Provider != "anthropic" || cfg.MaxComments != 5 {
+		t.Errorf("Expected the user file settings, got %+v", cfg)
+	}
+	if cfg.Model != "claude-opus-4-1" || len(cfg.Exclude) != 2 || cfg.Prompts.Review == "" || cfg.Timeout != 5*time.Minute {
+		t.Errorf("Expected the repository file settings, got %+v", cfg)
+	}
+	if cfg.Concurrency != 2 {
+		t.Errorf("Expected the environment to override the files, got concurrency %d", cfg.Concurrency)
+	}
+	if cfg.Context.Lines != 5 || !cfg.Context.Enclosing || cfg.Context.MaxTokens != 2000 {
+		t.Errorf("Expected the context lines of the repository file and the default budget, got %+v", cfg.Context)
+	}
+	if cfg.Temperature != 0.2 || cfg.PostMode != PostModeOff {
+		t.Errorf("Expected defaults for unset keys, got %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a valid configuration, got %v", err)
+	}
+}
+
+// TestLoadFileUnknownKey tests that misspelled keys are reported with their line.
+func TestLoadFileUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".prreviewer.yml")
+	writeFile(t, path, "provider: openai\nmax_coments: 3\n")
+
+	cfg := Default()
+	err := LoadFile(path, &cfg)
+	if err == nil || !strings.Contains(err.Error(), "line 2: field max_coments not found") {
+		t.Errorf("Expected an unknown key error, got %v", err)
+	}
+
+	// Credentials can only be set in the environment
+	writeFile(t, path, "github_token: secret\n")
+	if err := LoadFile(path, &cfg); err == nil {
+		t.Error("Expected an error for a credential in the config file")
+	}
+
+	// An empty file changes nothing
+	writeFile(t, path, "")
+	if err := LoadFile(path, &cfg); err != nil || cfg.Provider != "openai" {
+		t.Errorf("Expected an empty file to be accepted, got %v", err)
+	}
+}
+
+// TestValidate tests that every bad value is reported.
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Provider = "bard"
+	cfg.SeverityThreshold = "blocker"
+	cfg.Exclude = []string{"[a-"}
+	cfg.PostMode = "always"
+	cfg.Concurrency = 0
+	cfg.Prompts.Review = "{{.Path"
+	cfg.Context.MaxTokens = -1
+	cfg.MaxCost = -1
+	cfg.Cache.TTL = -time.Hour
+	cfg.Prices = map[string]Price{"gpt-4o": {Input: -2.5}}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors, got none")
+	}
+	for _, key := range []string{"provider:", "severity_threshold:", "exclude:", "post_mode:", "concurrency:", "prompts.review:", "context.max_tokens:", "max_cost:", "prices.gpt-4o:", "cache.ttl:"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected an error for %s, got:\n%v", key, err)
+		}
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Expected the defaults to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/utils"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// providers lists the LLM providers understood by llm.NewClient.
+var providers = map[string]bool{"openai": true, "azure": true, "anthropic": true, "ollama": true}
+
+// codeHosts lists the code hosts understood by codehost.New.
+var codeHosts = map[string]bool{"github": true, "gitlab": true, "bitbucket": true, "bitbucket-server": true, "gitea": true, "forgejo": true}
+
+// postModes lists the accepted posting modes.
+var postModes = map[string]bool{PostModeOff: true, PostModeReview: true, PostModeSummary: true}
+
+// Validate checks the values of the configuration and returns every problem found, joined into
+// a single error.
+func (c Config) Validate() error {
+	var errs []error
+	if !providers[strings.ToLower(c.Provider)] {
+		errs = append(errs, fmt.Errorf("provider: unknown provider %q, expected openai, azure, anthropic or ollama", c.Provider))
+	}
+	if c.GithubAppID != 0 && c.GithubAppPrivateKey == "" && c.GithubAppPrivateKeyPath == "" {
+		errs = append(errs, fmt.Errorf("github_app_private_key_path: a private key file or GITHUB_APP_PRIVATE_KEY is required with github_app_id"))
+	}
+	urls := []struct{ key, value string }{
+		{"github_url", c.GithubURL}, {"github_upload_url", c.GithubUploadURL}, {"gitlab_url", c.GitlabURL},
+		{"bitbucket_url", c.BitbucketURL}, {"gitea_url", c.GiteaURL},
+	}
+	for _, setting := range urls {
+		if setting.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(setting.value); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s: %q is not an http(s) URL", setting.key, setting.value))
+		}
+	}
+	if c.CodeHost != "" && !codeHosts[strings.ToLower(c.CodeHost)] {
+		errs = append(errs, fmt.Errorf("code_host: unknown code host %q, expected github, gitlab, bitbucket, bitbucket-server, gitea or forgejo", c.CodeHost))
+	}
+	if strings.TrimSpace(c.Remote) == "" {
+		errs = append(errs, fmt.Errorf("remote: the name of a git remote is required"))
+	}
+	if c.Temperature < 0 || c.Temperature > 2 {
+		errs = append(errs, fmt.Errorf("temperature: %v is outside of the range 0-2", c.Temperature))
+	}
+	if c.Prompts.Review != "" {
+		if _, err := template.New("review").Parse(c.Prompts.Review); err != nil {
+			errs = append(errs, fmt.Errorf("prompts.review: %w", err))
+		}
+	}
+	if c.Context.Lines < 0 {
+		errs = append(errs, fmt.Errorf("context.lines: must not be negative"))
+	}
+	if c.Context.MaxTokens < 0 {
+		errs = append(errs, fmt.Errorf("context.max_tokens: must not be negative"))
+	}
+
+	for _, pattern := range c.Include {
+		if _, err := utils.MatchGlob(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("include: %w", err))
+		}
+	}
+	for _, pattern := range c.Exclude {
+		if _, err := utils.MatchGlob(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("exclude: %w", err))
+		}
+	}
+
+	severities := []struct {
+		key   string
+		value string
+	}{
+		{"severity_threshold", c.SeverityThreshold},
+		{"fail_on", c.FailOn},
+		{"request_changes_at", c.RequestChangesAt},
+		{"approve_below", c.ApproveBelow},
+	}
+	for _, severity := range severities {
+		if _, ok := types.ParseSeverity(severity.value); severity.value != "" && !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown severity %q, expected info, minor, major or critical", severity.key, severity.value))
+		}
+	}
+
+	if c.MaxComments < 0 {
+		errs = append(errs, fmt.Errorf("max_comments: must not be negative"))
+	}
+	if !postModes[c.PostMode] {
+		errs = append(errs, fmt.Errorf("post_mode: unknown mode %q, expected off, review or summary", c.PostMode))
+	}
+	models := make([]string, 0, len(c.Prices))
+	for model := range c.Prices {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	for _, model := range models {
+		if price := c.Prices[model]; price.Input < 0 || price.Output < 0 {
+			errs = append(errs, fmt.Errorf("prices.%s: must not be negative", model))
+		}
+	}
+	if c.MaxCost < 0 {
+		errs = append(errs, fmt.Errorf("max_cost: must not be negative"))
+	}
+	if c.MaxTokensTotal < 0 {
+		errs = append(errs, fmt.Errorf("max_tokens_total: must not be negative"))
+	}
+	if c.Cache.TTL < 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl: must not be negative"))
+	}
+	if c.Cache.MaxSizeMB < 0 {
+		errs = append(errs, fmt.Errorf("cache.max_size_mb: must not be negative"))
+	}
+	if c.ContextWindow < 0 {
+		errs = append(errs, fmt.Errorf("context_window: must not be negative"))
+	}
+	if c.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("concurrency: must be at least 1"))
+	}
+	if c.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("timeout: must not be negative"))
+	}
+	if c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("max_retries: must not be negative"))
+	}
+	if c.RetryMaxDelay < 0 {
+		errs = append(errs, fmt.Errorf("retry_max_delay: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Op is the kind of a diff line, written as its prefix in a unified diff.
+type Op byte
+
+// Kinds of diff lines.
+const (
+	Context Op = ' '
+	Add     Op = '+'
+	Delete  Op = '-'
+)
+
+// Line is a line of a hunk with its numbers in the old and the new version of the file. Added lines
+// have no old number and deleted lines no new number.
+type Line struct {
+	Op      Op
+	Text    string
+	OldLine int
+	NewLine int
+	// NoNewline reports that the line ends its version of the file without a trailing newline, which
+	// the diff marks with "\ No newline at end of file".
+	NoNewline bool
+}
+
+// Hunk is a hunk of a unified diff. A range without a count in the header, as in "@@ -1 +1 @@", has a
+// single line; empty ranges have a count of zero and start at the line before the change.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the text after the header, usually the enclosing function.
+	Section string
+	Lines   []Line
+}
+
+// Block is a run of added and deleted lines between context lines. NewStart is the line of the new
+// version of the file the block starts at; for a block that only deletes lines it is the line following
+// the deletion.
+type Block struct {
+	NewStart int
+	Lines    []Line
+}
+
+// hunkHeader matches "@@ -a,b +c,d @@ section", where the counts are optional.
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// Parse parses the hunks of a unified diff of a single file. Lines before the first hunk header, such
+// as the file headers of git diff, are skipped; GitHub patches start with the first hunk. Hunks may end
+// early, as in truncated patches, but lines beyond the counts of their header are an error.
+func Parse(patch string) ([]Hunk, error) {
+	var hunks []Hunk
+	var hunk *Hunk
+	oldLine, newLine, oldLeft, newLeft := 0, 0, 0, 0
+
+	for _, text := range strings.Split(strings.TrimSuffix(patch, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			h, err := ParseHunkHeader(text)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, h)
+			hunk = &hunks[len(hunks)-1]
+			oldLine, newLine, oldLeft, newLeft = h.OldStart, h.NewStart, h.OldLines, h.NewLines
+		case hunk == nil:
+			continue
+		case strings.HasPrefix(text, `\`):
+			// "\ No newline at end of file" refers to the previous line
+			if len(hunk.Lines) > 0 {
+				hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+			}
+		case oldLeft == 0 && newLeft == 0:
+			if text != "" {
+				return nil, fmt.Errorf("unexpected line after hunk at line %d: %q", hunk.NewStart, text)
+			}
+		default:
+			// Some tools strip the space of empty context lines
+			op, content := Context, ""
+			if text != "" {
+				op, content = Op(text[0]), text[1:]
+			}
+			switch {
+			case op == Context && oldLeft > 0 && newLeft > 0:
+				hunk.Lines = append(hunk.Lines, Line{Op: Context, Text: content, OldLine: oldLine, NewLine: newLine})
+				oldLine, newLine, oldLeft, newLeft = oldLine+1, newLine+1, oldLeft-1, newLeft-1
+			case op == Add && newLeft > 0:
+				hunk.Lines = append(hunk.Lines, Line{Op: Add, Text: content, NewLine: newLine})
+				newLine, newLeft = newLine+1, newLeft-1
+			case op == Delete && oldLeft > 0:
+				hunk.Lines = append(hunk.Lines, Line{Op: Delete, Text: content, OldLine: oldLine})
+				oldLine, oldLeft = oldLine+1, oldLeft-1
+			default:
+				return nil, fmt.Errorf("unexpected line in hunk at line %d: %q", hunk.NewStart, text)
+			}
+		}
+	}
+	return hunks, nil
+}
+
+// ParseHunkHeader parses a hunk header line such as "@@ -1,2 +1,3 @@ func main() {".
+func ParseHunkHeader(line string) (Hunk, error) {
+	matches := hunkHeader.FindStringSubmatch(line)
+	if matches == nil {
+		return Hunk{}, fmt.Errorf("invalid hunk header %q", line)
+	}
+
+	number := func(text string) int {
+		if text == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(text)
+		return n
+	}
+	return Hunk{
+		OldStart: number(matches[1]),
+		OldLines: number(matches[2]),
+		NewStart: number(matches[3]),
+		NewLines: number(matches[4]),
+		Section:  matches[5],
+	}, nil
+}
+
+// Blocks returns the runs of changed lines of the hunks in order.
+func Blocks(hunks []Hunk) []Block {
+	var blocks []Block
+	for _, hunk := range hunks {
+		// next is the number of the next line of the new version; an empty new range starts after NewStart
+		next := hunk.NewStart
+		if hunk.NewLines == 0 {
+			next++
+		}
+		block := Block{}
+		for _, line := range hunk.Lines {
+			if line.Op == Context {
+				if len(block.Lines) > 0 {
+					blocks = append(blocks, block)
+					block = Block{}
+				}
+				next = line.NewLine + 1
+				continue
+			}
+			if len(block.Lines) == 0 {
+				block.NewStart = next
+			}
+			if line.Op == Add {
+				next = line.NewLine + 1
+			}
+			block.Lines = append(block.Lines, line)
+		}
+		if len(block.Lines) > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// String renders the hunk as a unified diff hunk, starting with its header. Parsing the result returns
+// the hunk again.
+func (h Hunk) String() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Section != "" {
+		text.WriteString(" " + h.Section)
+	}
+	for _, line := range h.Lines {
+		text.WriteString("\n" + string(line.Op) + line.Text)
+		if line.NoNewline {
+			text.WriteString("\n\\ No newline at end of file")
+		}
+	}
+	return text.String()
+}
+
+// NewRange returns the first and last added line of the block in the new version of the file, or
+// zeros if the block only deletes lines.
+func (b Block) NewRange() (int, int) {
+	return lineRange(b.Lines, func(line Line) int { return line.NewLine })
+}
+
+// OldRange returns the first and last deleted line of the block in the old version of the file, or
+// zeros if the block only adds lines.
+func (b Block) OldRange() (int, int) {
+	return lineRange(b.Lines, func(line Line) int { return line.OldLine })
+}
+
+// String renders the block as unified diff lines.
+func (b Block) String() string {
+	lines := make([]string, 0, len(b.Lines))
+	for _, line := range b.Lines {
+		lines = append(lines, string(line.Op)+line.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineRange returns the first and last non-zero number of the lines.
+func lineRange(lines []Line, number func(Line) int) (int, int) {
+	first, last := 0, 0
+	for _, line := range lines {
+		if n := number(line); n != 0 {
+			if first == 0 {
+				first = n
+			}
+			last = n
+		}
+	}
+	return first, last
+}
@@ -0,0 +1,179 @@
+package diff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestParse tests hunk headers without counts, new files, deletions and missing trailing newlines.
+func TestParse(t *testing.T) {
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1,2 @@ package main
+-old
++new
++more
+@@ -10,3 +11,2 @@ func main() {
+ keep
+-removed
+ last
+\ No newline at end of file
+`
+	hunks, err := Parse(patch)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(hunks))
+	}
+	if h := hunks[0]; h.OldStart != 1 || h.OldLines != 1 || h.NewStart != 1 || h.NewLines != 2 || h.Section != "package main" {
+		t.Errorf("Unexpected first hunk %+v", h)
+	}
+
+	expected := []Line{
+		{Op: Context, Text: "keep", OldLine: 10, NewLine: 11},
+		{Op: Delete, Text: "removed", OldLine: 11},
+		{Op: Context, Text: "last", OldLine: 12, NewLine: 12, NoNewline: true},
+	}
+	for i, line := range hunks[1].Lines {
+		if line != expected[i] {
+			t.Errorf("Line %d: expected %+v, got %+v", i, expected[i], line)
+		}
+	}
+
+	// Rendered hunks parse into the same hunks
+	for _, hunk := range hunks {
+		again, err := Parse(hunk.String())
+		if err != nil || len(again) != 1 || again[0].String() != hunk.String() || len(again[0].Lines) != len(hunk.Lines) {
+			t.Errorf("Expected %q to parse into the same hunk, got %+v (%v)", hunk.String(), again, err)
+		}
+	}
+
+	hunks, err = Parse("@@ -0,0 +1 @@\n+only line")
+	if err != nil || len(hunks) != 1 || hunks[0].Lines[0].NewLine != 1 {
+		t.Errorf("Expected a new file with line 1, got %+v (%v)", hunks, err)
+	}
+
+	for _, invalid := range []string{"@@ -1 +1 @@\n+a\n+b", "@@ -x +1 @@\n+a", "@@ -1 +1 @@\n*a"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+// TestBlocks tests splitting hunks into runs of changed lines with their ranges.
+func TestBlocks(t *testing.T) {
+	hunks, err := Parse("@@ -10,4 +10,4 @@\n-old()\n+first()\n+second()\n ctx\n-removed()\n ctx")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	blocks := Blocks(hunks)
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+
+	if start, end := blocks[0].NewRange(); start != 10 || end != 11 {
+		t.Errorf("Expected new lines 10-11, got %d-%d", start, end)
+	}
+	if blocks[0].String() != "-old()\n+first()\n+second()" {
+		t.Errorf("Unexpected block %q", blocks[0].String())
+	}
+	if start, end := blocks[1].NewRange(); start != 0 || end != 0 {
+		t.Errorf("Expected no new lines for a deletion, got %d-%d", start, end)
+	}
+	if start, end := blocks[1].OldRange(); start != 12 || end != 12 {
+		t.Errorf("Expected old line 12, got %d-%d", start, end)
+	}
+	if blocks[0].NewStart != 10 || blocks[1].NewStart != 13 {
+		t.Errorf("Expected the blocks to start at new lines 10 and 13, got %d and %d", blocks[0].NewStart, blocks[1].NewStart)
+	}
+
+	blocks = Blocks([]Hunk{{OldStart: 3, OldLines: 1, NewStart: 2, NewLines: 0, Lines: []Line{{Op: Delete, Text: "gone", OldLine: 3}}}})
+	if len(blocks) != 1 || blocks[0].NewStart != 3 {
+		t.Errorf("Expected a deletion of a whole hunk to start at new line 3, got %+v", blocks)
+	}
+}
+
+// FuzzParse tests the parser against diffs generated by git: every line must carry the numbers of
+// its text in the old and new file, and applying the hunks to the old file must give the new file.
+func FuzzParse(f *testing.F) {
+	if _, err := exec.LookPath("git"); err != nil {
+		f.Skip("git is not installed")
+	}
+	f.Add("a\nb\nc\n", "a\nB\nc\n", uint8(3))
+	f.Add("", "new\n", uint8(3))
+	f.Add("old\n", "", uint8(0))
+	f.Add("a\nb", "a\nb\nc", uint8(1))
+	f.Add("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n", "one\nthree\nfour\nfive\nsix\nseven\n8\n", uint8(1))
+	f.Add("x\n\ny\n", "x\n\n\ny", uint8(2))
+
+	f.Fuzz(func(t *testing.T, old, new string, context uint8) {
+		if strings.ContainsRune(old+new, 0) {
+			t.Skip()
+		}
+		dir := t.TempDir()
+		oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+		if err := os.WriteFile(oldPath, []byte(old), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(newPath, []byte(new), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command("git", "diff", "--no-index", "--no-color", "--no-ext-diff", "--text", "-U"+strconv.Itoa(int(context%6)), oldPath, newPath)
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull)
+		output, err := cmd.Output()
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			t.Fatal(err)
+		}
+
+		hunks, err := Parse(string(output))
+		if err != nil {
+			t.Fatalf("Failed to parse git diff:\n%s\n%v", output, err)
+		}
+
+		oldLines, newLines := fileLines(old), fileLines(new)
+		var applied []string
+		next := 1
+		for _, hunk := range hunks {
+			// Copy the unchanged lines before the hunk; an empty old range starts after OldStart
+			start := hunk.OldStart
+			if hunk.OldLines > 0 {
+				start--
+			}
+			applied = append(applied, oldLines[next-1:start]...)
+			next = start + 1
+			for _, line := range hunk.Lines {
+				if line.Op != Add {
+					if line.OldLine < 1 || line.OldLine > len(oldLines) || oldLines[line.OldLine-1] != line.Text {
+						t.Fatalf("Old line %d is not %q in:\n%s", line.OldLine, line.Text, output)
+					}
+					next = line.OldLine + 1
+				}
+				if line.Op != Delete {
+					if line.NewLine < 1 || line.NewLine > len(newLines) || newLines[line.NewLine-1] != line.Text {
+						t.Fatalf("New line %d is not %q in:\n%s", line.NewLine, line.Text, output)
+					}
+					applied = append(applied, line.Text)
+				}
+			}
+		}
+		applied = append(applied, oldLines[next-1:]...)
+		if strings.Join(applied, "\n") != strings.Join(newLines, "\n") {
+			t.Fatalf("Applying the hunks gives %q instead of %q:\n%s", applied, newLines, output)
+		}
+	})
+}
+
+// fileLines splits file content into lines without their newlines.
+func fileLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
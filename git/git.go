@@ -0,0 +1,194 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DiffOptions selects the local changes to review. At most one of Base, Staged, Commit and Range may
+// be set; without any of them the uncommitted changes against HEAD are diffed.
+type DiffOptions struct {
+	// Base diffs the working tree against the merge base of HEAD and this branch.
+	Base string
+	// Staged diffs the index against HEAD.
+	Staged bool
+	// Commit diffs a single commit against its parent.
+	Commit string
+	// Range diffs a revision range such as main..feature or main...feature.
+	Range string
+}
+
+// diffFlags make the output independent of the user's git configuration.
+var diffFlags = []string{"--no-color", "--no-ext-diff", "--unified=3", "--src-prefix=a/", "--dst-prefix=b/"}
+
+// Validate reports conflicting options.
+func (o DiffOptions) Validate() error {
+	set := 0
+	for _, value := range []bool{o.Base != "", o.Staged, o.Commit != "", o.Range != ""} {
+		if value {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("only one of base, staged, commit and range can be set")
+	}
+	return nil
+}
+
+// String describes the diff as the git command a user would run.
+func (o DiffOptions) String() string {
+	switch {
+	case o.Base != "":
+		return "git diff --merge-base " + o.Base
+	case o.Staged:
+		return "git diff --staged"
+	case o.Commit != "":
+		return "git show " + o.Commit
+	case o.Range != "":
+		return "git diff " + o.Range
+	}
+	return "git diff HEAD"
+}
+
+// Diff builds the diff selected by the options in the repository in dir and splits it per file.
+func Diff(ctx context.Context, dir string, opts DiffOptions) ([]types.FileDiff, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	switch {
+	case opts.Base != "":
+		base, err := Run(ctx, dir, "merge-base", opts.Base, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		args = append([]string{"diff"}, append(diffFlags, strings.TrimSpace(base))...)
+	case opts.Staged:
+		args = append([]string{"diff", "--cached"}, diffFlags...)
+	case opts.Commit != "":
+		args = append([]string{"show", "--format="}, append(diffFlags, opts.Commit)...)
+	case opts.Range != "":
+		args = append([]string{"diff"}, append(diffFlags, opts.Range)...)
+	default:
+		args = append([]string{"diff"}, append(diffFlags, "HEAD")...)
+	}
+
+	out, err := Run(ctx, dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiff(out), nil
+}
+
+// FileContent returns the content of the file at path, relative to the repository root, in the new
+// version of the diff selected by the options: the working tree, the index, the commit or the end of
+// the range.
+func FileContent(ctx context.Context, dir string, opts DiffOptions, path string) (string, error) {
+	var object string
+	switch {
+	case opts.Staged:
+		object = ":" + path
+	case opts.Commit != "":
+		object = opts.Commit + ":" + path
+	case strings.Contains(opts.Range, ".."):
+		// The end of main..feature or main...feature; tags such as v1.2 contain dots themselves
+		end := strings.TrimPrefix(opts.Range[strings.LastIndex(opts.Range, "..")+2:], ".")
+		if end == "" {
+			end = "HEAD"
+		}
+		object = end + ":" + path
+	default:
+		root, err := Run(ctx, dir, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(filepath.Join(strings.TrimSpace(root), filepath.FromSlash(path)))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return string(content), nil
+	}
+	return Run(ctx, dir, "show", object)
+}
+
+// Run runs git with the arguments in dir and returns its standard output. Paths are never quoted so
+// that file names keep their characters.
+func Run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-c", "core.quotepath=off"}, args...)...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// HooksDir returns the directory holding the hooks of the repository in dir, honoring core.hooksPath.
+func HooksDir(ctx context.Context, dir string) (string, error) {
+	out, err := Run(ctx, dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(out)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
+
+// ParseDiff splits the output of git diff into the diffs of the changed files. Deleted files are
+// listed with their deletions; binary files have an empty patch.
+func ParseDiff(diff string) []types.FileDiff {
+	var files []types.FileDiff
+	var file *types.FileDiff
+	var patch []string
+	inHunks := false
+
+	flush := func() {
+		if file != nil {
+			file.Patch = strings.Join(patch, "\n")
+			files = append(files, *file)
+		}
+		file, patch, inHunks = nil, nil, false
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			file = &types.FileDiff{Path: headerPath(line)}
+		case file == nil:
+			continue
+		case inHunks || strings.HasPrefix(line, "@@"):
+			inHunks = true
+			patch = append(patch, line)
+			if strings.HasPrefix(line, "+") {
+				file.Additions++
+			} else if strings.HasPrefix(line, "-") {
+				file.Deletions++
+			}
+		case strings.HasPrefix(line, "+++ b/"):
+			file.Path = strings.TrimPrefix(line, "+++ b/")
+		}
+	}
+	flush()
+	return files
+}
+
+// headerPath returns the new path of a "diff --git a/old b/new" header line.
+func headerPath(line string) string {
+	header := strings.TrimPrefix(line, "diff --git ")
+	if index := strings.LastIndex(header, " b/"); index >= 0 {
+		return header[index+len(" b/"):]
+	}
+	return ""
+}
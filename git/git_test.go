@@ -0,0 +1,132 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDiff = `diff --git a/main.go b/main.go
+index 3b18e51..a042389 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+-func old() {}
++func first() {}
++func second() {}
+ 
+diff --git a/assets/logo.png b/assets/logo.png
+index 1b2c3d4..5e6f7a8 100644
+Binary files a/assets/logo.png and b/assets/logo.png differ
+diff --git a/docs/old.md b/docs/old.md
+deleted file mode 100644
+index 3b18e51..0000000
+--- a/docs/old.md
++++ /dev/null
+@@ -1,2 +0,0 @@
+-# Old
+-text
+`
+
+// TestParseDiff tests splitting a diff into files with their patches and line counts.
+func TestParseDiff(t *testing.T) {
+	files := ParseDiff(testDiff)
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %+v", files)
+	}
+
+	if files[0].Path != "main.go" || files[0].Additions != 2 || files[0].Deletions != 1 {
+		t.Errorf("Unexpected first file %+v", files[0])
+	}
+	expectedPatch := "@@ -1,3 +1,4 @@\n package main\n-func old() {}\n+func first() {}\n+func second() {}\n "
+	if files[0].Patch != expectedPatch {
+		t.Errorf("Expected patch %q, got %q", expectedPatch, files[0].Patch)
+	}
+	if files[1].Path != "assets/logo.png" || files[1].Patch != "" {
+		t.Errorf("Expected a binary file without patch, got %+v", files[1])
+	}
+	if files[2].Path != "docs/old.md" || files[2].Deletions != 2 || files[2].Additions != 0 {
+		t.Errorf("Expected a deleted file, got %+v", files[2])
+	}
+}
+
+// TestDiff tests building the diff of staged and committed changes in a repository.
+func TestDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	ctx := context.Background()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := Run(ctx, dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q", "-b", "main")
+	write("a.go", "package a\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	write("a.go", "package a\n\nfunc A() {}\n")
+	git("tag", "v1.2", "HEAD")
+	git("commit", "-q", "-am", "add A")
+	git("tag", "v1.3", "HEAD")
+	write("a.go", "package a\n\nfunc A() {}\n\nfunc B() {}\n")
+	git("add", ".")
+
+	tests := []struct {
+		opts      DiffOptions
+		additions int
+	}{
+		{DiffOptions{Staged: true}, 2},
+		{DiffOptions{Commit: "HEAD"}, 2},
+		{DiffOptions{Range: "HEAD~1..HEAD"}, 2},
+		{DiffOptions{Base: "HEAD~1"}, 4},
+		{DiffOptions{}, 2},
+	}
+	for _, test := range tests {
+		files, err := Diff(ctx, dir, test.opts)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", test.opts, err)
+		}
+		if len(files) != 1 || files[0].Path != "a.go" || files[0].Additions != test.additions {
+			t.Errorf("%s: expected a.go with %d additions, got %+v", test.opts, test.additions, files)
+		}
+	}
+
+	if _, err := Diff(ctx, dir, DiffOptions{Staged: true, Commit: "HEAD"}); err == nil {
+		t.Error("Expected an error for conflicting options")
+	}
+
+	write("a.go", "package a\n\nfunc C() {}\n")
+	contents := []struct {
+		opts     DiffOptions
+		expected string
+	}{
+		{DiffOptions{Staged: true}, "func B"},
+		{DiffOptions{Commit: "HEAD~1"}, "package a"},
+		{DiffOptions{Range: "HEAD~1...HEAD"}, "func A() {}\n"},
+		{DiffOptions{Range: "v1.2..v1.3"}, "func A() {}\n"},
+		{DiffOptions{Range: "v1.2...v1.3"}, "func A() {}\n"},
+		{DiffOptions{Range: "v1.2.."}, "func A() {}\n"},
+		{DiffOptions{Base: "main"}, "func C"},
+	}
+	for _, test := range contents {
+		content, err := FileContent(ctx, dir, test.opts, "a.go")
+		if err != nil || !strings.Contains(content, test.expected) {
+			t.Errorf("%s: expected the file to contain %q, got %q (%v)", test.opts, test.expected, content, err)
+		}
+	}
+}
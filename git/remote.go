@@ -0,0 +1,82 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Remote identifies a hosted repository.
+type Remote struct {
+	// Host is the host name without port, in lower case.
+	Host string
+	// Owner is the user, organization or group owning the repository. Hosts such as GitLab nest
+	// groups, e.g. "group/subgroup".
+	Owner string
+	Repo  string
+}
+
+// FullName returns "owner/repo".
+func (r Remote) FullName() string {
+	return r.Owner + "/" + r.Repo
+}
+
+// remoteSchemes are the URL schemes git can clone from a host.
+var remoteSchemes = map[string]bool{"ssh": true, "git": true, "http": true, "https": true, "git+ssh": true, "ssh+git": true}
+
+// ParseRemoteURL extracts the host, owner and repository from a remote URL. It accepts URLs such as
+// ssh://git@host:2222/owner/repo.git and https://user@host/group/subgroup/repo/ as well as the
+// scp-like git@host:owner/repo.git form.
+func ParseRemoteURL(rawURL string) (Remote, error) {
+	rawURL = strings.TrimSpace(rawURL)
+
+	var host, path string
+	if strings.Contains(rawURL, "://") {
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return Remote{}, fmt.Errorf("invalid remote URL %q: %w", rawURL, err)
+		}
+		if !remoteSchemes[strings.ToLower(parsed.Scheme)] {
+			return Remote{}, fmt.Errorf("unsupported remote URL %q: scheme %s is not a hosted repository", rawURL, parsed.Scheme)
+		}
+		host, path = parsed.Hostname(), parsed.Path
+	} else {
+		// scp-like syntax: [user@]host:path, where the host part has no slash
+		colon := strings.Index(rawURL, ":")
+		if colon < 1 || strings.Contains(rawURL[:colon], "/") {
+			return Remote{}, fmt.Errorf("unsupported remote URL %q: not a hosted repository", rawURL)
+		}
+		host, path = rawURL[:colon], rawURL[colon+1:]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".git")
+	slash := strings.LastIndex(path, "/")
+	if host == "" || slash <= 0 || slash == len(path)-1 {
+		return Remote{}, fmt.Errorf("unsupported remote URL %q: expected a host, owner and repository", rawURL)
+	}
+	return Remote{Host: strings.ToLower(host), Owner: path[:slash], Repo: path[slash+1:]}, nil
+}
+
+// RemoteURL returns the URL of the named remote of the repository in dir, with url.<base>.insteadOf
+// rewrites applied.
+func RemoteURL(ctx context.Context, dir, name string) (string, error) {
+	out, err := Run(ctx, dir, "remote", "get-url", name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// GetRemote looks up the named remote of the repository in dir and parses its URL.
+func GetRemote(ctx context.Context, dir, name string) (Remote, error) {
+	rawURL, err := RemoteURL(ctx, dir, name)
+	if err != nil {
+		return Remote{}, err
+	}
+	return ParseRemoteURL(rawURL)
+}
@@ -0,0 +1,227 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Review events of a pull request review.
+const (
+	EventComment        = "COMMENT"
+	EventApprove        = "APPROVED"
+	EventRequestChanges = "REQUEST_CHANGES"
+)
+
+// filesPageSize is the number of changed files requested per page, the default maximum of Gitea.
+const filesPageSize = 50
+
+// Client talks to the REST API of a Gitea or Forgejo instance.
+type Client struct {
+	// BaseURL is the URL of the instance, e.g. https://codeberg.org; /api/v1 is added.
+	BaseURL string
+	Token   string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// ChangedFile describes a file changed by a pull request.
+type ChangedFile struct {
+	Filename         string `json:"filename"`
+	PreviousFilename string `json:"previous_filename"`
+	Status           string `json:"status"`
+	Additions        int    `json:"additions"`
+	Deletions        int    `json:"deletions"`
+}
+
+// Commit is a commit of a comparison with the files it changed.
+type Commit struct {
+	SHA   string `json:"sha"`
+	Files []struct {
+		Filename string `json:"filename"`
+	} `json:"files"`
+}
+
+// Review is a pull request review submitted in a single request.
+type Review struct {
+	Body     string          `json:"body"`
+	Event    string          `json:"event"`
+	CommitID string          `json:"commit_id,omitempty"`
+	Comments []ReviewComment `json:"comments,omitempty"`
+}
+
+// ReviewComment is an inline comment on a line of the new version of a file, or with OldPosition on a
+// deleted line of the old version.
+type ReviewComment struct {
+	Path        string `json:"path"`
+	Body        string `json:"body"`
+	NewPosition int    `json:"new_position,omitempty"`
+	OldPosition int    `json:"old_position,omitempty"`
+}
+
+// NewClient creates a client for the instance at baseURL.
+func NewClient(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token}
+}
+
+// PullFiles fetches the files changed by the pull request, following every page of the listing.
+func (c *Client) PullFiles(ctx context.Context, owner, repo string, index int) ([]ChangedFile, error) {
+	var all []ChangedFile
+	for page := 1; ; page++ {
+		var files []ChangedFile
+		path := fmt.Sprintf("%s/files?page=%d&limit=%d", pullPath(owner, repo, index), page, filesPageSize)
+		resp, err := c.do(ctx, http.MethodGet, path, nil, &files)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve pull request files: %w", err)
+		}
+		all = append(all, files...)
+		total, err := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+		if len(files) == 0 || (err == nil && len(all) >= total) || (err != nil && len(files) < filesPageSize) {
+			return all, nil
+		}
+	}
+}
+
+// PullDiff fetches the unified diff of the pull request.
+func (c *Client) PullDiff(ctx context.Context, owner, repo string, index int) (string, error) {
+	var diff string
+	if _, err := c.do(ctx, http.MethodGet, pullPath(owner, repo, index)+".diff", nil, &diff); err != nil {
+		return "", fmt.Errorf("failed to retrieve pull request diff: %w", err)
+	}
+	return diff, nil
+}
+
+// HeadCommit returns the SHA of the head commit of the pull request.
+func (c *Client) HeadCommit(ctx context.Context, owner, repo string, index int) (string, error) {
+	var pr struct {
+		Head struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, pullPath(owner, repo, index), nil, &pr); err != nil {
+		return "", fmt.Errorf("failed to retrieve pull request: %w", err)
+	}
+	return pr.Head.SHA, nil
+}
+
+// Compare returns the commits from the merge base of base and head to head, each with the paths of the
+// files it changed. The compare API requires Gitea 1.22 or Forgejo 7.
+func (c *Client) Compare(ctx context.Context, owner, repo, base, head string) ([]Commit, error) {
+	var comparison struct {
+		Commits []Commit `json:"commits"`
+	}
+	u := fmt.Sprintf("repos/%s/%s/compare/%s...%s", url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(base), url.PathEscape(head))
+	if _, err := c.do(ctx, http.MethodGet, u, nil, &comparison); err != nil {
+		return nil, fmt.Errorf("failed to compare %s...%s: %w", base, head, err)
+	}
+	return comparison.Commits, nil
+}
+
+// FileContent returns the content of the file at path in the commit ref.
+func (c *Client) FileContent(ctx context.Context, owner, repo, ref, path string) (string, error) {
+	var content string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	u := fmt.Sprintf("repos/%s/%s/raw/%s?ref=%s", url.PathEscape(owner), url.PathEscape(repo), strings.Join(segments, "/"), url.QueryEscape(ref))
+	if _, err := c.do(ctx, http.MethodGet, u, nil, &content); err != nil {
+		return "", fmt.Errorf("failed to retrieve %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// CreateReview submits a review with its inline comments.
+func (c *Client) CreateReview(ctx context.Context, owner, repo string, index int, review Review) error {
+	if _, err := c.do(ctx, http.MethodPost, pullPath(owner, repo, index)+"/reviews", review, nil); err != nil {
+		return fmt.Errorf("failed to submit review: %w", err)
+	}
+	return nil
+}
+
+// CreateComment posts a comment on the conversation of the pull request.
+func (c *Client) CreateComment(ctx context.Context, owner, repo string, index int, body string) error {
+	request := struct {
+		Body string `json:"body"`
+	}{body}
+	path := fmt.Sprintf("repos/%s/%s/issues/%d/comments", url.PathEscape(owner), url.PathEscape(repo), index)
+	if _, err := c.do(ctx, http.MethodPost, path, request, nil); err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+	return nil
+}
+
+// pullPath returns the API path of the pull request.
+func pullPath(owner, repo string, index int) string {
+	return fmt.Sprintf("repos/%s/%s/pulls/%d", url.PathEscape(owner), url.PathEscape(repo), index)
+}
+
+// do sends a request to the API, encoding the body and decoding the response into result when they
+// are not nil. A *string result receives the raw response body.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/api/v1/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "token "+c.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, newAPIError(resp.StatusCode, data)
+	}
+	switch result := result.(type) {
+	case nil:
+	case *string:
+		*result = string(data)
+	default:
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// newAPIError converts an error response into a typed error carrying the API's message.
+func newAPIError(statusCode int, body []byte) error {
+	var response struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil || response.Message == "" {
+		return types.NewAPIError(statusCode, "", strings.TrimSpace(string(body)))
+	}
+	return types.NewAPIError(statusCode, "", response.Message)
+}
@@ -0,0 +1,67 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// TestPullFiles tests that every page of changed files is fetched
+func TestPullFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/owner/repo/pulls/3/files" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "token fake-token" {
+			t.Errorf("Expected the token header, got %v", r.Header)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		w.Header().Set("X-Total-Count", strconv.Itoa(filesPageSize+1))
+		var files []ChangedFile
+		if page == 1 {
+			for i := 0; i < filesPageSize; i++ {
+				files = append(files, ChangedFile{Filename: fmt.Sprintf("file%d.go", i), Additions: 1})
+			}
+		} else {
+			files = append(files, ChangedFile{Filename: "last.go"})
+		}
+		json.NewEncoder(w).Encode(files)
+	}))
+	defer server.Close()
+
+	files, err := NewClient(server.URL+"/", "fake-token").PullFiles(context.Background(), "owner", "repo", 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != filesPageSize+1 || files[filesPageSize].Filename != "last.go" {
+		t.Errorf("Expected %d files ending with last.go, got %d", filesPageSize+1, len(files))
+	}
+}
+
+// TestCreateReview tests the review payload
+func TestCreateReview(t *testing.T) {
+	var received Review
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/repos/owner/repo/pulls/3/reviews" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL, "fake-token").CreateReview(context.Background(), "owner", "repo", 3, Review{
+		Body:     "Summary",
+		Event:    EventRequestChanges,
+		Comments: []ReviewComment{{Path: "a.go", Body: "Inline", NewPosition: 7}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if received.Event != EventRequestChanges || len(received.Comments) != 1 || received.Comments[0].NewPosition != 7 {
+		t.Errorf("Expected a review requesting changes with one comment, got %+v", received)
+	}
+}
@@ -0,0 +1,192 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"github.com/google/go-github/v42/github"
+	"golang.org/x/oauth2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// appTokenTTL is the lifetime of the JWTs authenticating as the app; GitHub accepts at most ten minutes.
+	appTokenTTL = 9 * time.Minute
+	// clockSkew backdates the JWTs to tolerate clocks running ahead of GitHub's.
+	clockSkew = time.Minute
+	// tokenRefreshMargin renews installation tokens this long before they expire.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// App authenticates as a GitHub App and hands out installation tokens, cached until shortly before
+// they expire.
+type App struct {
+	ID  int64
+	Key *rsa.PrivateKey
+	// HTTPClient sends the requests of the app, http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// BaseURL is the REST API URL, https://api.github.com/ when empty.
+	BaseURL string
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]*oauth2.Token
+}
+
+// apps caches the apps by API URL and ID so that installation tokens are shared by every client of
+// the process.
+var apps = struct {
+	sync.Mutex
+	byKey map[string]*App
+}{byKey: make(map[string]*App)}
+
+// NewApp creates an app from its ID and PEM encoded private key.
+func NewApp(id int64, privateKey []byte) (*App, error) {
+	key, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &App{ID: id, Key: key, now: time.Now, installations: make(map[string]int64), tokens: make(map[int64]*oauth2.Token)}, nil
+}
+
+// ParsePrivateKey decodes an RSA private key in PKCS #1 or PKCS #8 PEM form, as downloaded from the
+// settings of a GitHub App.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid GitHub App private key: not an RSA key")
+	}
+	return key, nil
+}
+
+// JWT returns a JSON Web Token signed with RS256 that authenticates as the app.
+func (a *App) JWT() (string, error) {
+	now := a.now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-clockSkew).Unix(),
+		"exp": now.Add(appTokenTTL).Unix(),
+		"iss": strconv.FormatInt(a.ID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App token: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// TokenSource returns the installation tokens of the app for the repository. The installation is
+// looked up for owner/repo unless installationID is set.
+func (a *App) TokenSource(ctx context.Context, installationID int64, owner, repo string) oauth2.TokenSource {
+	return tokenSourceFunc(func() (*oauth2.Token, error) {
+		return a.InstallationToken(ctx, installationID, owner, repo)
+	})
+}
+
+// InstallationToken returns a cached installation token, creating a new one when none is cached or
+// the cached token expires within the refresh margin.
+func (a *App) InstallationToken(ctx context.Context, installationID int64, owner, repo string) (*oauth2.Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	client, err := a.client()
+	if err != nil {
+		return nil, err
+	}
+	if installationID == 0 {
+		key := owner + "/" + repo
+		installationID = a.installations[key]
+		if installationID == 0 {
+			installation, _, err := client.Apps.FindRepositoryInstallation(ctx, owner, repo)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find GitHub App installation of %s: %w", key, apiError(err))
+			}
+			installationID = installation.GetID()
+			a.installations[key] = installationID
+		}
+	}
+
+	if token := a.tokens[installationID]; token != nil && token.Expiry.After(a.now().Add(tokenRefreshMargin)) {
+		return token, nil
+	}
+	created, _, err := client.Apps.CreateInstallationToken(ctx, installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub App installation token: %w", apiError(err))
+	}
+	token := &oauth2.Token{AccessToken: created.GetToken(), Expiry: created.GetExpiresAt()}
+	a.tokens[installationID] = token
+	return token, nil
+}
+
+// client returns a GitHub client authenticated with the app's JWT.
+func (a *App) client() (*github.Client, error) {
+	base := a.HTTPClient
+	if base == nil {
+		base = http.DefaultClient
+	}
+	client := github.NewClient(&http.Client{Transport: &jwtTransport{app: a, base: base}})
+	if a.BaseURL != "" {
+		baseURL, err := url.Parse(strings.TrimSuffix(a.BaseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid GitHub API URL: %w", err)
+		}
+		client.BaseURL = baseURL
+	}
+	return client, nil
+}
+
+// jwtTransport authenticates requests with a fresh app JWT.
+type jwtTransport struct {
+	app  *App
+	base *http.Client
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.JWT()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.Do(req)
+}
+
+// tokenSourceFunc adapts a function to oauth2.TokenSource.
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+// Token implements oauth2.TokenSource.
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	return f()
+}
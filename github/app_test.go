@@ -0,0 +1,129 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKey generates an RSA key and returns it with its PKCS #1 PEM encoding.
+func testKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// TestAppJWT tests that the app JWT is signed with RS256 and carries the app ID.
+func TestAppJWT(t *testing.T) {
+	key, pemKey := testKey(t)
+	app, err := NewApp(12345, pemKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	app.now = func() time.Time { return now }
+
+	token, err := app.JWT()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected three JWT parts, got %q", token)
+	}
+
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("Expected a valid RS256 signature, got %v", err)
+	}
+
+	var claims struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("Expected JSON claims, got %v", err)
+	}
+	if claims.Issuer != "12345" || claims.IssuedAt != now.Unix()-60 || claims.ExpiresAt != now.Unix()+9*60 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	if _, err := NewApp(1, []byte("not a key")); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+}
+
+// TestAppInstallationToken tests looking up the installation, caching its token and refreshing it
+// before it expires.
+func TestAppInstallationToken(t *testing.T) {
+	_, pemKey := testKey(t)
+	lookups, created := 0, 0
+	now := time.Now()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ey") {
+			t.Errorf("Expected the app JWT, got %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/owner/repo/installation":
+			lookups++
+			fmt.Fprint(w, `{"id":77}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/77/access_tokens":
+			created++
+			fmt.Fprintf(w, `{"token":"ghs_%d","expires_at":%q}`, created, now.Add(time.Hour).Format(time.RFC3339))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	app, err := NewApp(1, pemKey)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	app.BaseURL = server.URL
+	clock := now
+	app.now = func() time.Time { return clock }
+
+	for i := 0; i < 2; i++ {
+		token, err := app.InstallationToken(context.Background(), 0, "owner", "repo")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if token.AccessToken != "ghs_1" {
+			t.Errorf("Expected the cached token, got %s", token.AccessToken)
+		}
+	}
+	if lookups != 1 || created != 1 {
+		t.Errorf("Expected one lookup and one token, got %d and %d", lookups, created)
+	}
+
+	// Close to the expiry a new token is created
+	clock = now.Add(56 * time.Minute)
+	token, err := app.InstallationToken(context.Background(), 0, "owner", "repo")
+	if err != nil || token.AccessToken != "ghs_2" {
+		t.Errorf("Expected a refreshed token, got %v (%v)", token, err)
+	}
+	if lookups != 1 {
+		t.Errorf("Expected the installation to be cached, got %d lookups", lookups)
+	}
+}
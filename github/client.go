@@ -0,0 +1,123 @@
+package github
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/google/go-github/v42/github"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"golang.org/x/oauth2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Credentials selects how the GitHub client authenticates: as a GitHub App when AppID is set, with
+// the personal access token otherwise.
+type Credentials struct {
+	Token string
+	AppID int64
+	// PrivateKey is the PEM encoded private key of the app.
+	PrivateKey []byte
+	// InstallationID selects the installation of the app; when zero it is looked up for the repository.
+	InstallationID int64
+}
+
+// Endpoint locates the GitHub API. The zero value talks to github.com.
+type Endpoint struct {
+	// BaseURL is the URL of a GitHub Enterprise Server instance, e.g. https://github.example.com; the
+	// /api/v3/ path is added when missing.
+	BaseURL string
+	// UploadURL is the upload API URL, derived from BaseURL when empty.
+	UploadURL string
+	// CABundle holds PEM encoded certificates trusted in addition to the system roots.
+	CABundle []byte
+}
+
+// publicURLs are base URLs of github.com, which is not an Enterprise Server.
+var publicURLs = map[string]bool{"https://github.com": true, "https://api.github.com": true}
+
+// NewClient creates a GitHub client for the repository owner/repo with the credentials. Failed requests
+// are retried according to the policy. Apps are cached per process so that their installation tokens
+// are reused until shortly before they expire.
+func NewClient(ctx context.Context, creds Credentials, endpoint Endpoint, owner, repo string, policy retry.Policy) (*github.Client, error) {
+	httpClient, err := endpoint.httpClient(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	var source oauth2.TokenSource
+	if creds.AppID == 0 {
+		source = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: creds.Token})
+	} else {
+		// The app talks to the same API as the client
+		apiClient, err := endpoint.client(httpClient)
+		if err != nil {
+			return nil, err
+		}
+		app, err := cachedApp(creds, apiClient.BaseURL.String(), httpClient)
+		if err != nil {
+			return nil, err
+		}
+		source = app.TokenSource(ctx, creds.InstallationID, owner, repo)
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	return endpoint.client(oauth2.NewClient(ctx, source))
+}
+
+// cachedApp returns the app of the credentials for the API, creating it on first use.
+func cachedApp(creds Credentials, baseURL string, httpClient *http.Client) (*App, error) {
+	apps.Lock()
+	defer apps.Unlock()
+
+	key := baseURL + "#" + strconv.FormatInt(creds.AppID, 10)
+	if app := apps.byKey[key]; app != nil {
+		return app, nil
+	}
+	app, err := NewApp(creds.AppID, creds.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	app.HTTPClient = httpClient
+	app.BaseURL = baseURL
+	apps.byKey[key] = app
+	return app, nil
+}
+
+// client creates a GitHub client for the endpoint sending its requests with httpClient.
+func (e Endpoint) client(httpClient *http.Client) (*github.Client, error) {
+	if e.BaseURL == "" || publicURLs[strings.TrimSuffix(e.BaseURL, "/")] {
+		return github.NewClient(httpClient), nil
+	}
+	uploadURL := e.UploadURL
+	if uploadURL == "" {
+		uploadURL = e.BaseURL
+	}
+	client, err := github.NewEnterpriseClient(e.BaseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub URL: %w", err)
+	}
+	return client, nil
+}
+
+// httpClient returns the retrying HTTP client of the endpoint, trusting its CA bundle.
+func (e Endpoint) httpClient(policy retry.Policy) (*http.Client, error) {
+	client := retry.NewClient(policy)
+	if len(e.CABundle) == 0 {
+		return client, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(e.CABundle) {
+		return nil, fmt.Errorf("no certificates found in the GitHub CA bundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	client.Transport.(*retry.Transport).Base = transport
+	return client, nil
+}
@@ -0,0 +1,85 @@
+package github
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestNewClientEnterprise tests that the client talks to the Enterprise Server API path and trusts
+// the configured CA bundle.
+func TestNewClientEnterprise(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/owner/repo/pulls/1" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer ghp_token" {
+			t.Errorf("Expected the token, got %q", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `{"number":1,"changed_files":3}`)
+	}))
+	defer server.Close()
+
+	policy := retry.Policy{MaxAttempts: 1}
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	client, err := NewClient(context.Background(), Credentials{Token: "ghp_token"}, Endpoint{BaseURL: server.URL, CABundle: bundle}, "owner", "repo", policy)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if client.UploadURL.String() != server.URL+"/api/uploads/" {
+		t.Errorf("Expected the upload URL to be derived from the base URL, got %s", client.UploadURL)
+	}
+
+	count, err := GetPRChangedFilesCount(context.Background(), client, "owner", "repo", 1)
+	if err != nil || count != 3 {
+		t.Errorf("Expected 3 changed files, got %d (%v)", count, err)
+	}
+
+	// Without the bundle the self-signed certificate is rejected
+	client, _ = NewClient(context.Background(), Credentials{Token: "ghp_token"}, Endpoint{BaseURL: server.URL}, "owner", "repo", policy)
+	if _, err := GetPRChangedFilesCount(context.Background(), client, "owner", "repo", 1); err == nil {
+		t.Error("Expected a certificate error without the CA bundle")
+	}
+
+	if _, err := NewClient(context.Background(), Credentials{}, Endpoint{BaseURL: server.URL, CABundle: []byte("junk")}, "owner", "repo", policy); err == nil {
+		t.Error("Expected an error for a bundle without certificates")
+	}
+
+	// github.com URLs keep the public API
+	client, _ = NewClient(context.Background(), Credentials{}, Endpoint{BaseURL: "https://github.com"}, "owner", "repo", policy)
+	if client.BaseURL.String() != "https://api.github.com/" {
+		t.Errorf("Expected the public API, got %s", client.BaseURL)
+	}
+}
+
+// TestParseGitURL tests parsing remotes of github.com and other hosts.
+func TestParseGitURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		owner string
+		repo  string
+	}{
+		{"git@github.com:ozgen/go-chatgpt-pr-reviewer.git", "ozgen", "go-chatgpt-pr-reviewer"},
+		{"https://github.com/ozgen/go-chatgpt-pr-reviewer", "ozgen", "go-chatgpt-pr-reviewer"},
+		{"git@github.example.com:team/service.git", "team", "service"},
+		{"https://github.example.com/team/service.git", "team", "service"},
+		{"ssh://git@github.example.com:2222/team/service.git/", "team", "service"},
+	}
+	for _, test := range tests {
+		owner, repo, err := parseGitURL(test.url)
+		if err != nil || owner != test.owner || repo != test.repo {
+			t.Errorf("%s: expected %s/%s, got %s/%s (%v)", test.url, test.owner, test.repo, owner, repo, err)
+		}
+	}
+
+	if _, _, err := parseGitURL("/srv/git/repo.git"); err == nil {
+		t.Error("Expected an error for a local path")
+	}
+	if _, _, err := parseGitURL("https://gitlab.com/group/subgroup/repo.git"); err == nil {
+		t.Error("Expected an error for a nested group")
+	}
+}
@@ -2,147 +2,374 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/go-github/v42/github"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
 	"golang.org/x/oauth2"
-	"os/exec"
-	"regexp"
-	"strconv"
+	"net/http"
 	"strings"
 )
 
-// SetupGitHubClient creates a GitHub client with the provided token.
-func SetupGitHubClient(ctx context.Context, token string) *github.Client {
+// SetupGitHubClient creates a GitHub client with the provided token. Failed requests, including
+// responses to exceeded primary and secondary rate limits, are retried according to the policy.
+func SetupGitHubClient(ctx context.Context, token string, policy retry.Policy) *github.Client {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, retry.NewClient(policy))
 	tc := oauth2.NewClient(ctx, ts)
 	return github.NewClient(tc)
 }
 
-// GetPRChanges fetches file changes for a given pull request.
-func GetPRChanges(ctx context.Context, client *github.Client, owner, repo string, prNumber int) ([]*github.CommitFile, error) {
-	opts := &github.ListOptions{PerPage: 10}
-	files, _, err := client.PullRequests.ListFiles(ctx, owner, repo, prNumber, opts)
+// maxPRFiles is the maximum number of files GitHub returns when listing the files of a pull request.
+const maxPRFiles = 3000
+
+// GetPRChanges fetches file changes for a given pull request, following every page of the listing.
+// The returned count is the total number of files the pull request changes, which exceeds the number of
+// returned files when GitHub truncated the list at its 3000 file limit. The pull request is only fetched
+// for the count when the listing reaches the limit.
+func GetPRChanges(ctx context.Context, client *github.Client, owner, repo string, prNumber int) ([]*github.CommitFile, int, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	var allFiles []*github.CommitFile
+	for {
+		files, resp, err := client.PullRequests.ListFiles(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, 0, apiError(err)
+		}
+		allFiles = append(allFiles, files...)
+		if resp.NextPage == 0 || len(allFiles) >= maxPRFiles {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	if len(allFiles) < maxPRFiles {
+		return allFiles, len(allFiles), nil
+	}
+	total, err := GetPRChangedFilesCount(ctx, client, owner, repo, prNumber)
+	if err != nil {
+		return nil, 0, err
+	}
+	return allFiles, max(total, len(allFiles)), nil
+}
+
+// maxCompareFiles is the maximum number of files GitHub returns when comparing two commits.
+const maxCompareFiles = 300
+
+// CompareCommits returns the files changed from base to head and the status of head relative to base:
+// ahead, behind, diverged or identical. The returned flag reports whether GitHub truncated the file list
+// at its 300 file limit.
+func CompareCommits(ctx context.Context, client *github.Client, owner, repo, base, head string) ([]*github.CommitFile, string, bool, error) {
+	comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, nil)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to compare %s...%s: %w", base, head, apiError(err))
+	}
+	return comparison.Files, comparison.GetStatus(), len(comparison.Files) >= maxCompareFiles, nil
+}
+
+// FileDiffs converts the files of a pull request into code host independent diffs.
+func FileDiffs(files []*github.CommitFile) []types.FileDiff {
+	diffs := make([]types.FileDiff, 0, len(files))
+	for _, file := range files {
+		diffs = append(diffs, types.FileDiff{
+			Path:      file.GetFilename(),
+			Patch:     file.GetPatch(),
+			Additions: file.GetAdditions(),
+			Deletions: file.GetDeletions(),
+		})
+	}
+	return diffs
+}
+
+// GetPRChangedFilesCount returns the total number of files changed by a pull request as reported by GitHub,
+// which may exceed the number of files the listing API returns.
+func GetPRChangedFilesCount(ctx context.Context, client *github.Client, owner, repo string, prNumber int) (int, error) {
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, prNumber)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to retrieve PR information: %w", apiError(err))
 	}
-	return files, nil
+	return pr.GetChangedFiles(), nil
 }
 
 // GetGitRemoteInfo executes git command to get remote URL and extracts owner and repo.
 // The directory parameter specifies the path to the Git repository.
 func GetGitRemoteInfo(directory string) (string, string, error) {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = directory // Set the working directory to the specified directory
-	output, err := cmd.CombinedOutput()
+	return GetRemoteInfo(context.Background(), directory, "origin")
+}
+
+// GetRemoteInfo extracts the owner and repository from the URL of the named remote of the Git
+// repository in directory.
+func GetRemoteInfo(ctx context.Context, directory, remote string) (string, string, error) {
+	url, err := git.RemoteURL(ctx, directory, remote)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to execute git command: %v, output: %s", err, string(output))
+		return "", "", fmt.Errorf("failed to get the URL of remote %s: %w", remote, err)
 	}
-	return parseGitURL(strings.TrimSpace(string(output)))
+	return parseGitURL(url)
 }
 
-// PostReviewComment posts a comment on a pull request at a specified position within a file.
-func PostReviewComment(ctx context.Context, client *github.Client, owner, repo string, prNumber int, body, path string, line int) error {
-	// Retrieve the pull request to get the latest commit ID
+// GetPRHeadSHA returns the SHA of the head commit of the pull request.
+func GetPRHeadSHA(ctx context.Context, client *github.Client, owner, repo string, prNumber int) (string, error) {
 	pr, _, err := client.PullRequests.Get(ctx, owner, repo, prNumber)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve PR information: %v", err)
+		return "", fmt.Errorf("failed to retrieve PR information: %w", apiError(err))
+	}
+	return pr.GetHead().GetSHA(), nil
+}
+
+// GetFileContent returns the content of the file at path in the commit ref.
+func GetFileContent(ctx context.Context, client *github.Client, owner, repo, path, ref string) (string, error) {
+	file, _, _, err := client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve %s: %w", path, apiError(err))
+	}
+	if file == nil {
+		return "", fmt.Errorf("failed to retrieve %s: not a file", path)
+	}
+	return file.GetContent()
+}
+
+// PostReviewComment posts a comment on a pull request, anchored to the lines and side of the diff the
+// comment spans.
+func PostReviewComment(ctx context.Context, client *github.Client, owner, repo string, prNumber int, comment types.ReviewComment) error {
+	// Retrieve the pull request to get the latest commit ID
+	commitID, err := GetPRHeadSHA(ctx, client, owner, repo, prNumber)
+	if err != nil {
+		return err
 	}
-	commitID := pr.GetHead().GetSHA()
 
 	// Create a new review comment
-	comment := &github.PullRequestComment{
-		Body:     &body,
-		Path:     &path,
-		CommitID: &commitID,
-		Line:     &line,
-		Side:     github.String("RIGHT"), // Usually you want to comment on the "RIGHT" side of the diff
+	draft := draftComment(comment)
+	request := &github.PullRequestComment{
+		Body:      draft.Body,
+		Path:      draft.Path,
+		CommitID:  &commitID,
+		StartLine: draft.StartLine,
+		StartSide: draft.StartSide,
+		Line:      draft.Line,
+		Side:      draft.Side,
 	}
 
-	_, _, err = client.PullRequests.CreateComment(ctx, owner, repo, prNumber, comment)
+	_, _, err = client.PullRequests.CreateComment(ctx, owner, repo, prNumber, request)
 	if err != nil {
-		return fmt.Errorf("failed to post review comment: %v", err)
+		return fmt.Errorf("failed to post review comment: %w", apiError(err))
 	}
 	return nil
 }
 
-// ExtractModifiedLinesWithNumbers captures entire blocks of changes instead of line by line.
-func ExtractModifiedLinesWithNumbers(patch string) []types.ModifiedLine {
-	lines := strings.Split(patch, "\n")
-	var modifiedLines []types.ModifiedLine
-	var currentLineOriginal, currentLineModified int
-	var currentBlock []string
-	var blockStartLine int
-
-	// Regex to match @@ -a,b +c,d @@ lines
-	hunkHeaderRegex := regexp.MustCompile(`@@ -\d+,\d+ \+(\d+),\d+ @@`)
-	for _, line := range lines {
-		if strings.HasPrefix(line, "@@") {
-			// Process the previous block if any
-			if len(currentBlock) > 0 {
-				// Append the block with the starting line number
-				modifiedLines = append(modifiedLines, types.ModifiedLine{LineNumber: blockStartLine, Content: strings.Join(currentBlock, "\n")})
-				currentBlock = nil // Reset the block
-			}
+// draftComment converts a review comment into the comment of a review request. GitHub rejects a start
+// line on single-line comments, so it is only set for ranges.
+func draftComment(comment types.ReviewComment) *github.DraftReviewComment {
+	side := comment.Side
+	if side == "" {
+		side = types.SideRight
+	}
+	draft := &github.DraftReviewComment{
+		Path: github.String(comment.Path),
+		Body: github.String(comment.Body),
+		Line: github.Int(comment.Line),
+		Side: github.String(side),
+	}
+	if comment.StartLine > 0 && comment.StartLine < comment.Line {
+		draft.StartLine = github.Int(comment.StartLine)
+		draft.StartSide = github.String(side)
+	}
+	return draft
+}
 
-			// Parse hunk header to get starting line number in modified file
-			matches := hunkHeaderRegex.FindStringSubmatch(line)
-			if len(matches) == 2 {
-				currentLineModified, _ = strconv.Atoi(matches[1])
-				currentLineOriginal = currentLineModified // Start the original line counter from here
-			}
-		} else if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
-			if len(currentBlock) == 0 {
-				blockStartLine = currentLineModified // Record the starting line for the block
-			}
-			// Append lines to the current block
-			currentBlock = append(currentBlock, line)
-			if strings.HasPrefix(line, "+") {
-				currentLineModified++
-			} else if strings.HasPrefix(line, "-") {
-				currentLineOriginal++
+// fileComment represents a pull request comment on a whole file rather than a line of the diff.
+type fileComment struct {
+	Body        string `json:"body"`
+	Path        string `json:"path"`
+	CommitID    string `json:"commit_id"`
+	SubjectType string `json:"subject_type"`
+}
+
+// SubmitReview submits all review comments as a single pull request review. When GitHub rejects inline
+// comments because their lines are outside of the diff, the review is submitted again with the comments
+// whose lines are in the diff, and the others are posted as file-level comments instead.
+func SubmitReview(ctx context.Context, client *github.Client, owner, repo string, prNumber int, review types.Review) error {
+	commitID := review.CommitID
+	if commitID == "" {
+		sha, err := GetPRHeadSHA(ctx, client, owner, repo, prNumber)
+		if err != nil {
+			return err
+		}
+		commitID = sha
+	}
+
+	request := &github.PullRequestReviewRequest{
+		CommitID: github.String(commitID),
+		Body:     github.String(review.Body),
+		Event:    github.String(review.Event),
+		Comments: draftComments(review.Comments),
+	}
+	_, _, err := client.PullRequests.CreateReview(ctx, owner, repo, prNumber, request)
+	if err == nil {
+		return nil
+	}
+	if !linesRejected(err) || len(review.Comments) == 0 {
+		return fmt.Errorf("failed to submit review: %w", apiError(err))
+	}
+
+	// GitHub rejects the whole review if a single comment is outside of the diff, without naming it
+	files, _, err := GetPRChanges(ctx, client, owner, repo, prNumber)
+	if err != nil {
+		return err
+	}
+	inline, rejected := splitComments(review.Comments, files)
+	request.Comments = draftComments(inline)
+	_, _, err = client.PullRequests.CreateReview(ctx, owner, repo, prNumber, request)
+	if err != nil && linesRejected(err) && len(inline) > 0 {
+		// The diff does not explain the rejection; post every comment on its file
+		request.Comments, rejected = nil, review.Comments
+		_, _, err = client.PullRequests.CreateReview(ctx, owner, repo, prNumber, request)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to submit review: %w", apiError(err))
+	}
+	for _, comment := range rejected {
+		if err := postFileComment(ctx, client, owner, repo, prNumber, commitID, comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineErrors are the validation errors with which GitHub rejects review comments whose lines or paths are
+// outside of the diff.
+var lineErrors = []string{"could not be resolved", "part of the diff", "diff hunk", "same hunk"}
+
+// linesRejected reports whether GitHub refused the review as unprocessable because of the lines of its
+// comments, rather than for another reason such as approving one's own pull request.
+func linesRejected(err error) bool {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil || errResp.Response.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	for _, e := range errResp.Errors {
+		message := strings.ToLower(e.Message)
+		for _, lineError := range lineErrors {
+			if strings.Contains(message, lineError) {
+				return true
 			}
+		}
+	}
+	return false
+}
+
+// draftComments converts the comments into the inline comments of a review request.
+func draftComments(comments []types.ReviewComment) []*github.DraftReviewComment {
+	drafts := make([]*github.DraftReviewComment, 0, len(comments))
+	for _, comment := range comments {
+		drafts = append(drafts, draftComment(comment))
+	}
+	return drafts
+}
+
+// splitComments splits the comments into those whose lines are within a hunk of the files' patches,
+// which GitHub accepts inline, and the others.
+func splitComments(comments []types.ReviewComment, files []*github.CommitFile) ([]types.ReviewComment, []types.ReviewComment) {
+	hunks := make(map[string][]diff.Hunk)
+	for _, file := range files {
+		// Files whose patch does not parse have no commentable lines
+		hunks[file.GetFilename()], _ = diff.Parse(file.GetPatch())
+	}
+
+	var inline, rejected []types.ReviewComment
+	for _, comment := range comments {
+		if inHunk(comment, hunks[comment.Path]) {
+			inline = append(inline, comment)
 		} else {
-			if len(currentBlock) > 0 {
-				// End of a block detected, store it
-				modifiedLines = append(modifiedLines, types.ModifiedLine{LineNumber: blockStartLine, Content: strings.Join(currentBlock, "\n")})
-				currentBlock = nil // Reset the block
-			}
-			currentLineOriginal++
-			currentLineModified++
+			rejected = append(rejected, comment)
 		}
 	}
+	return inline, rejected
+}
 
-	// Process the last block if any
-	if len(currentBlock) > 0 {
-		modifiedLines = append(modifiedLines, types.ModifiedLine{LineNumber: blockStartLine, Content: strings.Join(currentBlock, "\n")})
+// inHunk reports whether all lines of the comment are within a single hunk, including its context lines,
+// on the side of the comment.
+func inHunk(comment types.ReviewComment, hunks []diff.Hunk) bool {
+	start := comment.Line
+	if comment.StartLine > 0 && comment.StartLine < comment.Line {
+		start = comment.StartLine
 	}
+	for _, hunk := range hunks {
+		first, count := hunk.NewStart, hunk.NewLines
+		if comment.Side == types.SideLeft {
+			first, count = hunk.OldStart, hunk.OldLines
+		}
+		if start >= first && comment.Line < first+count {
+			return true
+		}
+	}
+	return false
+}
+
+// postFileComment posts a review comment on the whole file, mentioning the lines it refers to.
+func postFileComment(ctx context.Context, client *github.Client, owner, repo string, prNumber int, commitID string, comment types.ReviewComment) error {
+	u := fmt.Sprintf("repos/%v/%v/pulls/%d/comments", owner, repo, prNumber)
+	req, err := client.NewRequest("POST", u, &fileComment{
+		Body:        fmt.Sprintf("On %s:\n%s", comment.Lines(), comment.Body),
+		Path:        comment.Path,
+		CommitID:    commitID,
+		SubjectType: "file",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create file comment request: %v", err)
+	}
+	if _, err := client.Do(ctx, req, nil); err != nil {
+		return fmt.Errorf("failed to post file comment on %s: %w", comment.Path, apiError(err))
+	}
+	return nil
+}
 
+// apiError converts GitHub rate limit and authentication failures into the shared typed errors, keeping
+// the message returned by the API.
+func apiError(err error) error {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var errResp *github.ErrorResponse
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return &types.APIError{StatusCode: rateLimitErr.Response.StatusCode, Message: rateLimitErr.Message, Err: types.ErrRateLimited}
+	case errors.As(err, &abuseErr):
+		return &types.APIError{StatusCode: abuseErr.Response.StatusCode, Message: abuseErr.Message, Err: types.ErrRateLimited}
+	case errors.As(err, &errResp) && errResp.Response != nil:
+		return types.NewAPIError(errResp.Response.StatusCode, "", errResp.Message)
+	}
+	return err
+}
+
+// ExtractModifiedLinesWithNumbers captures entire blocks of changes instead of line by line. Blocks
+// that only delete lines have no line number in the modified file. A patch that cannot be parsed has
+// no blocks.
+func ExtractModifiedLinesWithNumbers(patch string) []types.ModifiedLine {
+	hunks, err := diff.Parse(patch)
+	if err != nil {
+		return nil
+	}
+
+	var modifiedLines []types.ModifiedLine
+	for _, block := range diff.Blocks(hunks) {
+		line, _ := block.NewRange()
+		oldLine, _ := block.OldRange()
+		modifiedLines = append(modifiedLines, types.ModifiedLine{LineNumber: line, OldLineNumber: oldLine, Content: block.String()})
+	}
 	return modifiedLines
 }
 
-// parseGitURL parses the git URL to extract the owner and repository name.
+// parseGitURL parses the git URL to extract the owner and repository name. Remotes of any host are
+// accepted in the forms understood by git.ParseRemoteURL, but GitHub repositories have no nested groups.
 func parseGitURL(url string) (string, string, error) {
-	var sshPrefix = "git@github.com:"
-	var httpsPrefix = "https://github.com/"
-	var suffix = ".git"
-
-	if strings.HasPrefix(url, sshPrefix) {
-		trimmed := strings.TrimPrefix(url, sshPrefix)
-		trimmed = strings.TrimSuffix(trimmed, suffix)
-		parts := strings.Split(trimmed, "/")
-		if len(parts) == 2 {
-			return parts[0], parts[1], nil
-		}
-	} else if strings.HasPrefix(url, httpsPrefix) {
-		trimmed := strings.TrimPrefix(url, httpsPrefix)
-		trimmed = strings.TrimSuffix(trimmed, suffix)
-		parts := strings.Split(trimmed, "/")
-		if len(parts) == 2 {
-			return parts[0], parts[1], nil
-		}
+	remote, err := git.ParseRemoteURL(url)
+	if err != nil {
+		return "", "", err
+	}
+	if strings.Contains(remote.Owner, "/") {
+		return "", "", fmt.Errorf("unsupported remote URL %q: GitHub repositories are named owner/repo", url)
 	}
-	return "", "", fmt.Errorf("unknown git url format")
+	return remote.Owner, remote.Repo, nil
 }
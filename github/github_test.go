@@ -2,12 +2,16 @@ package github
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
 	"golang.org/x/oauth2"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/google/go-github/v42/github"
@@ -18,7 +22,7 @@ func TestSetupGitHubClient(t *testing.T) {
 	ctx := context.Background()
 	token := "fake-token"
 
-	client := SetupGitHubClient(ctx, token)
+	client := SetupGitHubClient(ctx, token, retry.DefaultPolicy())
 
 	if client == nil {
 		t.Fatal("Expected GitHub client, got nil")
@@ -61,11 +65,15 @@ func TestGetPRChanges(t *testing.T) {
 	repo := "repo"
 	prNumber := 1
 
-	files, err := GetPRChanges(ctx, client, owner, repo, prNumber)
+	files, total, err := GetPRChanges(ctx, client, owner, repo, prNumber)
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
+	if total != len(files) {
+		t.Errorf("Expected the total to match the %d listed files, got %d", len(files), total)
+	}
+
 	if len(files) != 1 {
 		t.Fatalf("Expected 1 file, got %d", len(files))
 	}
@@ -75,6 +83,85 @@ func TestGetPRChanges(t *testing.T) {
 	}
 }
 
+// TestGetPRChangesPagination tests that GetPRChanges follows the Link header through every page.
+func TestGetPRChangesPagination(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("per_page") != "100" {
+			t.Errorf("Expected per_page=100, got '%s'", r.URL.Query().Get("per_page"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2&per_page=100>; rel="next"`, server.URL, r.URL.Path))
+			w.Write([]byte(`[{"filename": "first.go"}, {"filename": "second.go"}]`))
+		case "2":
+			w.Write([]byte(`[{"filename": "third.go"}]`))
+		default:
+			t.Errorf("Unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	baseURL, _ := url.Parse(server.URL + "/")
+	client.BaseURL = baseURL
+
+	files, total, err := GetPRChanges(context.Background(), client, "owner", "repo", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if total != 3 {
+		t.Errorf("Expected a total of 3 files, got %d", total)
+	}
+	if len(files) != 3 || files[2].GetFilename() != "third.go" {
+		t.Fatalf("Expected 3 files across both pages, got %d", len(files))
+	}
+}
+
+// TestGetPRChangesLimit tests that the pull request is fetched once for the total when the listing reaches
+// GitHub's limit.
+func TestGetPRChangesLimit(t *testing.T) {
+	for _, changed := range []int{maxPRFiles, maxPRFiles + 1} {
+		var server *httptest.Server
+		var fetches int
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.URL.Path == "/repos/owner/repo/pulls/1" {
+				fetches++
+				fmt.Fprintf(w, `{"number":1,"changed_files":%d}`, changed)
+				return
+			}
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			if page < maxPRFiles/100 {
+				w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d&per_page=100>; rel="next"`, server.URL, r.URL.Path, page+1))
+			}
+			files := make([]map[string]string, 100)
+			for i := range files {
+				files[i] = map[string]string{"filename": fmt.Sprintf("file%d.go", (page-1)*100+i)}
+			}
+			json.NewEncoder(w).Encode(files)
+		}))
+
+		client := github.NewClient(nil)
+		baseURL, _ := url.Parse(server.URL + "/")
+		client.BaseURL = baseURL
+
+		files, total, err := GetPRChanges(context.Background(), client, "owner", "repo", 1)
+		server.Close()
+		if err != nil || len(files) != maxPRFiles {
+			t.Fatalf("Expected %d files, got %d (%v)", maxPRFiles, len(files), err)
+		}
+		if total != changed || fetches != 1 {
+			t.Errorf("Expected a total of %d files from a single fetch, got %d after %d fetches", changed, total, fetches)
+		}
+	}
+}
+
 // TestGetGitRemoteInfo tests the GetGitRemoteInfo function.
 func TestGetGitRemoteInfo(t *testing.T) {
 	directory, err := os.Getwd()
@@ -92,10 +179,17 @@ func TestGetGitRemoteInfo(t *testing.T) {
 	}
 }
 
-// TestPostReviewComment tests the PostReviewComment function.
+// TestPostReviewComment tests that comments are anchored to their line range and side.
 func TestPostReviewComment(t *testing.T) {
+	var received map[string]interface{}
 	// Mock the GitHub API server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"head":{"sha":"abc123"}}`))
+			return
+		}
+		received = nil
+		json.NewDecoder(r.Body).Decode(&received)
 		w.WriteHeader(http.StatusCreated)
 	}))
 	defer server.Close()
@@ -107,17 +201,115 @@ func TestPostReviewComment(t *testing.T) {
 	baseURL, _ := url.Parse(server.URL + "/")
 	client.BaseURL = baseURL
 
-	owner := "owner"
-	repo := "repo"
-	prNumber := 1
-	body := "This is a review comment"
-	path := "example.go"
-	line := 10
+	comment := types.ReviewComment{Path: "example.go", StartLine: 8, Line: 10, Side: types.SideLeft, Body: "This is a review comment"}
+	err := PostReviewComment(ctx, client, "owner", "repo", 1, comment)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if received["start_line"] != 8.0 || received["line"] != 10.0 || received["start_side"] != "LEFT" || received["side"] != "LEFT" || received["commit_id"] != "abc123" {
+		t.Errorf("Expected a comment on deleted lines 8-10, got %v", received)
+	}
 
-	err := PostReviewComment(ctx, client, owner, repo, prNumber, body, path, line)
+	comment = types.ReviewComment{Path: "example.go", StartLine: 10, Line: 10, Body: "Single line"}
+	if err := PostReviewComment(ctx, client, "owner", "repo", 1, comment); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := received["start_line"]; ok || received["side"] != "RIGHT" {
+		t.Errorf("Expected a single-line comment on the right side, got %v", received)
+	}
+}
+
+// TestSubmitReviewFallback tests that only the comments rejected by GitHub are posted as file-level
+// comments, while the others stay inline.
+func TestSubmitReviewFallback(t *testing.T) {
+	var reviews []github.PullRequestReviewRequest
+	var fileComments []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/pulls/1/reviews":
+			var request github.PullRequestReviewRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			reviews = append(reviews, request)
+			if request.GetCommitID() != "abc123" || request.GetEvent() != types.ReviewEventRequestChanges {
+				t.Errorf("Unexpected review request %+v", request)
+			}
+			for _, comment := range request.Comments {
+				if comment.GetLine() > 44 {
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					w.Write([]byte(`{"message":"Unprocessable Entity","errors":["Line could not be resolved"]}`))
+					return
+				}
+			}
+			w.Write([]byte(`{"id":1}`))
+		case "/repos/owner/repo/pulls/1/files":
+			w.Write([]byte(`[{"filename": "example.go", "patch": "@@ -40,3 +40,5 @@\n a\n+b\n+c\n d\n e"}]`))
+		case "/repos/owner/repo/pulls/1/comments":
+			var comment map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&comment)
+			fileComments = append(fileComments, comment)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":2}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	baseURL, _ := url.Parse(server.URL + "/")
+	client.BaseURL = baseURL
+
+	err := SubmitReview(context.Background(), client, "owner", "repo", 1, types.Review{
+		CommitID: "abc123",
+		Body:     "Summary",
+		Event:    types.ReviewEventRequestChanges,
+		Comments: []types.ReviewComment{
+			{Path: "example.go", StartLine: 41, Line: 42, Body: "Check the error."},
+			{Path: "example.go", Line: 60, Body: "Outside of the diff."},
+			{Path: "other.go", Line: 1, Body: "Not in the pull request."},
+		},
+	})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+
+	if len(reviews) != 2 || len(reviews[1].Comments) != 1 || reviews[1].Comments[0].GetLine() != 42 {
+		t.Fatalf("Expected the review to be retried with the comment in the diff, got %+v", reviews)
+	}
+	if len(fileComments) != 2 || fileComments[0]["subject_type"] != "file" || fileComments[0]["path"] != "example.go" ||
+		fileComments[1]["path"] != "other.go" {
+		t.Errorf("Expected file-level comments for the rejected comments only, got %v", fileComments)
+	}
+}
+
+// TestSubmitReviewUnprocessable tests that reviews GitHub refuses for reasons other than the lines of their
+// comments fail without falling back to file-level comments.
+func TestSubmitReviewUnprocessable(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"message":"Unprocessable Entity","errors":["Can not approve your own pull request"]}`))
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	baseURL, _ := url.Parse(server.URL + "/")
+	client.BaseURL = baseURL
+
+	err := SubmitReview(context.Background(), client, "owner", "repo", 1, types.Review{
+		CommitID: "abc123",
+		Event:    types.ReviewEventApprove,
+		Comments: []types.ReviewComment{{Path: "example.go", Line: 42, Body: "Nit."}},
+	})
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected a single review request, got %v", requests)
+	}
 }
 
 // TestExtractModifiedLinesWithNumbers tests the ExtractModifiedLinesWithNumbers function.
@@ -128,17 +320,25 @@ func TestExtractModifiedLinesWithNumbers(t *testing.T) {
 @@ -4,6 +4,7 @@
  var x = 10
 +var y = 20
+@@ -9,2 +10 @@
+ keep
+-gone
 `
 
 	expected := []types.ModifiedLine{
 		{
-			LineNumber: 1,
-			Content:    "-func Old() {}\n+func New() {}",
+			LineNumber:    1,
+			OldLineNumber: 1,
+			Content:       "-func Old() {}\n+func New() {}",
 		},
 		{
 			LineNumber: 5,
 			Content:    "+var y = 20",
 		},
+		{
+			OldLineNumber: 10,
+			Content:       "-gone",
+		},
 	}
 
 	result := ExtractModifiedLinesWithNumbers(patch)
@@ -147,7 +347,7 @@ func TestExtractModifiedLinesWithNumbers(t *testing.T) {
 	}
 
 	for i, modifiedLine := range result {
-		if modifiedLine.LineNumber != expected[i].LineNumber || modifiedLine.Content != expected[i].Content {
+		if modifiedLine != expected[i] {
 			t.Errorf("At index %d, expected %v, got %v", i, expected[i], modifiedLine)
 		}
 	}
@@ -0,0 +1,325 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultURL is the URL of gitlab.com.
+const DefaultURL = "https://gitlab.com"
+
+// Client talks to the REST API of gitlab.com or a self-managed GitLab instance.
+type Client struct {
+	// BaseURL is the URL of the instance, e.g. https://gitlab.example.com; /api/v4 is added.
+	BaseURL string
+	// Token is a personal, project or group access token with the api scope.
+	Token string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// DiffRefs are the commits a merge request diff is computed between.
+type DiffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	StartSHA string `json:"start_sha"`
+	HeadSHA  string `json:"head_sha"`
+}
+
+// MergeRequest holds the fields of a merge request used by the reviewer.
+type MergeRequest struct {
+	IID      int      `json:"iid"`
+	SHA      string   `json:"sha"`
+	DiffRefs DiffRefs `json:"diff_refs"`
+}
+
+// Version is a version of the diff of a merge request; every push to the merge request creates one.
+type Version struct {
+	HeadCommitSHA  string `json:"head_commit_sha"`
+	BaseCommitSHA  string `json:"base_commit_sha"`
+	StartCommitSHA string `json:"start_commit_sha"`
+}
+
+// DiffRefs returns the commits the diff of the version is computed between.
+func (v Version) DiffRefs() DiffRefs {
+	return DiffRefs{BaseSHA: v.BaseCommitSHA, StartSHA: v.StartCommitSHA, HeadSHA: v.HeadCommitSHA}
+}
+
+// Diff is the diff of a single file of a merge request. The diff text starts at the first hunk
+// header, without the file headers of git diff.
+type Diff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	// TooLarge reports that GitLab left out the diff of the file.
+	TooLarge bool `json:"too_large"`
+}
+
+// Comparison holds the file diffs between two commits.
+type Comparison struct {
+	Diffs []Diff `json:"diffs"`
+	// CompareTimeout reports that GitLab stopped computing the diffs, leaving out files.
+	CompareTimeout bool `json:"compare_timeout"`
+}
+
+// Position anchors a discussion to a line of a merge request diff.
+type Position struct {
+	PositionType string `json:"position_type"`
+	BaseSHA      string `json:"base_sha"`
+	StartSHA     string `json:"start_sha"`
+	HeadSHA      string `json:"head_sha"`
+	OldPath      string `json:"old_path"`
+	NewPath      string `json:"new_path"`
+	NewLine      int    `json:"new_line,omitempty"`
+	OldLine      int    `json:"old_line,omitempty"`
+}
+
+// NewClient creates a client for the instance at baseURL, gitlab.com when empty.
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &Client{BaseURL: baseURL, Token: token}
+}
+
+// MergeRequest fetches the merge request iid of the project, given by its full path such as
+// "group/subgroup/repo".
+func (c *Client) MergeRequest(ctx context.Context, project string, iid int) (*MergeRequest, error) {
+	var mr MergeRequest
+	if _, err := c.do(ctx, http.MethodGet, mergeRequestPath(project, iid), nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to retrieve merge request: %w", err)
+	}
+	return &mr, nil
+}
+
+// Versions fetches the latest diff versions of the merge request, the newest first.
+func (c *Client) Versions(ctx context.Context, project string, iid int) ([]Version, error) {
+	var versions []Version
+	if _, err := c.do(ctx, http.MethodGet, mergeRequestPath(project, iid)+"/versions?per_page=100", nil, &versions); err != nil {
+		return nil, fmt.Errorf("failed to retrieve merge request versions: %w", err)
+	}
+	return versions, nil
+}
+
+// MergeRequestDiffs fetches the file diffs of the merge request, following every page of the listing.
+// Instances older than GitLab 15.7 without the diffs endpoint are asked for the changes of the merge
+// request instead. The returned flag reports whether GitLab left out files or diffs.
+func (c *Client) MergeRequestDiffs(ctx context.Context, project string, iid int) ([]Diff, bool, error) {
+	var all []Diff
+	for page := 1; page > 0; {
+		var diffs []Diff
+		path := fmt.Sprintf("%s/diffs?per_page=100&page=%d", mergeRequestPath(project, iid), page)
+		resp, err := c.do(ctx, http.MethodGet, path, nil, &diffs)
+		if resp != nil && resp.StatusCode == http.StatusNotFound && page == 1 {
+			return c.mergeRequestChanges(ctx, project, iid)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to retrieve merge request diffs: %w", err)
+		}
+		all = append(all, diffs...)
+		page, _ = strconv.Atoi(resp.Header.Get("X-Next-Page"))
+	}
+	return all, tooLarge(all), nil
+}
+
+// mergeRequestChanges fetches the file diffs with the changes endpoint, which returns them at once.
+func (c *Client) mergeRequestChanges(ctx context.Context, project string, iid int) ([]Diff, bool, error) {
+	var changes struct {
+		Changes  []Diff `json:"changes"`
+		Overflow bool   `json:"overflow"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, mergeRequestPath(project, iid)+"/changes", nil, &changes); err != nil {
+		return nil, false, fmt.Errorf("failed to retrieve merge request changes: %w", err)
+	}
+	return changes.Changes, changes.Overflow || tooLarge(changes.Changes), nil
+}
+
+// Compare returns the file diffs from the commit from to the commit to, computed from their merge base.
+func (c *Client) Compare(ctx context.Context, project, from, to string) (*Comparison, error) {
+	var comparison Comparison
+	u := fmt.Sprintf("projects/%s/repository/compare?from=%s&to=%s", url.PathEscape(project), url.QueryEscape(from), url.QueryEscape(to))
+	if _, err := c.do(ctx, http.MethodGet, u, nil, &comparison); err != nil {
+		return nil, fmt.Errorf("failed to compare %s...%s: %w", from, to, err)
+	}
+	return &comparison, nil
+}
+
+// MergeBase returns the SHA of the common ancestor of the commits.
+func (c *Client) MergeBase(ctx context.Context, project string, refs ...string) (string, error) {
+	query := url.Values{}
+	for _, ref := range refs {
+		query.Add("refs[]", ref)
+	}
+	var commit struct {
+		ID string `json:"id"`
+	}
+	u := fmt.Sprintf("projects/%s/repository/merge_base?%s", url.PathEscape(project), query.Encode())
+	if _, err := c.do(ctx, http.MethodGet, u, nil, &commit); err != nil {
+		return "", fmt.Errorf("failed to retrieve merge base: %w", err)
+	}
+	return commit.ID, nil
+}
+
+// FileContent returns the content of the file at path in the commit ref.
+func (c *Client) FileContent(ctx context.Context, project, path, ref string) (string, error) {
+	var content string
+	u := fmt.Sprintf("projects/%s/repository/files/%s/raw?ref=%s", url.PathEscape(project), url.PathEscape(path), url.QueryEscape(ref))
+	if _, err := c.do(ctx, http.MethodGet, u, nil, &content); err != nil {
+		return "", fmt.Errorf("failed to retrieve %s: %w", path, err)
+	}
+	return content, nil
+}
+
+// CreateDiscussion starts a discussion on the merge request, on the diff line of the position or on
+// the merge request itself when the position is nil.
+func (c *Client) CreateDiscussion(ctx context.Context, project string, iid int, body string, position *Position) error {
+	request := struct {
+		Body     string    `json:"body"`
+		Position *Position `json:"position,omitempty"`
+	}{body, position}
+	if _, err := c.do(ctx, http.MethodPost, mergeRequestPath(project, iid)+"/discussions", request, nil); err != nil {
+		return fmt.Errorf("failed to create discussion: %w", err)
+	}
+	return nil
+}
+
+// CreateNote posts a comment on the merge request.
+func (c *Client) CreateNote(ctx context.Context, project string, iid int, body string) error {
+	request := struct {
+		Body string `json:"body"`
+	}{body}
+	if _, err := c.do(ctx, http.MethodPost, mergeRequestPath(project, iid)+"/notes", request, nil); err != nil {
+		return fmt.Errorf("failed to create note: %w", err)
+	}
+	return nil
+}
+
+// Approve approves the merge request at the head commit sha, failing if the head moved on.
+func (c *Client) Approve(ctx context.Context, project string, iid int, sha string) error {
+	request := struct {
+		SHA string `json:"sha,omitempty"`
+	}{sha}
+	if _, err := c.do(ctx, http.MethodPost, mergeRequestPath(project, iid)+"/approve", request, nil); err != nil {
+		return fmt.Errorf("failed to approve merge request: %w", err)
+	}
+	return nil
+}
+
+// FileDiffs converts the diffs of a merge request into code host independent diffs, counting the
+// added and deleted lines.
+func FileDiffs(diffs []Diff) []types.FileDiff {
+	files := make([]types.FileDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		file := types.FileDiff{Path: diff.NewPath, Patch: diff.Diff}
+		for _, line := range strings.Split(diff.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				file.Additions++
+			case strings.HasPrefix(line, "-"):
+				file.Deletions++
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// tooLarge reports whether GitLab left out the diff of a file.
+func tooLarge(diffs []Diff) bool {
+	for _, diff := range diffs {
+		if diff.TooLarge {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeRequestPath returns the API path of the merge request. The project path is escaped as a
+// single path segment.
+func mergeRequestPath(project string, iid int) string {
+	return fmt.Sprintf("projects/%s/merge_requests/%d", url.PathEscape(project), iid)
+}
+
+// do sends a request to the API, encoding the body and decoding the response into result when they
+// are not nil. A *string result receives the raw response body. The response is returned along with
+// API errors so callers can inspect the status.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+"/api/v4/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, newAPIError(resp.StatusCode, data)
+	}
+	switch result := result.(type) {
+	case nil:
+	case *string:
+		*result = string(data)
+	default:
+		if err := json.Unmarshal(data, result); err != nil {
+			return resp, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// newAPIError converts an error response into a typed error carrying the API's message. GitLab
+// answers with a "message" that is a string or an object of validation errors, or an "error".
+func newAPIError(statusCode int, body []byte) error {
+	var response struct {
+		Message json.RawMessage `json:"message"`
+		Error   string          `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return types.NewAPIError(statusCode, "", strings.TrimSpace(string(body)))
+	}
+
+	message := response.Error
+	if len(response.Message) > 0 {
+		var text string
+		if err := json.Unmarshal(response.Message, &text); err == nil {
+			message = text
+		} else {
+			message = string(response.Message)
+		}
+	}
+	return types.NewAPIError(statusCode, "", message)
+}
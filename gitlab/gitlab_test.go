@@ -0,0 +1,102 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestMergeRequestDiffs tests that every page of diffs is fetched for a project in a subgroup
+func TestMergeRequestDiffs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsub%2Frepo/merge_requests/7/diffs" {
+			t.Errorf("Unexpected path %s", r.URL.EscapedPath())
+		}
+		if r.Header.Get("PRIVATE-TOKEN") != "fake-token" {
+			t.Errorf("Expected the token header, got %v", r.Header)
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			fmt.Fprint(w, `[{"old_path":"a.go","new_path":"a.go","diff":"@@ -1,2 +1,2 @@\n-old\n+new\n ctx\n"}]`)
+		case "2":
+			w.Header().Set("X-Next-Page", "")
+			fmt.Fprint(w, `[{"old_path":"big.txt","new_path":"big.txt","diff":"","too_large":true}]`)
+		default:
+			t.Errorf("Unexpected page %s", r.URL.Query().Get("page"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "fake-token")
+	diffs, truncated, err := client.MergeRequestDiffs(context.Background(), "group/sub/repo", 7)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 2 || !truncated {
+		t.Fatalf("Expected 2 diffs with a too large one, got %d (truncated %v)", len(diffs), truncated)
+	}
+
+	files := FileDiffs(diffs[:1])
+	if files[0].Path != "a.go" || files[0].Additions != 1 || files[0].Deletions != 1 {
+		t.Errorf("Expected a.go with one added and one deleted line, got %+v", files[0])
+	}
+}
+
+// TestMergeRequestChangesFallback tests that instances without the diffs endpoint are asked for the changes
+func TestMergeRequestChangesFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/owner/repo/merge_requests/3/diffs":
+			http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+		case "/api/v4/projects/owner/repo/merge_requests/3/changes":
+			fmt.Fprint(w, `{"changes":[{"old_path":"a.go","new_path":"b.go","diff":"@@ -1 +1 @@\n-a\n+b\n","renamed_file":true}],"overflow":true}`)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	diffs, truncated, err := NewClient(server.URL, "fake-token").MergeRequestDiffs(context.Background(), "owner/repo", 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].OldPath != "a.go" || !truncated {
+		t.Errorf("Expected the renamed file of an overflowing change, got %+v (truncated %v)", diffs, truncated)
+	}
+}
+
+// TestAPIError tests that GitLab error messages are kept in typed errors
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message":"401 Unauthorized"}`)
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL, "bad-token").CreateNote(context.Background(), "owner/repo", 1, "Hello")
+	var apiErr *types.APIError
+	if !errors.Is(err, types.ErrAuth) || !errors.As(err, &apiErr) || apiErr.Message != "401 Unauthorized" {
+		t.Errorf("Expected an authentication error with the API's message, got %v", err)
+	}
+}
+
+// TestFileContent tests that the raw file is requested with the escaped path at the commit
+func TestFileContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Frepo/repository/files/cmd%2Fmain.go/raw" || r.URL.Query().Get("ref") != "abc123" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		fmt.Fprint(w, "package main\n")
+	}))
+	defer server.Close()
+
+	content, err := NewClient(server.URL, "fake-token").FileContent(context.Background(), "group/repo", "cmd/main.go", "abc123")
+	if err != nil || content != "package main\n" {
+		t.Errorf("Expected the raw file, got %q (%v)", content, err)
+	}
+}
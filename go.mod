@@ -3,11 +3,13 @@ module github.com/ozgen/go-chatgpt-pr-reviewer
 go 1.22.5
 
 require (
+	github.com/dlclark/regexp2 v1.11.5
 	github.com/google/go-github/v42 v42.0.0
 	github.com/joho/godotenv v1.5.1
 	github.com/spf13/cobra v1.8.1
 	github.com/stretchr/testify v1.9.0
 	golang.org/x/oauth2 v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -17,5 +19,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/crypto v0.19.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,212 @@
+package hook
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported git hooks.
+const (
+	PreCommit = "pre-commit"
+	PrePush   = "pre-push"
+)
+
+// SkipEnv is the environment variable that skips the review when set to a non-empty value.
+const SkipEnv = "PR_REVIEWER_SKIP"
+
+// marker identifies hook scripts written by Install.
+const marker = "# Installed by pr-reviewer."
+
+// zeroSHA is the object name git passes to pre-push hooks for refs that do not exist.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// emptyTree is the object name of the empty tree, the base of commits without parent.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// Validate reports hooks other than pre-commit and pre-push.
+func Validate(name string) error {
+	if name != PreCommit && name != PrePush {
+		return fmt.Errorf("unsupported hook %q, expected %s or %s", name, PreCommit, PrePush)
+	}
+	return nil
+}
+
+// Script returns the hook script running "<command> hook run <name>".
+func Script(name, command string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s
+# Reviews the changes with the model and fails when findings reach the configured severity.
+# Skip the review with "git %s --no-verify" or by setting %s=1.
+[ -n "$%s" ] && exit 0
+exec %s hook run %s "$@"
+`, marker, strings.TrimPrefix(name, "pre-"), SkipEnv, SkipEnv, shellQuote(command), name)
+}
+
+// Install writes the hook script into the hooks directory of the repository in dir and returns its
+// path. An existing hook that was not installed by pr-reviewer is only replaced when force is set.
+func Install(ctx context.Context, dir, name, command string, force bool) (string, error) {
+	if err := Validate(name); err != nil {
+		return "", err
+	}
+	hooksDir, err := git.HooksDir(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(hooksDir, name)
+
+	installed, err := isInstalled(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if err == nil && !installed && !force {
+		return "", fmt.Errorf("%s already exists and was not installed by pr-reviewer, use --force to replace it", path)
+	}
+
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(Script(name, command)), 0o755); err != nil {
+		return "", fmt.Errorf("failed to write hook: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	return path, os.Chmod(path, 0o755)
+}
+
+// Uninstall removes the hook from the repository in dir if it was installed by pr-reviewer and
+// returns its path.
+func Uninstall(ctx context.Context, dir, name string) (string, error) {
+	if err := Validate(name); err != nil {
+		return "", err
+	}
+	hooksDir, err := git.HooksDir(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(hooksDir, name)
+
+	installed, err := isInstalled(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%s is not installed", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !installed {
+		return "", fmt.Errorf("%s was not installed by pr-reviewer, leaving it in place", path)
+	}
+	return path, os.Remove(path)
+}
+
+// isInstalled reports whether the hook at path was written by Install.
+func isInstalled(path string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(string(content), marker), nil
+}
+
+// Changes returns the changes a hook reviews: the staged changes for pre-commit, and the commits
+// about to be pushed, read from the ref lines git writes to the hook's stdin, for pre-push. The
+// second value describes the changes for the report; the third lists warnings about pushed refs that
+// could not be diffed and are not reviewed.
+func Changes(ctx context.Context, dir, name string, stdin io.Reader) ([]types.FileDiff, string, []string, error) {
+	switch name {
+	case PreCommit:
+		opts := git.DiffOptions{Staged: true}
+		files, err := git.Diff(ctx, dir, opts)
+		return files, opts.String(), nil, err
+	case PrePush:
+		return pushChanges(ctx, dir, stdin)
+	}
+	return nil, "", nil, Validate(name)
+}
+
+// pushChanges diffs every ref of a push. Each stdin line reads
+// "<local ref> <local sha> <remote ref> <remote sha>". Refs that cannot be diffed are skipped with a
+// warning, so that the hook never keeps developers from pushing.
+func pushChanges(ctx context.Context, dir string, stdin io.Reader) ([]types.FileDiff, string, []string, error) {
+	var files []types.FileDiff
+	var ranges, warnings []string
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 4 || fields[1] == zeroSHA {
+			// Deleting a remote ref pushes no changes
+			continue
+		}
+		local, remote := fields[1], fields[3]
+
+		if remote == zeroSHA || !hasCommit(ctx, dir, remote) {
+			// A new branch, or a remote commit missing locally after a force-push or in a shallow
+			// clone: diff the commits that are not on any remote yet
+			base, err := unpushedBase(ctx, dir, local)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s not reviewed: %v", fields[0], err))
+				continue
+			}
+			remote = base
+		}
+
+		opts := git.DiffOptions{Range: remote + ".." + local}
+		diff, err := git.Diff(ctx, dir, opts)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s not reviewed: %v", fields[0], err))
+			continue
+		}
+		files = append(files, diff...)
+		ranges = append(ranges, opts.Range)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", nil, fmt.Errorf("failed to read pushed refs: %w", err)
+	}
+	return files, "git diff " + strings.Join(ranges, " "), warnings, nil
+}
+
+// unpushedBase returns the parent of the oldest commit of sha that no remote branch contains, or the
+// empty tree when the whole history is new.
+func unpushedBase(ctx context.Context, dir, sha string) (string, error) {
+	out, err := git.Run(ctx, dir, "rev-list", "--topo-order", "--reverse", sha, "--not", "--remotes")
+	if err != nil {
+		return "", err
+	}
+	commits := strings.Fields(out)
+	if len(commits) == 0 {
+		return sha, nil
+	}
+	parent, err := git.Run(ctx, dir, "rev-parse", "--verify", "--quiet", commits[0]+"^")
+	if err != nil {
+		return emptyTree, nil
+	}
+	return strings.TrimSpace(parent), nil
+}
+
+// hasCommit reports whether the commit sha is available in the repository.
+func hasCommit(ctx context.Context, dir, sha string) bool {
+	_, err := git.Run(ctx, dir, "cat-file", "-e", sha+"^{commit}")
+	return err == nil
+}
+
+// Blocking returns the findings of the report at or above the threshold.
+func Blocking(report *review.Report, threshold types.Severity) []review.Finding {
+	var blocking []review.Finding
+	for _, finding := range report.Findings() {
+		if finding.Severity.AtLeast(threshold) {
+			blocking = append(blocking, finding)
+		}
+	}
+	return blocking
+}
+
+// shellQuote quotes the value for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
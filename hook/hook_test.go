@@ -0,0 +1,169 @@
+package hook
+
+import (
+	"context"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo creates a git repository with one commit and returns its directory and a git runner.
+func testRepo(t *testing.T) (string, func(args ...string) string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		out, err := git.Run(context.Background(), dir, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(out)
+	}
+	run("init", "-q", "-b", "main")
+	writeFile(t, filepath.Join(dir, "a.go"), "package a\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	return dir, run
+}
+
+// writeFile writes a file for the test.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestInstallUninstall tests that hooks are written with the marker and foreign hooks are kept.
+func TestInstallUninstall(t *testing.T) {
+	dir, run := testRepo(t)
+	ctx := context.Background()
+
+	path, err := Install(ctx, dir, PreCommit, "/usr/local/bin/review", false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if path != filepath.Join(dir, ".git", "hooks", "pre-commit") {
+		t.Errorf("Unexpected hook path %s", path)
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "exec '/usr/local/bin/review' hook run pre-commit \"$@\"") {
+		t.Errorf("Unexpected hook script:\n%s", content)
+	}
+	if info, _ := os.Stat(path); info.Mode()&0o111 == 0 {
+		t.Errorf("Expected an executable hook, got mode %v", info.Mode())
+	}
+
+	// Reinstalling our own hook is fine
+	if _, err := Install(ctx, dir, PreCommit, "review", false); err != nil {
+		t.Errorf("Expected reinstalling to succeed, got %v", err)
+	}
+	if _, err := Uninstall(ctx, dir, PreCommit); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the hook to be removed, got %v", err)
+	}
+
+	// Foreign hooks are neither replaced nor removed
+	writeFile(t, path, "#!/bin/sh\nmake lint\n")
+	if _, err := Install(ctx, dir, PreCommit, "review", false); err == nil {
+		t.Error("Expected an error for a foreign hook")
+	}
+	if _, err := Uninstall(ctx, dir, PreCommit); err == nil {
+		t.Error("Expected an error when uninstalling a foreign hook")
+	}
+	if _, err := Install(ctx, dir, PreCommit, "review", true); err != nil {
+		t.Errorf("Expected --force to replace the hook, got %v", err)
+	}
+
+	// core.hooksPath is honored
+	run("config", "core.hooksPath", ".githooks")
+	path, err = Install(ctx, dir, PrePush, "review", false)
+	if err != nil || path != filepath.Join(dir, ".githooks", "pre-push") {
+		t.Errorf("Expected the hook in core.hooksPath, got %s (%v)", path, err)
+	}
+
+	if _, err := Install(ctx, dir, "post-merge", "review", false); err == nil {
+		t.Error("Expected an error for an unsupported hook")
+	}
+}
+
+// TestChanges tests collecting the staged changes and the commits of a push.
+func TestChanges(t *testing.T) {
+	dir, run := testRepo(t)
+	ctx := context.Background()
+	initial := run("rev-parse", "HEAD")
+
+	writeFile(t, filepath.Join(dir, "a.go"), "package a\n\nfunc A() {}\n")
+	run("add", ".")
+	files, source, _, err := Changes(ctx, dir, PreCommit, nil)
+	if err != nil || len(files) != 1 || files[0].Additions != 2 || source != "git diff --staged" {
+		t.Errorf("Unexpected staged changes %+v %q (%v)", files, source, err)
+	}
+
+	run("commit", "-q", "-m", "add A")
+	head := run("rev-parse", "HEAD")
+
+	// Pushing an existing branch diffs the remote and local commits
+	stdin := strings.NewReader("refs/heads/main " + head + " refs/heads/main " + initial + "\n")
+	files, source, _, err = Changes(ctx, dir, PrePush, stdin)
+	if err != nil || len(files) != 1 || files[0].Additions != 2 || source != "git diff "+initial+".."+head {
+		t.Errorf("Unexpected pushed changes %+v %q (%v)", files, source, err)
+	}
+
+	// A new branch without remotes diffs the whole history
+	stdin = strings.NewReader("refs/heads/main " + head + " refs/heads/main " + zeroSHA + "\n")
+	files, _, _, err = Changes(ctx, dir, PrePush, stdin)
+	if err != nil || len(files) != 1 || files[0].Additions != 3 {
+		t.Errorf("Unexpected changes of a new branch %+v (%v)", files, err)
+	}
+
+	// A remote commit missing locally, as after a force-push, falls back to the unpushed commits
+	missing := strings.Repeat("1", len(zeroSHA))
+	stdin = strings.NewReader("refs/heads/main " + head + " refs/heads/main " + missing + "\n")
+	files, _, _, err = Changes(ctx, dir, PrePush, stdin)
+	if err != nil || len(files) != 1 || files[0].Additions != 3 {
+		t.Errorf("Unexpected changes after a force-push %+v (%v)", files, err)
+	}
+
+	// Refs that cannot be diffed are skipped rather than failing the push
+	stdin = strings.NewReader("refs/heads/other " + missing + " refs/heads/other " + zeroSHA + "\n" +
+		"refs/heads/main " + head + " refs/heads/main " + initial + "\n")
+	files, _, warnings, err := Changes(ctx, dir, PrePush, stdin)
+	if err != nil || len(files) != 1 || files[0].Additions != 2 {
+		t.Errorf("Expected the unknown ref to be skipped, got %+v (%v)", files, err)
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "refs/heads/other not reviewed: ") {
+		t.Errorf("Expected a warning about the skipped ref, got %q", warnings)
+	}
+
+	// Deleting a remote branch pushes nothing
+	stdin = strings.NewReader("(delete) " + zeroSHA + " refs/heads/old " + head + "\n")
+	files, _, _, err = Changes(ctx, dir, PrePush, stdin)
+	if err != nil || len(files) != 0 {
+		t.Errorf("Expected no changes for a deleted branch, got %+v (%v)", files, err)
+	}
+}
+
+// TestBlocking tests the severity threshold of the hooks.
+func TestBlocking(t *testing.T) {
+	report := &review.Report{Files: []review.FileReport{{Path: "a.go", Findings: []review.Finding{
+		{Severity: types.SeverityMinor}, {Severity: types.SeverityMajor}, {Severity: types.SeverityCritical},
+	}}}}
+	if blocking := Blocking(report, types.SeverityMajor); len(blocking) != 2 {
+		t.Errorf("Expected 2 blocking findings, got %+v", blocking)
+	}
+	if blocking := Blocking(report, types.SeverityInfo); len(blocking) != 3 {
+		t.Errorf("Expected 3 blocking findings, got %+v", blocking)
+	}
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/anthropic"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/chatgpt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/ollama"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"strings"
+)
+
+// Supported LLM providers.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAzure     = "azure"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+)
+
+// Client is implemented by every LLM backend the review loop can talk to.
+type Client interface {
+	Complete(ctx context.Context, request types.CompletionRequest) (*types.CompletionResponse, error)
+}
+
+// NewClient creates the client for the given provider using the credentials from the configuration.
+// Failed requests are retried according to the policy; completions have no side effects, so they are
+// retried on server and network errors too.
+func NewClient(provider string, cfg config.Config, policy retry.Policy) (Client, error) {
+	httpClient := &http.Client{Transport: &retry.Transport{Policy: policy, RetryUnsafe: true}}
+	switch strings.ToLower(provider) {
+	case ProviderOpenAI, "":
+		client := chatgpt.NewChatGPTClient(cfg.OpenAIApiKey, cfg.OrganizationId, cfg.ProjectId)
+		if cfg.OpenAIModel != "" {
+			client.Model = cfg.OpenAIModel
+		}
+		client.HTTPClient = httpClient
+		return client, nil
+	case ProviderAzure:
+		if cfg.AzureEndpoint == "" || cfg.AzureDeployment == "" {
+			return nil, fmt.Errorf("azure provider requires AZURE_OPENAI_ENDPOINT and AZURE_OPENAI_DEPLOYMENT")
+		}
+		client := chatgpt.NewAzureClient(cfg.AzureApiKey, cfg.AzureEndpoint, cfg.AzureDeployment, cfg.AzureApiVersion)
+		client.HTTPClient = httpClient
+		return client, nil
+	case ProviderAnthropic:
+		client := anthropic.NewClient(cfg.AnthropicApiKey)
+		if cfg.AnthropicModel != "" {
+			client.Model = cfg.AnthropicModel
+		}
+		client.HTTPClient = httpClient
+		return client, nil
+	case ProviderOllama:
+		client := ollama.NewClient(cfg.OllamaURL)
+		if cfg.OllamaModel != "" {
+			client.Model = cfg.OllamaModel
+		}
+		client.HTTPClient = httpClient
+		return client, nil
+	}
+	return nil, fmt.Errorf("unknown LLM provider %q", provider)
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"github.com/ozgen/go-chatgpt-pr-reviewer/anthropic"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/chatgpt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/ollama"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"testing"
+)
+
+// TestNewClient tests that each provider name selects the matching backend.
+func TestNewClient(t *testing.T) {
+	cfg := config.Config{
+		OpenAIApiKey:    "fake-openai-key",
+		AnthropicApiKey: "fake-anthropic-key",
+		AzureEndpoint:   "https://example.openai.azure.com/",
+		AzureApiKey:     "fake-azure-key",
+		AzureDeployment: "gpt-4o-review",
+		OllamaModel:     "qwen2.5-coder",
+	}
+
+	client, err := NewClient(ProviderOpenAI, cfg, retry.DefaultPolicy())
+	if _, ok := client.(*chatgpt.ChatGPTClient); err != nil || !ok {
+		t.Errorf("Expected OpenAI client, got %T (%v)", client, err)
+	}
+
+	client, err = NewClient(ProviderAzure, cfg, retry.DefaultPolicy())
+	azure, ok := client.(*chatgpt.ChatGPTClient)
+	if err != nil || !ok || !azure.Azure {
+		t.Fatalf("Expected Azure client, got %T (%v)", client, err)
+	}
+	expectedURL := "https://example.openai.azure.com/openai/deployments/gpt-4o-review/chat/completions?api-version=2024-10-21"
+	if azure.APIURL != expectedURL {
+		t.Errorf("Expected URL '%s', got '%s'", expectedURL, azure.APIURL)
+	}
+
+	client, err = NewClient(ProviderAnthropic, cfg, retry.DefaultPolicy())
+	if _, ok := client.(*anthropic.Client); err != nil || !ok {
+		t.Errorf("Expected Anthropic client, got %T (%v)", client, err)
+	}
+
+	client, err = NewClient(ProviderOllama, cfg, retry.DefaultPolicy())
+	local, ok := client.(*ollama.Client)
+	if err != nil || !ok || local.Model != "qwen2.5-coder" {
+		t.Errorf("Expected Ollama client with configured model, got %T (%v)", client, err)
+	}
+
+	if _, err := NewClient("unknown", cfg, retry.DefaultPolicy()); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
+
+// TestLookupModel tests that model names match the longest registered family.
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		name          string
+		contextWindow int
+		encoding      string
+	}{
+		{"gpt-4o-2024-08-06", 128000, "o200k_base"},
+		{"gpt-4-0613", 8192, "cl100k_base"},
+		{"gpt-4-turbo-preview", 128000, "cl100k_base"},
+		{"o1-mini-2024-09-12", 128000, "o200k_base"},
+		{"claude-sonnet-4-0", 200000, "cl100k_base"},
+		{"my-deployment", 8192, "cl100k_base"},
+	}
+	for _, test := range tests {
+		model := LookupModel(test.name)
+		if model.Name != test.name || model.ContextWindow != test.contextWindow || model.Encoding != test.encoding {
+			t.Errorf("%s: expected a context window of %d with %s, got %+v", test.name, test.contextWindow, test.encoding, model)
+		}
+	}
+
+	cfg := config.Config{AzureDeployment: "review", OllamaModel: "qwen2.5-coder"}
+	for provider, expected := range map[string]string{
+		ProviderOpenAI:    chatgpt.DefaultModel,
+		ProviderAzure:     "review",
+		ProviderAnthropic: anthropic.DefaultModel,
+		ProviderOllama:    "qwen2.5-coder",
+	} {
+		if name := ModelName(provider, "", cfg); name != expected {
+			t.Errorf("%s: expected model %s, got %s", provider, expected, name)
+		}
+	}
+	if name := ModelName(ProviderOpenAI, "gpt-4.1", cfg); name != "gpt-4.1" {
+		t.Errorf("Expected the selected model, got %s", name)
+	}
+}
+
+// TestModelCost tests that configured prices override the registry and convert usage to dollars.
+func TestModelCost(t *testing.T) {
+	model := LookupModel("gpt-4o-2024-08-06")
+	usage := types.Usage{PromptTokens: 1000000, CompletionTokens: 100000, TotalTokens: 1100000}
+	if cost := model.Cost(usage); cost != 3.5 {
+		t.Errorf("Expected $3.50 at the list price, got %v", cost)
+	}
+
+	prices := map[string]config.Price{"gpt": {Input: 1, Output: 1}, "gpt-4o": {Input: 2, Output: 10}}
+	if cost := model.WithPrices(prices).Cost(usage); cost != 3 {
+		t.Errorf("Expected $3.00 at the configured price, got %v", cost)
+	}
+
+	if model := LookupModel("llama3.1"); model.Priced() {
+		t.Errorf("Expected no price for an unknown model, got %+v", model.Price)
+	}
+	if model := LookupModel("llama3.1").WithPrices(map[string]config.Price{"llama": {Input: 0.1}}); !model.Priced() {
+		t.Error("Expected the configured price for an unknown model")
+	}
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"github.com/ozgen/go-chatgpt-pr-reviewer/anthropic"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/chatgpt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/ollama"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/tokenizer"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"strings"
+)
+
+// Model describes the token limits and the price of a chat model.
+type Model struct {
+	Name string
+	// ContextWindow is the number of tokens of the prompt and the answer together.
+	ContextWindow   int
+	MaxOutputTokens int
+	// Encoding is the tokenizer encoding counting the tokens of prompts. Models of other vendors are
+	// counted with an OpenAI encoding, which approximates their tokenizers.
+	Encoding string
+	// Price is zero when the price of the model is unknown.
+	Price config.Price
+}
+
+// models lists the known models with their list prices; a model name matches the longest name it starts
+// with, so that dated snapshots such as gpt-4o-2024-08-06 share the limits and prices of their family.
+var models = []Model{
+	{Name: "gpt-5", ContextWindow: 400000, MaxOutputTokens: 128000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 1.25, Output: 10}},
+	{Name: "gpt-5-mini", ContextWindow: 400000, MaxOutputTokens: 128000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 0.25, Output: 2}},
+	{Name: "gpt-5-nano", ContextWindow: 400000, MaxOutputTokens: 128000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 0.05, Output: 0.4}},
+	{Name: "gpt-4.1", ContextWindow: 1047576, MaxOutputTokens: 32768, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 2, Output: 8}},
+	{Name: "gpt-4.1-mini", ContextWindow: 1047576, MaxOutputTokens: 32768, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 0.4, Output: 1.6}},
+	{Name: "gpt-4.1-nano", ContextWindow: 1047576, MaxOutputTokens: 32768, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 0.1, Output: 0.4}},
+	{Name: "gpt-4o", ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 2.5, Output: 10}},
+	{Name: "gpt-4o-mini", ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 0.15, Output: 0.6}},
+	{Name: "chatgpt-4o", ContextWindow: 128000, MaxOutputTokens: 16384, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 5, Output: 15}},
+	{Name: "gpt-4-turbo", ContextWindow: 128000, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 10, Output: 30}},
+	{Name: "gpt-4-32k", ContextWindow: 32768, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 60, Output: 120}},
+	{Name: "gpt-4", ContextWindow: 8192, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 30, Output: 60}},
+	{Name: "gpt-3.5-turbo", ContextWindow: 16385, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 0.5, Output: 1.5}},
+	{Name: "o1", ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 15, Output: 60}},
+	{Name: "o1-mini", ContextWindow: 128000, MaxOutputTokens: 65536, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 1.1, Output: 4.4}},
+	{Name: "o3", ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 2, Output: 8}},
+	{Name: "o3-mini", ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 1.1, Output: 4.4}},
+	{Name: "o4-mini", ContextWindow: 200000, MaxOutputTokens: 100000, Encoding: tokenizer.O200KBase, Price: config.Price{Input: 1.1, Output: 4.4}},
+	{Name: "claude-opus-4", ContextWindow: 200000, MaxOutputTokens: 32000, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 15, Output: 75}},
+	{Name: "claude-sonnet-4", ContextWindow: 200000, MaxOutputTokens: 64000, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 3, Output: 15}},
+	{Name: "claude-3-7-sonnet", ContextWindow: 200000, MaxOutputTokens: 64000, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 3, Output: 15}},
+	{Name: "claude-3-5-sonnet", ContextWindow: 200000, MaxOutputTokens: 8192, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 3, Output: 15}},
+	{Name: "claude-3-5-haiku", ContextWindow: 200000, MaxOutputTokens: 8192, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 0.8, Output: 4}},
+	{Name: "claude-3-opus", ContextWindow: 200000, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 15, Output: 75}},
+	{Name: "claude-3-haiku", ContextWindow: 200000, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase, Price: config.Price{Input: 0.25, Output: 1.25}},
+}
+
+// defaultModel holds the limits assumed for unknown models, such as Azure deployments and Ollama
+// models, whose context window depends on the deployment or server. Their price is unknown.
+var defaultModel = Model{ContextWindow: 8192, MaxOutputTokens: 4096, Encoding: tokenizer.CL100KBase}
+
+// LookupModel returns the limits of the named model from the registry, or conservative defaults for
+// unknown models.
+func LookupModel(name string) Model {
+	found := defaultModel
+	for _, model := range models {
+		if strings.HasPrefix(name, model.Name) && len(model.Name) > len(found.Name) {
+			found = model
+		}
+	}
+	found.Name = name
+	return found
+}
+
+// WithPrices returns the model with the price of the longest name of the configured price table that
+// the model name starts with, if any.
+func (m Model) WithPrices(prices map[string]config.Price) Model {
+	matched := ""
+	for name, price := range prices {
+		if strings.HasPrefix(m.Name, name) && len(name) >= len(matched) {
+			m.Price, matched = price, name
+		}
+	}
+	return m
+}
+
+// Priced reports whether the price of the model is known.
+func (m Model) Priced() bool {
+	return m.Price != config.Price{}
+}
+
+// Cost returns the price of the usage in US dollars.
+func (m Model) Cost(usage types.Usage) float64 {
+	return (float64(usage.PromptTokens)*m.Price.Input + float64(usage.CompletionTokens)*m.Price.Output) / 1e6
+}
+
+// ModelName returns the model requests to the provider use: model if set, otherwise the configured or
+// built-in model of the provider.
+func ModelName(provider, model string, cfg config.Config) string {
+	if model != "" {
+		return model
+	}
+	var configured, builtIn string
+	switch strings.ToLower(provider) {
+	case ProviderOpenAI, "":
+		configured, builtIn = cfg.OpenAIModel, chatgpt.DefaultModel
+	case ProviderAzure:
+		configured = cfg.AzureDeployment
+	case ProviderAnthropic:
+		configured, builtIn = cfg.AnthropicModel, anthropic.DefaultModel
+	case ProviderOllama:
+		configured, builtIn = cfg.OllamaModel, ollama.DefaultModel
+	}
+	if configured != "" {
+		return configured
+	}
+	return builtIn
+}
@@ -0,0 +1,129 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultModel is the model used when none is configured.
+const DefaultModel = "llama3.1"
+
+const (
+	defaultBaseURL = "http://localhost:11434"
+)
+
+// Client holds the configuration for a local Ollama server.
+type Client struct {
+	BaseURL string
+	Model   string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// chatRequest represents the data sent to the Ollama chat endpoint.
+type chatRequest struct {
+	Model    string              `json:"model"`
+	Messages []types.ChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Format   json.RawMessage     `json:"format,omitempty"`
+	Options  chatOptions         `json:"options"`
+}
+
+// chatOptions holds the sampling options supported by Ollama.
+type chatOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+	// NumCtx is the context window; Ollama's default is much smaller than most models support and
+	// silently drops the start of longer prompts.
+	NumCtx int `json:"num_ctx,omitempty"`
+}
+
+// chatResponse represents the structure of data received from the Ollama chat endpoint.
+type chatResponse struct {
+	Model           string            `json:"model"`
+	Message         types.ChatMessage `json:"message"`
+	PromptEvalCount int               `json:"prompt_eval_count"`
+	EvalCount       int               `json:"eval_count"`
+	Error           string            `json:"error"`
+}
+
+// NewClient creates a new Ollama client. An empty base URL selects the default local server.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Model:   DefaultModel,
+	}
+}
+
+// Complete sends the conversation to the Ollama chat endpoint and returns the response message.
+// It implements the llm.Client interface.
+func (c *Client) Complete(ctx context.Context, request types.CompletionRequest) (*types.CompletionResponse, error) {
+	payload := chatRequest{
+		Model:    request.Model,
+		Messages: request.Messages,
+		Options: chatOptions{
+			Temperature: request.Temperature,
+			NumPredict:  request.MaxTokens,
+			NumCtx:      request.ContextWindow,
+		},
+	}
+	if payload.Model == "" {
+		payload.Model = c.Model
+	}
+	if request.JSONSchema != nil {
+		payload.Format = request.JSONSchema.Schema
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/api/chat", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var response chatResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, types.NewAPIError(resp.StatusCode, "", strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("failed to unmarshal response: %v, body: %s", err, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, types.NewAPIError(resp.StatusCode, "", response.Error)
+	}
+
+	usage := types.Usage{
+		PromptTokens:     response.PromptEvalCount,
+		CompletionTokens: response.EvalCount,
+		TotalTokens:      response.PromptEvalCount + response.EvalCount,
+	}
+	return &types.CompletionResponse{Content: response.Message.Content, Model: response.Model, Usage: usage}, nil
+}
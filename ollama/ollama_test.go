@@ -0,0 +1,69 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCompleteSuccess tests a non-streaming chat request against a mock Ollama server
+func TestCompleteSuccess(t *testing.T) {
+	var received chatRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Expected path /api/chat, got %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"model":"llama3.1","message":{"role":"assistant","content":"No issues found."},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL + "/")
+
+	response, err := client.Complete(context.Background(), types.CompletionRequest{
+		Model:         "qwen2.5-coder",
+		Messages:      []types.ChatMessage{{Role: types.RoleUser, Content: "Review this."}},
+		Temperature:   0.1,
+		MaxTokens:     200,
+		ContextWindow: 8192,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if response.Content != "No issues found." {
+		t.Errorf("Expected response 'No issues found.', got '%s'", response.Content)
+	}
+	if received.Stream {
+		t.Error("Expected streaming to be disabled")
+	}
+	if received.Model != "qwen2.5-coder" || received.Options.NumPredict != 200 || received.Options.NumCtx != 8192 {
+		t.Errorf("Expected model and options to be forwarded, got %+v", received)
+	}
+}
+
+// TestCompleteError tests the case where Ollama reports an error
+func TestCompleteError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"model 'missing' not found"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+
+	_, err := client.Complete(context.Background(), types.CompletionRequest{
+		Model:    "missing",
+		Messages: []types.ChatMessage{{Role: types.RoleUser, Content: "Review this."}},
+	})
+	if err == nil {
+		t.Fatal("Expected an error, but got none")
+	}
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"strings"
+)
+
+// writeMarkdown renders the report as a Markdown document with a summary table and a section per file.
+func writeMarkdown(w io.Writer, report *review.Report) error {
+	var doc strings.Builder
+	if report.Source != "" {
+		fmt.Fprintf(&doc, "# Review of `%s`\n\n", report.Source)
+	} else {
+		fmt.Fprintf(&doc, "# Review of %s/%s#%d\n\n", report.Owner, report.Repo, report.PRNumber)
+	}
+	if report.Since != "" {
+		fmt.Fprintf(&doc, "Changes since `%s` only.\n\n", report.Since)
+	}
+
+	findings := report.Findings()
+	counts := make(map[types.Severity]int)
+	for _, finding := range findings {
+		counts[finding.Severity]++
+	}
+	doc.WriteString("| Severity | Findings |\n|----------|----------|\n")
+	for _, severity := range []types.Severity{types.SeverityCritical, types.SeverityMajor, types.SeverityMinor, types.SeverityInfo} {
+		fmt.Fprintf(&doc, "| %s | %d |\n", severity, counts[severity])
+	}
+	fmt.Fprintf(&doc, "\n%d files reviewed, %d findings, %d tokens used", len(report.Files), len(findings), report.Usage.TotalTokens)
+	if report.Cost > 0 {
+		fmt.Fprintf(&doc, " ($%.4f)", report.Cost)
+	}
+	doc.WriteString(".\n")
+	if report.Cached > 0 {
+		fmt.Fprintf(&doc, "%d hunks were answered from the cache.\n", report.Cached)
+	}
+	if len(report.Unreviewed) > 0 {
+		fmt.Fprintf(&doc, "\n> **Warning:** the review budget was exceeded, %d files are not reviewed: `%s`.\n", len(report.Unreviewed), strings.Join(report.Unreviewed, "`, `"))
+	}
+	if report.Truncated {
+		if report.SkippedFiles > 0 {
+			fmt.Fprintf(&doc, "\n> **Warning:** the code host truncated the file list, %d changed files are not reviewed.\n", report.SkippedFiles)
+		} else {
+			fmt.Fprintf(&doc, "\n> **Warning:** the code host truncated the file list at %d files, remaining files are not reviewed.\n", report.ListedFiles)
+		}
+	}
+
+	for _, file := range report.Files {
+		if len(file.Findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(&doc, "\n## `%s`\n", file.Path)
+		for _, finding := range file.Findings {
+			fmt.Fprintf(&doc, "\n### %s (%s)\n\n", markdownTitle(finding), lineRange(finding))
+			fmt.Fprintf(&doc, "**Severity:** %s · **Category:** %s\n\n%s\n", finding.Severity, finding.Category, finding.Message)
+			if finding.SuggestedFix != "" {
+				fmt.Fprintf(&doc, "\n```\n%s\n```\n", finding.SuggestedFix)
+			}
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		doc.WriteString("\n## Not reviewed\n\n")
+		for _, failure := range report.Failures {
+			fmt.Fprintf(&doc, "- `%s` line %d: %v\n", failure.Path, failure.Line, failure.Err)
+		}
+	}
+
+	_, err := io.WriteString(w, doc.String())
+	return err
+}
+
+// markdownTitle returns the heading of a finding.
+func markdownTitle(finding review.Finding) string {
+	if finding.Title == "" {
+		return "Finding"
+	}
+	return finding.Title
+}
+
+// lineRange renders the lines of a finding as "line 12", "line 12-15" or "deleted line 12".
+func lineRange(finding review.Finding) string {
+	lines := fmt.Sprintf("line %d", finding.StartLine)
+	if finding.EndLine > finding.StartLine {
+		lines = fmt.Sprintf("line %d-%d", finding.StartLine, finding.EndLine)
+	}
+	if finding.Deleted() {
+		return "deleted " + lines
+	}
+	return lines
+}
@@ -0,0 +1,119 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"sort"
+	"strings"
+)
+
+// toolName identifies the reviewer in machine-readable reports.
+const toolName = "pr-reviewer"
+
+// toolURI points to the project's homepage in machine-readable reports.
+const toolURI = "https://github.com/ozgen/go-chatgpt-pr-reviewer"
+
+// writers maps every supported format to the function rendering a report in it.
+var writers = map[string]func(io.Writer, *review.Report) error{
+	"text":       writeText,
+	"json":       writeJSON,
+	"sarif":      writeSARIF,
+	"markdown":   writeMarkdown,
+	"checkstyle": writeCheckstyle,
+	"junit":      writeJUnit,
+}
+
+// Formats returns the names of the supported formats.
+func Formats() []string {
+	formats := make([]string, 0, len(writers))
+	for format := range writers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Supports reports whether the format is supported.
+func Supports(format string) bool {
+	_, ok := writers[strings.ToLower(format)]
+	return ok
+}
+
+// Write renders the report in the given format.
+func Write(w io.Writer, format string, report *review.Report) error {
+	writer, ok := writers[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unknown output format %q, supported formats: %s", format, strings.Join(Formats(), ", "))
+	}
+	return writer(w, report)
+}
+
+// writeText renders the report as human-readable text.
+func writeText(w io.Writer, report *review.Report) error {
+	if report.Source != "" {
+		fmt.Fprintf(w, "Local changes: %s\n", report.Source)
+	} else {
+		fmt.Fprintf(w, "Owner: %s, Repo: %s\n", report.Owner, report.Repo)
+	}
+
+	// Display the files with changes
+	for _, file := range report.Files {
+		fmt.Fprintf(w, "File: %s, Changes: +%d -%d\n", file.Path, file.Additions, file.Deletions)
+	}
+	if report.Truncated {
+		if report.SkippedFiles > 0 {
+			fmt.Fprintf(w, "Warning: the code host truncated the file list, %d changed files are not reviewed\n", report.SkippedFiles)
+		} else {
+			fmt.Fprintf(w, "Warning: the code host truncated the file list at %d files, remaining files are not reviewed\n", report.ListedFiles)
+		}
+	}
+
+	for _, finding := range report.Findings() {
+		line := "line"
+		if finding.Deleted() {
+			line = "deleted line"
+		}
+		fmt.Fprintf(w, "Feedback for file %s at %s %d (%s, %s): %s\n%s\n", finding.Path, line, finding.StartLine, finding.Severity, finding.Category, finding.Title, finding.Message)
+		if finding.SuggestedFix != "" {
+			fmt.Fprintf(w, "Suggested fix:\n%s\n", finding.SuggestedFix)
+		}
+	}
+	for _, failure := range report.Failures {
+		fmt.Fprintf(w, "Failed to review file %s at line %d: %v\n", failure.Path, failure.Line, failure.Err)
+	}
+	if len(report.Unreviewed) > 0 {
+		fmt.Fprintf(w, "Warning: the review budget was exceeded, files not reviewed: %s\n", strings.Join(report.Unreviewed, ", "))
+	}
+	if report.Cached > 0 {
+		fmt.Fprintf(w, "Reused %d cached answers\n", report.Cached)
+	}
+	fmt.Fprintf(w, "Token usage: %d prompt, %d completion", report.Usage.PromptTokens, report.Usage.CompletionTokens)
+	if report.Cost > 0 {
+		fmt.Fprintf(w, ", cost $%.4f", report.Cost)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeJSON renders the report as indented JSON.
+func writeJSON(w io.Writer, report *review.Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// findingText renders the title and message of a finding as a single message.
+func findingText(finding review.Finding) string {
+	if finding.Title == "" {
+		return finding.Message
+	}
+	return finding.Title + ": " + finding.Message
+}
+
+// isError reports whether the finding is serious enough to be reported as an error by CI tools.
+func isError(severity types.Severity) bool {
+	return severity.AtLeast(types.SeverityMajor)
+}
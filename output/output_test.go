@@ -0,0 +1,247 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"strings"
+	"testing"
+)
+
+// testReport returns a report with findings of every kind.
+func testReport() *review.Report {
+	return &review.Report{
+		Owner:    "owner",
+		Repo:     "repo",
+		PRNumber: 7,
+		Files: []review.FileReport{
+			{
+				Path: "main.go",
+				Findings: []review.Finding{
+					{Path: "main.go", StartLine: 10, EndLine: 12, Severity: types.SeverityMajor, Category: "bug",
+						Title: "Unchecked error", Message: "The error is ignored.", SuggestedFix: "return err"},
+					{Path: "main.go", StartLine: 20, EndLine: 20, Severity: types.SeverityInfo, Category: "style",
+						Title: "Naming", Message: "Use camelCase & short names."},
+				},
+			},
+			{Path: "util.go"},
+		},
+		Failures: []review.Failure{{Path: "util.go", Line: 3, Err: errors.New("rate limited")}},
+		Usage:    types.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+	}
+}
+
+// TestWriteUnknownFormat tests that unknown formats are rejected.
+func TestWriteUnknownFormat(t *testing.T) {
+	if Supports("yaml") {
+		t.Error("Expected yaml not to be supported")
+	}
+	if err := Write(&bytes.Buffer{}, "yaml", testReport()); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// TestWriteJSON tests that the JSON report round-trips and includes failure messages.
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "json", testReport()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var decoded struct {
+		Files []struct {
+			Findings []struct {
+				StartLine int    `json:"start_line"`
+				Severity  string `json:"severity"`
+			} `json:"findings"`
+		} `json:"files"`
+		Failures []struct {
+			Error string `json:"error"`
+		} `json:"failures"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if len(decoded.Files) != 2 || decoded.Files[0].Findings[0].StartLine != 10 || decoded.Files[0].Findings[0].Severity != "major" {
+		t.Errorf("Unexpected JSON report %s", buf.String())
+	}
+	if len(decoded.Failures) != 1 || decoded.Failures[0].Error != "rate limited" {
+		t.Errorf("Expected the failure message, got %+v", decoded.Failures)
+	}
+}
+
+// TestWriteSARIF tests the SARIF levels, rules and locations, which have no region for deleted lines.
+func TestWriteSARIF(t *testing.T) {
+	report := testReport()
+	report.Files[0].Findings[1].Side = types.SideLeft
+	var buf bytes.Buffer
+	if err := Write(&buf, "sarif", report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a single SARIF 2.1.0 run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "bug" {
+		t.Errorf("Expected a rule per category, got %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 2 || run.Results[0].Level != "error" || run.Results[1].Level != "note" {
+		t.Fatalf("Unexpected results %+v", run.Results)
+	}
+	region := run.Results[0].Locations[0].PhysicalLocation.Region
+	if region == nil || region.StartLine != 10 || region.EndLine != 12 {
+		t.Errorf("Expected region 10-12, got %+v", region)
+	}
+	if region := run.Results[1].Locations[0].PhysicalLocation.Region; region != nil {
+		t.Errorf("Expected no region for deleted lines, got %+v", region)
+	}
+}
+
+// TestWriteCheckstyle tests that the Checkstyle report is valid XML with escaped messages.
+func TestWriteCheckstyle(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "checkstyle", testReport()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var doc checkstyleReport
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid XML, got %v", err)
+	}
+	if len(doc.Files) != 1 || len(doc.Files[0].Errors) != 2 {
+		t.Fatalf("Expected one file with two errors, got %+v", doc.Files)
+	}
+	if doc.Files[0].Errors[1].Message != "Naming: Use camelCase & short names." || doc.Files[0].Errors[0].Severity != "error" {
+		t.Errorf("Unexpected errors %+v", doc.Files[0].Errors)
+	}
+}
+
+// TestWriteJUnit tests the test suites, failures and errors of the JUnit report.
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "junit", testReport()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid XML, got %v", err)
+	}
+	if len(doc.Suites) != 2 {
+		t.Fatalf("Expected a suite per file, got %d", len(doc.Suites))
+	}
+	if doc.Suites[0].Tests != 2 || doc.Suites[0].Failures != 2 {
+		t.Errorf("Expected two failed tests for main.go, got %+v", doc.Suites[0])
+	}
+	if doc.Suites[1].Errors != 1 || doc.Suites[1].Cases[0].Error == nil {
+		t.Errorf("Expected the review failure as an error for util.go, got %+v", doc.Suites[1])
+	}
+}
+
+// TestWriteMarkdownAndText tests the human-readable formats.
+func TestWriteMarkdownAndText(t *testing.T) {
+	var markdown bytes.Buffer
+	if err := Write(&markdown, "markdown", testReport()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, expected := range []string{"# Review of owner/repo#7", "| major | 1 |", "## `main.go`", "### Unchecked error (line 10-12)", "## Not reviewed"} {
+		if !strings.Contains(markdown.String(), expected) {
+			t.Errorf("Expected markdown to contain %q, got:\n%s", expected, markdown.String())
+		}
+	}
+
+	var text bytes.Buffer
+	if err := Write(&text, "TEXT", testReport()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(text.String(), "Feedback for file main.go at line 10 (major, bug): Unchecked error") {
+		t.Errorf("Unexpected text output:\n%s", text.String())
+	}
+}
+
+// TestWriteCost tests that the cost and the files left unreviewed by the budget are reported.
+func TestWriteCost(t *testing.T) {
+	report := testReport()
+	report.Cost = 0.0125
+	report.Unreviewed = []string{"util.go"}
+	report.Cached = 2
+
+	var text, markdown bytes.Buffer
+	if err := Write(&text, "text", report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := Write(&markdown, "markdown", report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(text.String(), "Token usage: 100 prompt, 20 completion, cost $0.0125\n") ||
+		!strings.Contains(text.String(), "files not reviewed: util.go") || !strings.Contains(text.String(), "Reused 2 cached answers\n") {
+		t.Errorf("Unexpected text output:\n%s", text.String())
+	}
+	if !strings.Contains(markdown.String(), "120 tokens used ($0.0125).") || !strings.Contains(markdown.String(), "1 files are not reviewed: `util.go`") {
+		t.Errorf("Unexpected markdown output:\n%s", markdown.String())
+	}
+}
+
+// TestWriteTruncated tests the warning about a file list truncated by the code host.
+func TestWriteTruncated(t *testing.T) {
+	report := testReport()
+	report.Host = "gitlab.com"
+	report.Truncated, report.SkippedFiles = true, 5
+
+	for format, expected := range map[string]string{
+		"text":     "Warning: the code host truncated the file list, 5 changed files are not reviewed",
+		"markdown": "**Warning:** the code host truncated the file list, 5 changed files are not reviewed.",
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, report); err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		if !strings.Contains(buf.String(), expected) || strings.Contains(buf.String(), "GitHub") {
+			t.Errorf("%s: expected a host-neutral warning %q, got %s", format, expected, buf.String())
+		}
+	}
+
+	// Without a total, the warning names the number of files the host listed rather than reviewed
+	report.SkippedFiles, report.ListedFiles = 0, 1000
+	for format, expected := range map[string]string{
+		"text":     "Warning: the code host truncated the file list at 1000 files, remaining files are not reviewed",
+		"markdown": "**Warning:** the code host truncated the file list at 1000 files, remaining files are not reviewed.",
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, report); err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("%s: expected the warning %q, got %s", format, expected, buf.String())
+		}
+	}
+}
+
+// TestWriteLocalSource tests that reports of local diffs name the diff instead of a pull request.
+func TestWriteLocalSource(t *testing.T) {
+	report := testReport()
+	report.Owner, report.Repo, report.PRNumber = "", "", 0
+	report.Source = "git diff --staged"
+
+	var text, markdown bytes.Buffer
+	if err := Write(&text, "text", report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := Write(&markdown, "markdown", report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(text.String(), "Local changes: git diff --staged\n") {
+		t.Errorf("Unexpected text output:\n%s", text.String())
+	}
+	if !strings.HasPrefix(markdown.String(), "# Review of `git diff --staged`") {
+		t.Errorf("Unexpected markdown output:\n%s", markdown.String())
+	}
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"encoding/json"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+	"sort"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// sarifLog is the root object of a SARIF 2.1.0 document.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// writeSARIF renders the findings as a SARIF 2.1.0 log that can be uploaded to GitHub code scanning.
+// Every finding category becomes a rule.
+func writeSARIF(w io.Writer, report *review.Report) error {
+	rules := make(map[string]bool)
+	results := []sarifResult{}
+	for _, finding := range report.Findings() {
+		ruleID := finding.Category
+		if ruleID == "" {
+			ruleID = "review"
+		}
+		rules[ruleID] = true
+
+		// Deleted lines are not part of the analyzed file, so their findings concern the whole file
+		var region *sarifRegion
+		if !finding.Deleted() {
+			region = &sarifRegion{StartLine: finding.StartLine, EndLine: finding.EndLine}
+		}
+		results = append(results, sarifResult{
+			RuleID:  ruleID,
+			Level:   sarifLevel(finding.Severity),
+			Message: sarifMessage{Text: findingText(finding)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: finding.Path},
+					Region:           region,
+				},
+			}},
+			Properties: map[string]string{"severity": string(finding.Severity)},
+		})
+	}
+
+	driver := sarifDriver{Name: toolName, InformationURI: toolURI, Rules: []sarifRule{}}
+	for ruleID := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{ID: ruleID, ShortDescription: sarifMessage{Text: ruleID}})
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool { return driver.Rules[i].ID < driver.Rules[j].ID })
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
+
+// sarifLevel maps a severity to a SARIF result level.
+func sarifLevel(severity types.Severity) string {
+	switch {
+	case isError(severity):
+		return "error"
+	case severity == types.SeverityMinor:
+		return "warning"
+	}
+	return "note"
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/review"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io"
+)
+
+// checkstyleReport is the root element of a Checkstyle XML report.
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeCheckstyle renders the findings as a Checkstyle XML report, e.g. for Jenkins warnings-ng.
+func writeCheckstyle(w io.Writer, report *review.Report) error {
+	doc := checkstyleReport{Version: "4.3"}
+	for _, file := range report.Files {
+		if len(file.Findings) == 0 {
+			continue
+		}
+		entry := checkstyleFile{Name: file.Path}
+		for _, finding := range file.Findings {
+			// Deleted lines are not part of the checked file, so their findings concern the whole file
+			line := finding.StartLine
+			if finding.Deleted() {
+				line = 0
+			}
+			entry.Errors = append(entry.Errors, checkstyleError{
+				Line:     line,
+				Severity: checkstyleSeverity(finding.Severity),
+				Message:  findingText(finding),
+				Source:   toolName + "." + finding.Category,
+			})
+		}
+		doc.Files = append(doc.Files, entry)
+	}
+	return writeXML(w, doc)
+}
+
+// writeJUnit renders the report as a JUnit XML report with a test suite per file. Every finding is a
+// failed test case, files without findings pass and hunks that could not be reviewed are errors.
+func writeJUnit(w io.Writer, report *review.Report) error {
+	doc := junitTestSuites{}
+	for _, file := range report.Files {
+		suite := junitTestSuite{Name: file.Path}
+		for _, finding := range file.Findings {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      fmt.Sprintf("%s: %s", lineRange(finding), markdownTitle(finding)),
+				ClassName: file.Path,
+				Failure: &junitProblem{
+					Message: findingText(finding),
+					Type:    string(finding.Severity),
+					Text:    finding.Message,
+				},
+			})
+			suite.Failures++
+		}
+		for _, failure := range report.Failures {
+			if failure.Path != file.Path {
+				continue
+			}
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      fmt.Sprintf("line %d", failure.Line),
+				ClassName: file.Path,
+				Error:     &junitProblem{Message: fmt.Sprint(failure.Err), Type: "review-error"},
+			})
+			suite.Errors++
+		}
+		if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: "review", ClassName: file.Path})
+		}
+		suite.Tests = len(suite.Cases)
+		doc.Suites = append(doc.Suites, suite)
+	}
+	return writeXML(w, doc)
+}
+
+// checkstyleSeverity maps a severity to a Checkstyle severity.
+func checkstyleSeverity(severity types.Severity) string {
+	switch {
+	case isError(severity):
+		return "error"
+	case severity == types.SeverityMinor:
+		return "warning"
+	}
+	return "info"
+}
+
+// writeXML writes the document with an XML header.
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,195 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes how often and how long failed HTTP requests are retried.
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled on every further attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. Servers asking to wait longer than MaxDelay are not retried.
+	MaxDelay time.Duration
+}
+
+// DefaultPolicy returns the policy used when nothing else is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+	}
+}
+
+// Transport is an http.RoundTripper that retries requests on rate limits, server errors and network
+// errors with jittered exponential backoff, honoring the Retry-After and rate limit reset headers
+// sent by OpenAI and GitHub. Requests of methods that are not idempotent, such as POST, may have been
+// applied by the server despite an error, so they are only retried on rate limits.
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+	// RetryUnsafe retries every request on server and network errors, for APIs whose requests have no
+	// side effects when repeated, such as model completions.
+	RetryUnsafe bool
+}
+
+// NewClient returns an HTTP client retrying requests according to the policy.
+func NewClient(policy Policy) *http.Client {
+	return &http.Client{Transport: &Transport{Policy: policy}}
+}
+
+// RoundTrip executes the request, retrying it while the policy allows.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if attempt >= t.Policy.MaxAttempts || req.Context().Err() != nil || !t.retryable(req, resp, err) ||
+			(req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		delay, ok := t.delay(resp, attempt)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// delay returns how long to wait before the next attempt. The second value is false when the server
+// asks to wait longer than the policy allows.
+func (t *Transport) delay(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp != nil {
+		if wait, ok := serverDelay(resp); ok {
+			if wait < 0 {
+				wait = 0
+			}
+			return wait, wait <= t.Policy.MaxDelay
+		}
+	}
+
+	backoff := float64(t.Policy.BaseDelay) * math.Pow(2, float64(attempt-1))
+	backoff = backoff/2 + rand.Float64()*backoff
+	if t.Policy.MaxDelay > 0 && backoff > float64(t.Policy.MaxDelay) {
+		backoff = float64(t.Policy.MaxDelay)
+	}
+	return time.Duration(backoff), true
+}
+
+// retryable reports whether the outcome of an attempt is worth retrying.
+func (t *Transport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if !t.RetryUnsafe && !idempotent(req.Method) {
+		// Rate limited requests are the only failures known not to have been applied
+		return err == nil && rateLimited(resp)
+	}
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return rateLimited(resp)
+}
+
+// idempotent reports whether repeating a request of the method has the same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// rateLimited reports whether the server refused the request because of a rate limit.
+func rateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return isGitHubRateLimit(resp)
+	}
+	return false
+}
+
+// isGitHubRateLimit detects GitHub's primary and secondary rate limit responses, which use 403.
+func isGitHubRateLimit(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return err == nil && strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+// serverDelay extracts the wait time requested by the server from the response headers.
+func serverDelay(resp *http.Response) (time.Duration, bool) {
+	if value := resp.Header.Get("Retry-After-Ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date), true
+		}
+	}
+
+	// OpenAI reports the time until the request and token limits reset as durations, e.g. "1s" or "6m0s"
+	var wait time.Duration
+	for _, header := range []string{"X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset-Tokens"} {
+		if d, err := time.ParseDuration(resp.Header.Get(header)); err == nil && d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 && resp.StatusCode == http.StatusTooManyRequests {
+		return wait, true
+	}
+
+	// GitHub reports the reset time of an exhausted limit as a unix timestamp
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return time.Until(time.Unix(reset, 0)), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,167 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testPolicy retries quickly so the tests stay fast.
+var testPolicy = Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}
+
+// TestRetryServerErrors tests that server errors of idempotent requests are retried and the request body
+// is sent again.
+func TestRetryServerErrors(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("Expected the body on attempt %d, got '%s'", attempts, body)
+		}
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL, bytes.NewReader([]byte("payload")))
+	resp, err := NewClient(testPolicy).Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Errorf("Expected success after 3 attempts, got status %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+// TestRetryUnsafe tests that POST requests, which the server may have applied, are only retried on rate
+// limits unless the transport retries unsafe requests.
+func TestRetryUnsafe(t *testing.T) {
+	var attempts int
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	post := func(client *http.Client) {
+		t.Helper()
+		attempts = 0
+		resp, err := client.Post(server.URL, "text/plain", bytes.NewReader([]byte("payload")))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	post(NewClient(testPolicy))
+	if attempts != 1 {
+		t.Errorf("Expected a POST failing with 503 not to be retried, got %d attempts", attempts)
+	}
+	post(&http.Client{Transport: &Transport{Policy: testPolicy, RetryUnsafe: true}})
+	if attempts != testPolicy.MaxAttempts {
+		t.Errorf("Expected %d attempts retrying unsafe requests, got %d", testPolicy.MaxAttempts, attempts)
+	}
+
+	status = http.StatusTooManyRequests
+	post(NewClient(testPolicy))
+	if attempts != testPolicy.MaxAttempts {
+		t.Errorf("Expected a rate limited POST to be retried, got %d attempts", attempts)
+	}
+}
+
+// TestRetryExhausted tests that the last response is returned once all attempts failed.
+func TestRetryExhausted(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(testPolicy).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || attempts != testPolicy.MaxAttempts {
+		t.Errorf("Expected status 429 after %d attempts, got %d after %d", testPolicy.MaxAttempts, resp.StatusCode, attempts)
+	}
+}
+
+// TestRetryAfter tests that Retry-After is honored and waits beyond MaxDelay are not retried.
+func TestRetryAfter(t *testing.T) {
+	var attempts int
+	retryAfter := "1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := NewClient(testPolicy).Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to wait for Retry-After, waited %v", elapsed)
+	}
+
+	attempts = 0
+	retryAfter = "3600"
+	resp, err = NewClient(testPolicy).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || attempts != 1 {
+		t.Errorf("Expected no retry for a wait beyond MaxDelay, got status %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+// TestRetryGitHubSecondaryRateLimit tests that GitHub's 403 secondary rate limit responses are retried
+// while other 403 responses are not.
+func TestRetryGitHubSecondaryRateLimit(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.URL.Path == "/forbidden" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+			return
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message":"You have exceeded a secondary rate limit."}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := NewClient(testPolicy).Get(server.URL)
+	if err != nil || resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("Expected success on the second attempt, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	resp, err = NewClient(testPolicy).Get(server.URL + "/forbidden")
+	if err != nil || resp.StatusCode != http.StatusForbidden || attempts != 1 {
+		t.Errorf("Expected a single attempt for a plain 403, got %d attempts", attempts)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if len(body) == 0 {
+		t.Error("Expected the response body to stay readable")
+	}
+}
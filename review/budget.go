@@ -0,0 +1,65 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/llm"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"sync"
+)
+
+// ErrBudgetExceeded is returned for the hunks that were not sent to the model because the next request
+// could exceed the token or cost budget of the run.
+var ErrBudgetExceeded = errors.New("review budget exceeded")
+
+// budget tracks the tokens and cost spent by the requests of a review run against its caps. Requests
+// reserve their largest possible usage before they are sent, so that concurrent requests cannot exceed
+// the caps together; once a request does not fit, no further requests are sent.
+type budget struct {
+	model     llm.Model
+	maxCost   float64
+	maxTokens int
+
+	mu        sync.Mutex
+	spent     types.Usage
+	reserved  types.Usage
+	exhausted bool
+}
+
+// newBudget returns the budget of a run with the caps of the options; the cost cap requires the price
+// of the model.
+func newBudget(model llm.Model, opts Options) (*budget, error) {
+	if opts.MaxCost > 0 && !model.Priced() {
+		return nil, fmt.Errorf("max_cost requires the price of %s, add it to prices in the configuration", model.Name)
+	}
+	return &budget{model: model, maxCost: opts.MaxCost, maxTokens: opts.MaxTokensTotal}, nil
+}
+
+// reserve reserves the usage of a request, or returns ErrBudgetExceeded if it does not fit into the
+// remaining budget.
+func (b *budget) reserve(usage types.Usage) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exhausted {
+		return ErrBudgetExceeded
+	}
+	total := b.spent.Add(b.reserved).Add(usage)
+	if (b.maxTokens > 0 && total.TotalTokens > b.maxTokens) || (b.maxCost > 0 && b.model.Cost(total) > b.maxCost) {
+		b.exhausted = true
+		return ErrBudgetExceeded
+	}
+	b.reserved = b.reserved.Add(usage)
+	return nil
+}
+
+// settle replaces the reservation of a completed request with its actual usage.
+func (b *budget) settle(reserved, actual types.Usage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved = types.Usage{
+		PromptTokens:     b.reserved.PromptTokens - reserved.PromptTokens,
+		CompletionTokens: b.reserved.CompletionTokens - reserved.CompletionTokens,
+		TotalTokens:      b.reserved.TotalTokens - reserved.TotalTokens,
+	}
+	b.spent = b.spent.Add(actual)
+}
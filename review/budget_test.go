@@ -0,0 +1,57 @@
+package review
+
+import (
+	"errors"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/llm"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"testing"
+)
+
+// usage returns a usage of the prompt and completion tokens.
+func usage(prompt, completion int) types.Usage {
+	return types.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+// TestBudgetTokens tests that reservations count against the token cap until they are settled.
+func TestBudgetTokens(t *testing.T) {
+	spending, err := newBudget(llm.LookupModel("llama3.1"), Options{MaxTokensTotal: 1000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := spending.reserve(usage(400, 200)); err != nil {
+		t.Fatalf("Expected the first request to fit, got %v", err)
+	}
+	spending.settle(usage(400, 200), usage(400, 50))
+	if err := spending.reserve(usage(300, 200)); err != nil {
+		t.Fatalf("Expected the unused answer tokens to be released, got %v", err)
+	}
+	if err := spending.reserve(usage(100, 100)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected the budget to be exceeded, got %v", err)
+	}
+
+	// Once exceeded, no further requests are sent even if they would fit
+	spending.settle(usage(300, 200), usage(300, 10))
+	if err := spending.reserve(usage(1, 1)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected the budget to stay exhausted, got %v", err)
+	}
+}
+
+// TestBudgetCost tests the cost cap and that it requires the price of the model.
+func TestBudgetCost(t *testing.T) {
+	if _, err := newBudget(llm.LookupModel("llama3.1"), Options{MaxCost: 1}); err == nil {
+		t.Error("Expected an error for a cost cap without the price of the model")
+	}
+
+	model := llm.LookupModel("llama3.1").WithPrices(map[string]config.Price{"llama3.1": {Input: 1, Output: 2}})
+	spending, err := newBudget(model, Options{MaxCost: 1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := spending.reserve(usage(500000, 200000)); err != nil {
+		t.Fatalf("Expected $0.90 to fit into $1, got %v", err)
+	}
+	if err := spending.reserve(usage(200000, 0)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected $1.10 to exceed the budget, got %v", err)
+	}
+}
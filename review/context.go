@@ -0,0 +1,79 @@
+package review
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/snippet"
+	"strings"
+)
+
+// hunkContext renders the code of the new version of the file around the hunk: the lines before and
+// after it, widened to its enclosing declaration, and the imports of the file. Each part is only added
+// if the context stays within the token budget counted by count, dropping surrounding lines before
+// giving up on them.
+func hunkContext(file *snippet.File, h hunk, settings config.Context, count func(string) int) string {
+	if file == nil || file.Len() == 0 || settings.MaxTokens <= 0 || len(h.blocks) == 0 {
+		return ""
+	}
+
+	changed := file.Clip(snippet.Range{Start: changedLines(h.blocks[0]).Start, End: changedLines(h.blocks[len(h.blocks)-1]).End})
+	if changed.Start > changed.End {
+		return ""
+	}
+
+	var excerpt snippet.Range
+	for n := settings.Lines; n > 0; n-- {
+		lines := file.Clip(snippet.Range{Start: changed.Start - n, End: changed.End + n})
+		if count(renderLines(file, lines)) <= settings.MaxTokens {
+			excerpt = lines
+			break
+		}
+	}
+	if settings.Enclosing {
+		if enclosing, ok := file.Enclosing(changed); ok {
+			lines := enclosing
+			if excerpt.Start > 0 {
+				lines = snippet.Range{Start: min(excerpt.Start, enclosing.Start), End: max(excerpt.End, enclosing.End)}
+			}
+			if count(renderLines(file, lines)) <= settings.MaxTokens {
+				excerpt = lines
+			}
+		}
+	}
+
+	var context strings.Builder
+	used := 0
+	if excerpt.Start > 0 {
+		used = count(renderLines(file, excerpt))
+	}
+	if settings.Imports {
+		if imports, ok := file.Imports(); ok && !excerpt.Contains(imports) {
+			if text := renderLines(file, imports); used+count(text) <= settings.MaxTokens {
+				fmt.Fprintf(&context, "Imports of the file:\n%s\n", text)
+			}
+		}
+	}
+	if excerpt.Start > 0 {
+		fmt.Fprintf(&context, "Lines %d-%d of the new version of the file:\n%s", excerpt.Start, excerpt.End, renderLines(file, excerpt))
+	}
+	return strings.TrimSuffix(context.String(), "\n")
+}
+
+// changedLines returns the lines of the new version of the file changed by the block. A block that only
+// deletes lines sits between the line before NewStart and NewStart.
+func changedLines(block diff.Block) snippet.Range {
+	if start, end := block.NewRange(); start > 0 {
+		return snippet.Range{Start: start, End: end}
+	}
+	return snippet.Range{Start: max(block.NewStart-1, 1), End: block.NewStart}
+}
+
+// renderLines renders the lines of the range with their line number in front of each.
+func renderLines(file *snippet.File, lines snippet.Range) string {
+	var text strings.Builder
+	for number := lines.Start; number <= lines.End; number++ {
+		fmt.Fprintf(&text, "%6d  %s\n", number, file.Line(number))
+	}
+	return text.String()
+}
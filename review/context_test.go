@@ -0,0 +1,66 @@
+package review
+
+import (
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/snippet"
+	"strings"
+	"testing"
+)
+
+const contextSource = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// run prints the arguments.
+func run(args []string) error {
+	for _, arg := range args {
+		fmt.Println(arg)
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "no arguments")
+	}
+	return nil
+}
+`
+
+// estimate counts about four characters per token.
+func estimate(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// TestHunkContext tests that the surrounding lines, the enclosing function and the imports are added
+// within the token budget.
+func TestHunkContext(t *testing.T) {
+	file := snippet.Parse("main.go", contextSource)
+	h := hunk{path: "main.go", blocks: []diff.Block{{NewStart: 11, Lines: []diff.Line{
+		{Op: diff.Add, Text: "\t\tfmt.Println(arg)", NewLine: 11},
+	}}}}
+
+	context := hunkContext(file, h, config.Context{Lines: 1, Enclosing: true, Imports: true, MaxTokens: 1000}, estimate)
+	if !strings.HasPrefix(context, "Imports of the file:\n     3  import (\n") {
+		t.Errorf("Expected the imports first, got:\n%s", context)
+	}
+	if !strings.Contains(context, "Lines 9-17 of the new version of the file:\n     9  func run(args []string) error {\n") ||
+		!strings.HasSuffix(context, "    17  }") {
+		t.Errorf("Expected the enclosing function, got:\n%s", context)
+	}
+
+	context = hunkContext(file, h, config.Context{Lines: 1, MaxTokens: 1000}, estimate)
+	if context != "Lines 10-12 of the new version of the file:\n    10  \tfor _, arg := range args {\n    11  \t\tfmt.Println(arg)\n    12  \t}" {
+		t.Errorf("Expected only the surrounding lines, got:\n%s", context)
+	}
+
+	// The function and the imports exceed the budget, the surrounding lines shrink to fit
+	context = hunkContext(file, h, config.Context{Lines: 3, Enclosing: true, Imports: true, MaxTokens: 40}, estimate)
+	if !strings.HasPrefix(context, "Lines 9-13 of") || strings.Contains(context, "Imports") {
+		t.Errorf("Expected two surrounding lines within the budget, got:\n%s", context)
+	}
+
+	if context := hunkContext(file, h, config.Context{Lines: 3, MaxTokens: 0}, estimate); context != "" {
+		t.Errorf("Expected no context without a budget, got:\n%s", context)
+	}
+}
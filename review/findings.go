@@ -0,0 +1,382 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/cache"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/llm"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/tokenizer"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"strings"
+	"text/template"
+)
+
+const systemPrompt = "You are an experienced software engineer reviewing a pull request. " +
+	"Point out bugs, security issues, performance problems and readability improvements in the changed code. " +
+	"Only comment on the lines that were changed and only when there is something worth fixing; " +
+	"do not praise the code or restate what it does. " +
+	"Answer with a JSON object whose \"findings\" array holds one entry per issue, using the line numbers " +
+	"shown next to the changed lines: side \"new\" for added lines and side \"old\" for deleted lines, e.g. " +
+	"when removed code is still needed. Return an empty \"findings\" array when the change needs no comment."
+
+// promptVersion is part of the keys of cached answers. Increment it when a change of the built-in prompts,
+// the schema or the parsing of answers makes the cached answers invalid.
+const promptVersion = "1"
+
+// defaultReviewPrompt renders a hunk; .Code holds the hunk with the new line number in front of every
+// added line and the old line number in front of every deleted line, .Context the surrounding code.
+const defaultReviewPrompt = "Code Review Request: Review the following block in file {{.Path}}. " +
+	"Added lines are prefixed with their line number in the new version of the file, " +
+	"deleted lines with their line number in the old version.\n\n{{.Code}}" +
+	"{{if .Context}}\nSurrounding code from the new version of the file, for reference only; " +
+	"do not comment on it:\n\n{{.Context}}{{end}}"
+
+// prompts holds the system instructions and the template rendering each hunk, and the token limits of
+// the model they are sent to.
+type prompts struct {
+	system  string
+	review  *template.Template
+	context config.Context
+	// count returns the number of tokens of a text in the encoding of the model
+	count func(string) int
+	model llm.Model
+	// answer is the number of tokens reserved for each answer of the model
+	answer int
+}
+
+// promptData is the data of the review prompt template.
+type promptData struct {
+	Path    string
+	Code    string
+	Context string
+}
+
+// findingsSchema is the JSON schema the model's answer has to follow.
+var findingsSchema = &types.JSONSchema{
+	Name:   "review_findings",
+	Strict: true,
+	Schema: json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "findings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "side": {"type": "string", "enum": ["new", "old"], "description": "new for added lines, old for deleted lines"},
+          "line": {"type": "integer", "description": "First line of the issue in the version of the file given by side"},
+          "end_line": {"type": ["integer", "null"], "description": "Last line of the issue, null for a single line"},
+          "severity": {"type": "string", "enum": ["info", "minor", "major", "critical"]},
+          "category": {"type": "string", "enum": ["bug", "security", "performance", "error-handling", "maintainability", "style", "documentation", "testing"]},
+          "title": {"type": "string"},
+          "explanation": {"type": "string"},
+          "replacement": {"type": ["string", "null"], "description": "Replacement code for the lines of the issue, null if there is none"}
+        },
+        "required": ["side", "line", "end_line", "severity", "category", "title", "explanation", "replacement"],
+        "additionalProperties": false
+      }
+    }
+  },
+  "required": ["findings"],
+  "additionalProperties": false
+}`),
+}
+
+// categories lists the finding categories accepted by the schema.
+var categories = map[string]bool{
+	"bug": true, "security": true, "performance": true, "error-handling": true,
+	"maintainability": true, "style": true, "documentation": true, "testing": true,
+}
+
+// modelFindings represents the JSON document answered by the model.
+type modelFindings struct {
+	Findings []modelFinding `json:"findings"`
+}
+
+// modelFinding represents a single finding as answered by the model.
+type modelFinding struct {
+	Side        string  `json:"side"`
+	Line        int     `json:"line"`
+	EndLine     *int    `json:"end_line"`
+	Severity    string  `json:"severity"`
+	Category    string  `json:"category"`
+	Title       string  `json:"title"`
+	Explanation string  `json:"explanation"`
+	Replacement *string `json:"replacement"`
+}
+
+// cachedAnswer is the cache entry of a valid answer of the model.
+type cachedAnswer struct {
+	Content string `json:"content"`
+	Model   string `json:"model"`
+}
+
+// reviewHunk asks the model for the findings of a hunk. When the answer does not parse or fails
+// validation, the model is asked once to repair it before the hunk is reported as failed. Every request
+// is charged to the spending budget unless it is nil. Valid answers are stored in the responses cache
+// and reused for the same prompt unless the cache is nil.
+func reviewHunk(ctx context.Context, client llm.Client, opts Options, p *prompts, spending *budget, responses *cache.Cache, h hunk) ([]Finding, types.Usage, error) {
+	prompt, err := p.hunk(h)
+	if err != nil {
+		return nil, types.Usage{}, err
+	}
+	messages := []types.ChatMessage{
+		{Role: types.RoleSystem, Content: p.system},
+		{Role: types.RoleUser, Content: prompt},
+	}
+
+	key := cache.Key(promptVersion, strings.ToLower(opts.Provider), p.model.Name, fmt.Sprint(opts.Temperature), p.system, prompt)
+	if responses != nil {
+		var answer cachedAnswer
+		if data, ok := responses.Get(key); ok && json.Unmarshal(data, &answer) == nil {
+			if findings, err := parseFindings(answer.Content, h); err == nil {
+				for i := range findings {
+					findings[i].Model = answer.Model
+				}
+				return findings, types.Usage{}, nil
+			}
+		}
+	}
+
+	var usage types.Usage
+	for attempt := 0; ; attempt++ {
+		// Reserve the prompt and the longest possible answer before sending the request
+		reserved := types.Usage{CompletionTokens: p.answer}
+		for _, message := range messages {
+			reserved.PromptTokens += p.count(message.Content)
+		}
+		reserved.TotalTokens = reserved.PromptTokens + reserved.CompletionTokens
+		if spending != nil {
+			if err := spending.reserve(reserved); err != nil {
+				return nil, usage, err
+			}
+		}
+
+		response, err := client.Complete(ctx, types.CompletionRequest{
+			Model:         opts.Model,
+			Messages:      messages,
+			Temperature:   opts.Temperature,
+			MaxTokens:     p.answer,
+			ContextWindow: p.model.ContextWindow,
+			JSONSchema:    findingsSchema,
+		})
+		if spending != nil {
+			var actual types.Usage
+			if response != nil {
+				actual = response.Usage
+			}
+			spending.settle(reserved, actual)
+		}
+		if err != nil {
+			return nil, usage, err
+		}
+		usage = usage.Add(response.Usage)
+
+		findings, err := parseFindings(response.Content, h)
+		if err == nil {
+			if responses != nil {
+				// A failed write only costs another request on the next run
+				data, _ := json.Marshal(cachedAnswer{Content: response.Content, Model: response.Model})
+				responses.Put(key, data)
+			}
+			for i := range findings {
+				findings[i].Model = response.Model
+				findings[i].Usage = usage
+			}
+			return findings, usage, nil
+		}
+		if attempt > 0 {
+			return nil, usage, fmt.Errorf("invalid findings from model: %w", err)
+		}
+
+		// Ask the model to repair its answer
+		messages = append(messages,
+			types.ChatMessage{Role: types.RoleAssistant, Content: response.Content},
+			types.ChatMessage{Role: types.RoleUser, Content: fmt.Sprintf(
+				"Your answer is invalid: %v. Reply again with only the JSON object following the schema.", err)},
+		)
+	}
+}
+
+// newPrompts parses the prompts of the options, using the built-in prompts for empty values.
+func newPrompts(opts Options) (*prompts, error) {
+	p := &prompts{system: opts.SystemPrompt, context: opts.Context}
+	if p.system == "" {
+		p.system = systemPrompt
+	}
+
+	text := opts.ReviewPrompt
+	if text == "" {
+		text = defaultReviewPrompt
+	}
+	review, err := template.New("review").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid review prompt: %w", err)
+	}
+	p.review = review
+
+	p.model = llm.LookupModel(llm.ModelName(opts.Provider, opts.Model, opts.Config)).WithPrices(opts.Prices)
+	if opts.ContextWindow > 0 {
+		p.model.ContextWindow = opts.ContextWindow
+	}
+	encoding, err := tokenizer.Get(p.model.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	p.count = encoding.Count
+	p.answer = min(p.model.MaxOutputTokens, maxAnswerTokens, p.model.ContextWindow/4)
+	return p, nil
+}
+
+// hunk renders the review prompt of the hunk. The context is left out if the prompt would not fit
+// into the context window of the model with it.
+func (p *prompts) hunk(h hunk) (string, error) {
+	data := promptData{Path: h.path, Code: hunkCode(h)}
+	if h.source != nil {
+		data.Context = hunkContext(h.source(), h, p.context, p.count)
+	}
+	for {
+		var prompt strings.Builder
+		if err := p.review.Execute(&prompt, data); err != nil {
+			return "", fmt.Errorf("failed to render review prompt: %w", err)
+		}
+		if data.Context == "" || p.fits(prompt.String()) {
+			return prompt.String(), nil
+		}
+		data.Context = ""
+	}
+}
+
+// hunkCode renders the blocks of the hunk with the new line number in front of every added line and
+// the old line number in front of every deleted line. Blocks are separated by a line of dots.
+func hunkCode(h hunk) string {
+	var code strings.Builder
+	for i, block := range h.blocks {
+		if i > 0 {
+			code.WriteString("   ...\n")
+		}
+		code.WriteString(blockCode(block))
+	}
+	return code.String()
+}
+
+// blockCode renders the lines of a block like hunkCode.
+func blockCode(block diff.Block) string {
+	var code strings.Builder
+	for _, line := range block.Lines {
+		code.WriteString(lineCode(line))
+	}
+	return code.String()
+}
+
+// lineCode renders a line of a block like hunkCode.
+func lineCode(line diff.Line) string {
+	number := line.NewLine
+	if line.Op == diff.Delete {
+		number = line.OldLine
+	}
+	return fmt.Sprintf("%6d %c%s\n", number, line.Op, line.Text)
+}
+
+// parseFindings decodes and validates the model's answer for the hunk. An empty findings array means
+// the hunk needs no comment.
+func parseFindings(content string, h hunk) ([]Finding, error) {
+	var answer modelFindings
+	if err := json.Unmarshal([]byte(stripCodeFence(content)), &answer); err != nil {
+		return nil, fmt.Errorf("not a JSON object: %v", err)
+	}
+	if answer.Findings == nil {
+		return nil, fmt.Errorf("missing findings array")
+	}
+
+	findings := make([]Finding, 0, len(answer.Findings))
+	for i, f := range answer.Findings {
+		severity, ok := types.ParseSeverity(f.Severity)
+		if !ok {
+			return nil, fmt.Errorf("finding %d has unknown severity %q", i, f.Severity)
+		}
+		if !categories[f.Category] {
+			return nil, fmt.Errorf("finding %d has unknown category %q", i, f.Category)
+		}
+		if strings.TrimSpace(f.Title) == "" || strings.TrimSpace(f.Explanation) == "" {
+			return nil, fmt.Errorf("finding %d needs a title and an explanation", i)
+		}
+
+		// Findings on deleted lines use the numbers of the old version of the file; models answering
+		// without the schema may leave out the side of added lines
+		side := types.SideRight
+		switch f.Side {
+		case "", "new":
+		case "old":
+			side = types.SideLeft
+		default:
+			return nil, fmt.Errorf("finding %d has unknown side %q", i, f.Side)
+		}
+		endLine := f.Line
+		if f.EndLine != nil {
+			endLine = *f.EndLine
+		}
+		if err := checkLines(h, side, f.Line, endLine); err != nil {
+			return nil, fmt.Errorf("finding %d %w", i, err)
+		}
+
+		finding := Finding{
+			Path:      h.path,
+			StartLine: f.Line,
+			EndLine:   endLine,
+			Side:      side,
+			Severity:  severity,
+			Category:  f.Category,
+			Title:     strings.TrimSpace(f.Title),
+			Message:   strings.TrimSpace(f.Explanation),
+		}
+		if f.Replacement != nil {
+			finding.SuggestedFix = *f.Replacement
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// checkLines reports an error unless the lines start to end are changed lines of a single block of the
+// hunk on the side.
+func checkLines(h hunk, side string, start, end int) error {
+	var changed []string
+	for _, block := range h.blocks {
+		first, last := block.NewRange()
+		if side == types.SideLeft {
+			first, last = block.OldRange()
+		}
+		if first == 0 {
+			continue
+		}
+		if first <= start && start <= end && end <= last {
+			return nil
+		}
+		changed = append(changed, fmt.Sprintf("%d-%d", first, last))
+	}
+	name := "new"
+	if side == types.SideLeft {
+		name = "old"
+	}
+	if len(changed) == 0 {
+		return fmt.Errorf("refers to %s lines, but the block has none", name)
+	}
+	return fmt.Errorf("refers to %s lines %d-%d outside of the changed lines %s", name, start, end, strings.Join(changed, ", "))
+}
+
+// stripCodeFence removes a markdown code fence some models wrap around JSON answers.
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if newline := strings.Index(content, "\n"); newline >= 0 {
+		content = content[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
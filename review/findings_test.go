@@ -0,0 +1,185 @@
+package review
+
+import (
+	"context"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/cache"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClient answers completion requests with canned responses and records the requests.
+type fakeClient struct {
+	answers  []string
+	requests []types.CompletionRequest
+}
+
+func (c *fakeClient) Complete(ctx context.Context, request types.CompletionRequest) (*types.CompletionResponse, error) {
+	c.requests = append(c.requests, request)
+	answer := c.answers[len(c.requests)-1]
+	return &types.CompletionResponse{Content: answer, Model: "fake-model", Usage: types.Usage{TotalTokens: 10}}, nil
+}
+
+var testHunk = hunk{
+	path: "main.go",
+	blocks: []diff.Block{{NewStart: 20, Lines: []diff.Line{
+		{Op: diff.Delete, Text: "old()", OldLine: 20},
+		{Op: diff.Add, Text: "first()", NewLine: 20},
+		{Op: diff.Add, Text: "second()", NewLine: 21},
+	}}},
+}
+
+// TestParseFindings tests decoding and validating the model's JSON answer.
+func TestParseFindings(t *testing.T) {
+	content := "```json\n" + `{"findings":[{"line":20,"end_line":21,"severity":"major","category":"bug",` +
+		`"title":"Unchecked error","explanation":"The error of second() is ignored.","replacement":"if err := second(); err != nil {\n\treturn err\n}"}]}` + "\n```"
+
+	findings, err := parseFindings(content, testHunk)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	finding := findings[0]
+	if finding.StartLine != 20 || finding.EndLine != 21 || finding.Severity != types.SeverityMajor ||
+		finding.Category != "bug" || finding.Title != "Unchecked error" || !strings.HasPrefix(finding.SuggestedFix, "if err") {
+		t.Errorf("Unexpected finding %+v", finding)
+	}
+
+	findings, err = parseFindings(`{"findings":[{"side":"old","line":20,"end_line":null,"severity":"minor",`+
+		`"category":"bug","title":"Still called","explanation":"old() is still used elsewhere."}]}`, testHunk)
+	if err != nil || len(findings) != 1 || findings[0].Side != types.SideLeft || findings[0].StartLine != 20 || findings[0].EndLine != 20 {
+		t.Errorf("Expected a finding on the deleted line 20, got %+v (%v)", findings, err)
+	}
+
+	findings, err = parseFindings(`{"findings":[]}`, testHunk)
+	if err != nil || len(findings) != 0 {
+		t.Errorf("Expected no findings for an empty array, got %v (%v)", findings, err)
+	}
+
+	invalid := []string{
+		`Looks good to me!`,
+		`{"comments":[]}`,
+		`{"findings":[{"line":20,"severity":"blocker","category":"bug","title":"t","explanation":"e"}]}`,
+		`{"findings":[{"line":20,"severity":"minor","category":"naming","title":"t","explanation":"e"}]}`,
+		`{"findings":[{"line":35,"severity":"minor","category":"style","title":"t","explanation":"e"}]}`,
+		`{"findings":[{"side":"old","line":21,"severity":"minor","category":"style","title":"t","explanation":"e"}]}`,
+		`{"findings":[{"side":"left","line":20,"severity":"minor","category":"style","title":"t","explanation":"e"}]}`,
+		`{"findings":[{"line":20,"severity":"minor","category":"style","title":"","explanation":"e"}]}`,
+	}
+	for _, content := range invalid {
+		if _, err := parseFindings(content, testHunk); err == nil {
+			t.Errorf("Expected a validation error for %s", content)
+		}
+	}
+}
+
+// TestReviewHunkRepair tests that an invalid answer is repaired with a second request.
+func TestReviewHunkRepair(t *testing.T) {
+	client := &fakeClient{answers: []string{
+		`Severity: minor. Consider renaming second().`,
+		`{"findings":[{"line":21,"end_line":null,"severity":"minor","category":"style","title":"Naming","explanation":"Consider renaming second().","replacement":null}]}`,
+	}}
+
+	findings, usage, err := reviewHunk(context.Background(), client, Options{}, testPrompts(t, Options{}), nil, nil, testHunk)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(client.requests) != 2 {
+		t.Fatalf("Expected a repair request, got %d requests", len(client.requests))
+	}
+	repair := client.requests[1].Messages
+	if repair[len(repair)-2].Role != types.RoleAssistant || client.requests[1].JSONSchema == nil {
+		t.Errorf("Expected the invalid answer to be sent back with the schema, got %+v", repair)
+	}
+	if len(findings) != 1 || findings[0].StartLine != 21 || findings[0].EndLine != 21 || findings[0].Model != "fake-model" {
+		t.Errorf("Unexpected findings %+v", findings)
+	}
+	if usage.TotalTokens != 20 {
+		t.Errorf("Expected the usage of both requests, got %d", usage.TotalTokens)
+	}
+
+	client = &fakeClient{answers: []string{`not json`, `still not json`}}
+	if _, _, err := reviewHunk(context.Background(), client, Options{}, testPrompts(t, Options{}), nil, nil, testHunk); err == nil {
+		t.Error("Expected an error after a failed repair")
+	}
+}
+
+// TestReviewHunkCache tests that a cached answer is reused for the same prompt without a request.
+func TestReviewHunkCache(t *testing.T) {
+	responses, err := cache.Open(t.TempDir(), time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	client := &fakeClient{answers: []string{
+		`{"findings":[{"line":21,"end_line":null,"severity":"minor","category":"style","title":"Naming","explanation":"Consider renaming second().","replacement":null}]}`,
+		`{"findings":[]}`,
+	}}
+	p := testPrompts(t, Options{})
+
+	for i := 0; i < 2; i++ {
+		findings, usage, err := reviewHunk(context.Background(), client, Options{}, p, nil, responses, testHunk)
+		if err != nil || len(findings) != 1 || findings[0].Model != "fake-model" {
+			t.Fatalf("Run %d: expected the finding, got %+v (%v)", i, findings, err)
+		}
+		if i == 1 && usage.TotalTokens != 0 {
+			t.Errorf("Expected no usage for a cached answer, got %d", usage.TotalTokens)
+		}
+	}
+	if len(client.requests) != 1 || responses.Hits() != 1 {
+		t.Fatalf("Expected a single request and a cache hit, got %d requests and %d hits", len(client.requests), responses.Hits())
+	}
+
+	// Another temperature is another prompt
+	if _, _, err := reviewHunk(context.Background(), client, Options{Temperature: 1}, p, nil, responses, testHunk); err != nil || len(client.requests) != 2 {
+		t.Errorf("Expected a new request, got %d requests (%v)", len(client.requests), err)
+	}
+}
+
+// testPrompts parses the prompts of the options.
+func testPrompts(t *testing.T, opts Options) *prompts {
+	t.Helper()
+	p, err := newPrompts(opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return p
+}
+
+// TestHunkPrompt tests that added and deleted lines are numbered in the prompt.
+func TestHunkPrompt(t *testing.T) {
+	prompt, err := testPrompts(t, Options{}).hunk(testHunk)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, expected := range []string{"main.go", "    20 -old()", "    20 +first()", "    21 +second()"} {
+		if !strings.Contains(prompt, expected) {
+			t.Errorf("Expected prompt to contain %q, got:\n%s", expected, prompt)
+		}
+	}
+}
+
+// TestCustomPrompts tests that the configured prompts replace the built-in ones.
+func TestCustomPrompts(t *testing.T) {
+	client := &fakeClient{answers: []string{`{"findings":[]}`}}
+	opts := Options{SystemPrompt: "Only report security issues.", ReviewPrompt: "Review {{.Path}}:\n{{.Code}}"}
+
+	if _, _, err := reviewHunk(context.Background(), client, opts, testPrompts(t, opts), nil, nil, testHunk); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	messages := client.requests[0].Messages
+	if messages[0].Content != "Only report security issues." {
+		t.Errorf("Expected the configured system prompt, got %q", messages[0].Content)
+	}
+	if !strings.HasPrefix(messages[1].Content, "Review main.go:\n") || !strings.Contains(messages[1].Content, "    20 +first()") {
+		t.Errorf("Expected the configured review prompt, got %q", messages[1].Content)
+	}
+
+	if _, err := newPrompts(Options{ReviewPrompt: "{{.Path"}); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+}
@@ -0,0 +1,195 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/codehost"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reviewState is the state file of a pull request, recording the head commit of its last review.
+type reviewState struct {
+	HeadSHA    string    `json:"head_sha"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+// incrementalChanges returns the files of the pull request to review when only the commits since its
+// last review are reviewed, and the last reviewed commit. The whole pull request is reviewed, with an
+// empty last commit, when it was not reviewed before, its head is no longer a descendant of the last
+// reviewed commit or the host truncated the comparison.
+func incrementalChanges(ctx context.Context, opts Options, host codehost.Host, remote git.Remote, changes *codehost.Changes) ([]types.FileDiff, string, error) {
+	last, err := lastReviewed(opts, remote)
+	if err != nil || last == "" {
+		return changes.Files, "", err
+	}
+	if last == changes.HeadSHA {
+		return nil, last, nil
+	}
+
+	compared, err := host.Compare(ctx, last, changes.HeadSHA)
+	if errors.Is(err, codehost.ErrDiverged) {
+		return changes.Files, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to compare with the last reviewed commit: %w", err)
+	}
+	if compared.Truncated {
+		return changes.Files, "", nil
+	}
+	return incrementalFiles(changes.Files, compared.Files), last, nil
+}
+
+// incrementalFiles returns the hunks of the pull request's files that contain a line changed by the
+// compared commits. The hunks keep the line numbers of the pull request diff, so that findings can be
+// commented on it. Files compared without a patch, such as binary files or the files of hosts that
+// cannot diff commits, are kept whole.
+func incrementalFiles(files, compared []types.FileDiff) []types.FileDiff {
+	changed := make(map[string]types.FileDiff)
+	for _, file := range compared {
+		changed[file.Path] = file
+	}
+
+	var selected []types.FileDiff
+	for _, file := range files {
+		since, ok := changed[file.Path]
+		if !ok {
+			continue
+		}
+		hunks, err := diff.Parse(file.Patch)
+		lines, sinceErr := touchedLines(since.Patch)
+		if since.Patch == "" || err != nil || sinceErr != nil {
+			// Reviewed whole; ReviewDiff reports patches that do not parse
+			selected = append(selected, file)
+			continue
+		}
+
+		var patch []string
+		filtered := types.FileDiff{Path: file.Path}
+		for _, hunk := range hunks {
+			if !touches(hunk, lines) {
+				continue
+			}
+			patch = append(patch, hunk.String())
+			for _, line := range hunk.Lines {
+				switch line.Op {
+				case diff.Add:
+					filtered.Additions++
+				case diff.Delete:
+					filtered.Deletions++
+				}
+			}
+		}
+		if len(patch) > 0 {
+			filtered.Patch = strings.Join(patch, "\n")
+			selected = append(selected, filtered)
+		}
+	}
+	return selected
+}
+
+// touchedLines returns the lines of the new version of the file changed by the patch: the added lines,
+// and the lines around the place of deleted lines.
+func touchedLines(patch string) (map[int]bool, error) {
+	hunks, err := diff.Parse(patch)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[int]bool)
+	for _, block := range diff.Blocks(hunks) {
+		if start, end := block.NewRange(); start > 0 {
+			for line := start; line <= end; line++ {
+				lines[line] = true
+			}
+			continue
+		}
+		lines[block.NewStart-1], lines[block.NewStart] = true, true
+	}
+	return lines, nil
+}
+
+// touches reports whether the new range of the hunk, including its context lines, contains one of the
+// lines.
+func touches(hunk diff.Hunk, lines map[int]bool) bool {
+	start, end := hunk.NewStart, hunk.NewStart+hunk.NewLines-1
+	if hunk.NewLines == 0 {
+		// An empty range starts at the line before the deletion
+		end = start + 1
+	}
+	for line := start; line <= end; line++ {
+		if lines[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// lastReviewed returns the head commit of the last recorded review of the pull request, empty when there
+// is none.
+func lastReviewed(opts Options, remote git.Remote) (string, error) {
+	path := stateFile(opts, remote, opts.PRNumber)
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read review state: %w", err)
+	}
+	var state reviewState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", fmt.Errorf("failed to read review state %s: %w", path, err)
+	}
+	return state.HeadSHA, nil
+}
+
+// Record records the head commit of a complete review of a pull request for the next incremental review.
+// Call it only after the review was published. Reviews with failed, unreviewed or skipped files and
+// reviews that are not posted are not recorded, so that the next run reviews their changes again.
+func Record(opts Options, report *Report) error {
+	if !opts.Incremental || opts.PostMode == config.PostModeOff || report.HeadSHA == "" || report.PRNumber == 0 || !report.Complete() {
+		return nil
+	}
+	remote := git.Remote{Host: report.Host, Owner: report.Owner, Repo: report.Repo}
+	path := stateFile(opts, remote, report.PRNumber)
+	if path == "" {
+		return fmt.Errorf("no state directory, set state_dir or REVIEW_STATE_DIR")
+	}
+
+	data, err := json.Marshal(reviewState{HeadSHA: report.HeadSHA, ReviewedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to write review state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write review state: %w", err)
+	}
+	return nil
+}
+
+// stateFile returns the state file of the pull request, below a directory per code host and repository.
+// Remotes without a host name, given as owner/repo, use the kind of code host instead.
+func stateFile(opts Options, remote git.Remote, number int) string {
+	dir := config.StateDir(opts.StateDir)
+	if dir == "" {
+		return ""
+	}
+	host := remote.Host
+	if host == "" {
+		host = codehost.Detect(opts.Config, "")
+	}
+	return filepath.Join(dir, host, remote.Owner, remote.Repo, fmt.Sprintf("%d.json", number))
+}
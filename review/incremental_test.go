@@ -0,0 +1,120 @@
+package review
+
+import (
+	"context"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/codehost"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"testing"
+)
+
+// fakeHost compares commits with a canned result and records the compared commits.
+type fakeHost struct {
+	compared *codehost.Changes
+	err      error
+	calls    []string
+}
+
+func (h *fakeHost) Changes(ctx context.Context, number int) (*codehost.Changes, error) {
+	return nil, nil
+}
+
+func (h *fakeHost) File(ctx context.Context, ref, path string) (string, error) {
+	return "", nil
+}
+
+func (h *fakeHost) Compare(ctx context.Context, base, head string) (*codehost.Changes, error) {
+	h.calls = append(h.calls, base+"..."+head)
+	return h.compared, h.err
+}
+
+func (h *fakeHost) SubmitReview(ctx context.Context, number int, review types.Review) error {
+	return nil
+}
+
+// prFiles are the files of a pull request: a.go with two hunks and b.go.
+var prFiles = []types.FileDiff{
+	{Path: "a.go", Patch: "@@ -1,3 +1,4 @@\n a\n+b\n c\n d\n@@ -20,3 +21,4 @@\n x\n+y\n z\n w", Additions: 2},
+	{Path: "b.go", Patch: "@@ -1 +1 @@\n-old\n+new", Additions: 1, Deletions: 1},
+}
+
+// TestIncrementalFiles tests that only the hunks of the pull request touched since the last review are
+// kept, with the line numbers of the pull request diff.
+func TestIncrementalFiles(t *testing.T) {
+	files := incrementalFiles(prFiles, []types.FileDiff{
+		{Path: "a.go", Patch: "@@ -21,2 +21,3 @@\n x\n+y\n z"},
+		{Path: "c.go", Patch: "@@ -1 +1 @@\n-a\n+b"},
+	})
+	if len(files) != 1 || files[0].Path != "a.go" || files[0].Additions != 1 {
+		t.Fatalf("Expected the second hunk of a.go, got %+v", files)
+	}
+	hunks, err := diff.Parse(files[0].Patch)
+	if err != nil || len(hunks) != 1 || hunks[0].NewStart != 21 || hunks[0].Lines[1].NewLine != 22 {
+		t.Errorf("Expected the hunk at line 21 of the pull request diff, got %+v (%v)", hunks, err)
+	}
+
+	// Deleted lines touch the hunk around them, files compared without a patch are kept whole
+	files = incrementalFiles(prFiles, []types.FileDiff{
+		{Path: "a.go", Patch: "@@ -3,1 +2,0 @@\n-gone"},
+		{Path: "b.go"},
+	})
+	if len(files) != 2 || files[0].Patch != "@@ -1,3 +1,4 @@\n a\n+b\n c\n d" || files[1].Patch != prFiles[1].Patch {
+		t.Errorf("Expected the first hunk of a.go and all of b.go, got %+v", files)
+	}
+}
+
+// TestIncrementalChanges tests that recorded reviews are compared with the head, falling back to a full
+// review when the commits diverged.
+func TestIncrementalChanges(t *testing.T) {
+	opts := Options{Incremental: true, StateDir: t.TempDir(), PRNumber: 3}
+	remote := git.Remote{Host: "github.com", Owner: "owner", Repo: "repo"}
+	changes := &codehost.Changes{Files: prFiles, HeadSHA: "second"}
+	host := &fakeHost{compared: &codehost.Changes{Files: []types.FileDiff{{Path: "b.go"}}}}
+
+	// Pull requests without a recorded review are reviewed whole
+	files, since, err := incrementalChanges(context.Background(), opts, host, remote, changes)
+	if err != nil || len(files) != 2 || since != "" || len(host.calls) != 0 {
+		t.Fatalf("Expected a full review, got %d files since %q (%v)", len(files), since, err)
+	}
+
+	report := &Report{Host: "github.com", Owner: "owner", Repo: "repo", PRNumber: 3, HeadSHA: "first"}
+	if err := Record(opts, report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	files, since, err = incrementalChanges(context.Background(), opts, host, remote, changes)
+	if err != nil || len(files) != 1 || files[0].Path != "b.go" || since != "first" || host.calls[0] != "first...second" {
+		t.Errorf("Expected b.go since the first commit, got %+v since %q (%v)", files, since, err)
+	}
+
+	host.err = codehost.ErrDiverged
+	if files, since, err := incrementalChanges(context.Background(), opts, host, remote, changes); err != nil || len(files) != 2 || since != "" {
+		t.Errorf("Expected a full review after a force-push, got %d files since %q (%v)", len(files), since, err)
+	}
+
+	// Incomplete reviews are not recorded
+	report.HeadSHA, report.Failures = "second", []Failure{{Path: "a.go"}}
+	if err := Record(opts, report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if last, err := lastReviewed(opts, remote); err != nil || last != "first" {
+		t.Errorf("Expected the first commit to stay recorded, got %q (%v)", last, err)
+	}
+	report.Failures = nil
+
+	// Dry runs are not recorded
+	dryRun := opts
+	dryRun.PostMode = config.PostModeOff
+	if err := Record(dryRun, report); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if last, err := lastReviewed(opts, remote); err != nil || last != "first" {
+		t.Errorf("Expected a dry run not to be recorded, got %q (%v)", last, err)
+	}
+
+	Record(opts, report)
+	if files, since, _ := incrementalChanges(context.Background(), opts, host, remote, changes); len(files) != 0 || since != "second" {
+		t.Errorf("Expected nothing to review at the recorded head, got %d files since %q", len(files), since)
+	}
+}
@@ -0,0 +1,133 @@
+package review
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/snippet"
+	"strings"
+)
+
+const (
+	// maxAnswerTokens caps the tokens reserved for each answer of the model.
+	maxAnswerTokens = 4096
+	// mergeTokens is the size of the code up to which adjacent blocks of a file are reviewed together.
+	mergeTokens = 1500
+	// minCodeTokens is the smallest code budget a review request is sent with.
+	minCodeTokens = 256
+)
+
+// codeBudget returns the number of tokens the changed code of a request may have. The context window
+// of the model has to hold the system prompt, the rendered review prompt with its context and two
+// answers, since an invalid answer is sent back to be repaired.
+func (p *prompts) codeBudget() (int, error) {
+	var empty strings.Builder
+	if err := p.review.Execute(&empty, promptData{}); err != nil {
+		return 0, fmt.Errorf("failed to render review prompt: %w", err)
+	}
+	budget := p.model.ContextWindow - 2*p.answer - p.count(p.system) - p.count(empty.String()) - p.context.MaxTokens
+	if budget < minCodeTokens {
+		return 0, fmt.Errorf("the context window of %d tokens of %s leaves no room for the code to review; set context_window or lower context.max_tokens",
+			p.model.ContextWindow, p.model.Name)
+	}
+	return budget, nil
+}
+
+// fits reports whether the review prompt fits into the context window of the model together with the
+// system prompt and two answers.
+func (p *prompts) fits(prompt string) bool {
+	return p.count(p.system)+p.count(prompt)+2*p.answer <= p.model.ContextWindow
+}
+
+// planRequests groups the blocks of a file into review requests whose code stays within limit tokens
+// counted by count. Blocks larger than the limit are split at logical boundaries, and adjacent blocks
+// are merged into one request while their code stays below mergeTokens.
+func planRequests(path string, blocks []diff.Block, count func(string) int, limit int) []hunk {
+	var hunks []hunk
+	current, size := hunk{path: path}, 0
+	for _, block := range blocks {
+		for _, part := range splitBlock(block, count, limit) {
+			tokens := count(blockCode(part))
+			if len(current.blocks) > 0 && size+tokens > min(mergeTokens, limit) {
+				hunks = append(hunks, current)
+				current, size = hunk{path: path}, 0
+			}
+			current.blocks = append(current.blocks, part)
+			size += tokens
+		}
+	}
+	if len(current.blocks) > 0 {
+		hunks = append(hunks, current)
+	}
+	return hunks
+}
+
+// splitBlock splits a block whose code exceeds limit tokens into blocks that fit. Blocks are cut at the
+// last logical boundary that fits, see boundary, or at the limit if there is none. A single line longer
+// than the limit is kept whole.
+func splitBlock(block diff.Block, count func(string) int, limit int) []diff.Block {
+	if count(blockCode(block)) <= limit {
+		return []diff.Block{block}
+	}
+
+	// The outermost indentation of the block marks the start of its statements and declarations
+	outermost := -1
+	for _, line := range block.Lines {
+		if strings.TrimSpace(line.Text) != "" && (outermost < 0 || snippet.Indentation(line.Text) < outermost) {
+			outermost = snippet.Indentation(line.Text)
+		}
+	}
+
+	var blocks []diff.Block
+	newStart := block.NewStart
+	var lines []diff.Line
+	var tokens []int
+	size, cut := 0, 0
+	flush := func(n int) {
+		part := diff.Block{NewStart: newStart, Lines: append([]diff.Line(nil), lines[:n]...)}
+		for _, line := range part.Lines {
+			if line.Op == diff.Add {
+				newStart = line.NewLine + 1
+			}
+		}
+		blocks = append(blocks, part)
+		lines, tokens = lines[n:], tokens[n:]
+		size, cut = 0, 0
+		for i, t := range tokens {
+			size += t
+			if i > 0 && boundary(lines[i-1], lines[i], outermost) {
+				cut = i
+			}
+		}
+	}
+
+	for _, line := range block.Lines {
+		n := count(lineCode(line))
+		if len(lines) > 0 && boundary(lines[len(lines)-1], line, outermost) {
+			cut = len(lines)
+		}
+		for len(lines) > 0 && size+n > limit {
+			if cut == 0 {
+				cut = len(lines)
+			}
+			flush(cut)
+		}
+		lines = append(lines, line)
+		tokens = append(tokens, n)
+		size += n
+	}
+	if len(lines) > 0 {
+		flush(len(lines))
+	}
+	return blocks
+}
+
+// boundary reports whether a block may be cut between the lines: after a blank line, between deleted
+// and added lines, or before a line at the outermost indentation of the block that does not close a
+// bracket.
+func boundary(previous, line diff.Line, outermost int) bool {
+	if strings.TrimSpace(previous.Text) == "" || previous.Op != line.Op {
+		return true
+	}
+	text := strings.TrimSpace(line.Text)
+	return text != "" && snippet.Indentation(line.Text) <= outermost && !strings.ContainsAny(text[:1], "})]")
+}
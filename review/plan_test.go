@@ -0,0 +1,95 @@
+package review
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"strings"
+	"testing"
+)
+
+// addedLines returns a block adding the lines, numbered from start.
+func addedLines(start int, lines ...string) diff.Block {
+	block := diff.Block{NewStart: start}
+	for i, text := range lines {
+		block.Lines = append(block.Lines, diff.Line{Op: diff.Add, Text: text, NewLine: start + i})
+	}
+	return block
+}
+
+// TestPlanRequestsMerge tests that adjacent small blocks of a file share a request until the merge size.
+func TestPlanRequestsMerge(t *testing.T) {
+	blocks := []diff.Block{addedLine(3), addedLine(10), addedLine(42)}
+	hunks := planRequests("main.go", blocks, estimate, 1000)
+	if len(hunks) != 1 || len(hunks[0].blocks) != 3 || hunks[0].path != "main.go" {
+		t.Fatalf("Expected a single request with 3 blocks, got %+v", hunks)
+	}
+	if code := hunkCode(hunks[0]); !strings.Contains(code, "     3 +code()\n   ...\n    10 +code()\n") {
+		t.Errorf("Expected the blocks separated by dots, got:\n%s", code)
+	}
+
+	// Findings have to stay within a single block of the request
+	if err := checkLines(hunks[0], types.SideRight, 10, 10); err != nil {
+		t.Errorf("Expected line 10 to be accepted, got %v", err)
+	}
+	if err := checkLines(hunks[0], types.SideRight, 3, 10); err == nil || !strings.Contains(err.Error(), "3-3, 10-10, 42-42") {
+		t.Errorf("Expected an error listing the changed lines, got %v", err)
+	}
+
+	large := strings.Repeat("x", 4*mergeTokens)
+	blocks = []diff.Block{addedLine(3), addedLines(10, large), addedLine(42)}
+	if hunks := planRequests("main.go", blocks, estimate, 2*mergeTokens); len(hunks) != 3 {
+		t.Errorf("Expected a request per block around a large block, got %d", len(hunks))
+	}
+}
+
+// TestSplitBlock tests that blocks over the limit are cut at blank lines and keep their line numbers.
+func TestSplitBlock(t *testing.T) {
+	var lines []string
+	for i := 0; i < 3; i++ {
+		lines = append(lines, fmt.Sprintf("func f%d() {", i), "\treturn", "}", "")
+	}
+	block := addedLines(100, lines...)
+	block.Lines = append([]diff.Line{{Op: diff.Delete, Text: "old()", OldLine: 99}}, block.Lines...)
+
+	parts := splitBlock(block, estimate, 20)
+	var total int
+	for _, part := range parts {
+		if tokens := estimate(blockCode(part)); tokens > 20 {
+			t.Errorf("Expected at most 20 tokens per part, got %d:\n%s", tokens, blockCode(part))
+		}
+		total += len(part.Lines)
+	}
+	if total != len(block.Lines) {
+		t.Fatalf("Expected the %d lines of the block, got %d", len(block.Lines), total)
+	}
+
+	// The functions are cut after the blank lines
+	if len(parts) != 3 || parts[0].Lines[0].Op != diff.Delete || parts[0].NewStart != 100 {
+		t.Fatalf("Expected the deleted line and a function per part, got %+v", parts)
+	}
+	for _, part := range parts[1:] {
+		if start, _ := part.NewRange(); part.NewStart != start || !strings.HasPrefix(part.Lines[0].Text, "func ") {
+			t.Errorf("Expected each part to start at a function, got %+v", part)
+		}
+	}
+
+	if parts := splitBlock(block, estimate, 1000); len(parts) != 1 {
+		t.Errorf("Expected a block within the limit to stay whole, got %d parts", len(parts))
+	}
+}
+
+// TestCodeBudget tests that the context window leaves room for the prompts, the context and the answers.
+func TestCodeBudget(t *testing.T) {
+	p := testPrompts(t, Options{Model: "gpt-4o"})
+	budget, err := p.codeBudget()
+	if err != nil || budget < 100000 {
+		t.Errorf("Expected a large budget for gpt-4o, got %d (%v)", budget, err)
+	}
+
+	p = testPrompts(t, Options{Model: "gpt-4o", ContextWindow: 4000, Context: config.Context{MaxTokens: 2000}})
+	if _, err := p.codeBudget(); err == nil {
+		t.Error("Expected an error for a context window too small for the context and the answers")
+	}
+}
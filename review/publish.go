@@ -0,0 +1,169 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/codehost"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"sort"
+	"strings"
+)
+
+// Publish submits the findings of the report as a single review to the code host and returns the review
+// event chosen by the rules; incomplete and incremental reviews are only comments. In the summary posting
+// mode the review has no inline comments; otherwise at most opts.MaxComments of the most severe findings
+// are commented inline.
+func Publish(ctx context.Context, opts Options, report *Report, rules EventRules) (string, error) {
+	findings := report.Findings()
+	var severities []types.Severity
+	for _, finding := range findings {
+		severities = append(severities, finding.Severity)
+	}
+
+	var comments []types.ReviewComment
+	for _, finding := range inlineFindings(findings, opts) {
+		comments = append(comments, types.ReviewComment{
+			Path:      finding.Path,
+			StartLine: finding.StartLine,
+			Line:      finding.EndLine,
+			Side:      finding.Side,
+			Body:      commentBody(finding),
+		})
+	}
+
+	remote := git.Remote{Host: report.Host, Owner: report.Owner, Repo: report.Repo}
+	host, err := codehost.New(ctx, opts.Config, remote, opts.RetryPolicy)
+	if err != nil {
+		return "", err
+	}
+	body := reviewSummary(findings, len(comments))
+	if report.Since != "" {
+		body = fmt.Sprintf("Reviewed the changes since %s.\n\n%s", shortSHA(report.Since), body)
+	}
+	body += incompleteNote(report)
+	event := reviewEvent(rules, severities, report)
+	err = host.SubmitReview(ctx, report.PRNumber, types.Review{
+		// Findings are on the lines of the reviewed head, which may no longer be the head of the pull request
+		CommitID: report.HeadSHA,
+		Body:     body,
+		Event:    event,
+		Comments: comments,
+	})
+	if err != nil {
+		return "", err
+	}
+	return event, nil
+}
+
+// reviewEvent returns the review event chosen by the rules for the severities. An incomplete review is
+// submitted as a comment, so that a pull request is never approved or blocked by a review that missed
+// some of its changes. So is an incremental review, whose findings leave out those of earlier reviews.
+func reviewEvent(rules EventRules, severities []types.Severity, report *Report) string {
+	if !report.Complete() || report.Since != "" {
+		return types.ReviewEventComment
+	}
+	return rules.Event(severities)
+}
+
+// incompleteNote lists the files that the review missed, empty when the review is complete.
+func incompleteNote(report *Report) string {
+	if report.Complete() {
+		return ""
+	}
+	var note strings.Builder
+	note.WriteString("\n\nThe review is incomplete:")
+	if len(report.Failures) > 0 {
+		var failed []string
+		seen := make(map[string]bool)
+		for _, failure := range report.Failures {
+			if !seen[failure.Path] {
+				seen[failure.Path] = true
+				failed = append(failed, failure.Path)
+			}
+		}
+		fmt.Fprintf(&note, "\n- The model could not review these files: `%s`", strings.Join(failed, "`, `"))
+	}
+	if len(report.Unreviewed) > 0 {
+		fmt.Fprintf(&note, "\n- The review budget was exceeded before these files were reviewed: `%s`", strings.Join(report.Unreviewed, "`, `"))
+	}
+	if report.Truncated {
+		if report.SkippedFiles > 0 {
+			fmt.Fprintf(&note, "\n- The code host truncated the file list, %d changed files were not reviewed.", report.SkippedFiles)
+		} else {
+			fmt.Fprintf(&note, "\n- The code host truncated the file list at %d files, remaining files were not reviewed.", report.ListedFiles)
+		}
+	}
+	return note.String()
+}
+
+// inlineFindings returns the findings posted as inline comments, the most severe first when they are
+// capped by MaxComments.
+func inlineFindings(findings []Finding, opts Options) []Finding {
+	if opts.PostMode == config.PostModeSummary {
+		return nil
+	}
+	if opts.MaxComments <= 0 || len(findings) <= opts.MaxComments {
+		return findings
+	}
+
+	sorted := append([]Finding(nil), findings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity.Rank() > sorted[j].Severity.Rank()
+	})
+	return sorted[:opts.MaxComments]
+}
+
+// commentBody renders a finding as the body of an inline review comment.
+func commentBody(finding Finding) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "**Severity: %s**", finding.Severity)
+	if finding.Category != "" {
+		fmt.Fprintf(&body, " · %s", finding.Category)
+	}
+	if finding.Title != "" {
+		fmt.Fprintf(&body, "\n\n**%s**", finding.Title)
+	}
+	fmt.Fprintf(&body, "\n\n%s", finding.Message)
+	if finding.SuggestedFix != "" {
+		fmt.Fprintf(&body, "\n\nSuggested fix:\n```\n%s\n```", finding.SuggestedFix)
+	}
+	return body.String()
+}
+
+// reviewSummary builds the body of the review from the number of findings per severity, noting how
+// many of them are commented inline when not all are.
+func reviewSummary(findings []Finding, inline int) string {
+	if len(findings) == 0 {
+		return "PR Reviewer found no issues in the changed code."
+	}
+
+	files := make(map[string]bool)
+	counts := make(map[types.Severity]int)
+	for _, finding := range findings {
+		files[finding.Path] = true
+		counts[finding.Severity]++
+	}
+
+	var summary strings.Builder
+	if inline == len(findings) {
+		fmt.Fprintf(&summary, "PR Reviewer left %d comments on %d files.\n", len(findings), len(files))
+	} else {
+		fmt.Fprintf(&summary, "PR Reviewer found %d issues in %d files, %d of them are commented inline.\n", len(findings), len(files), inline)
+	}
+	for _, severity := range []types.Severity{types.SeverityCritical, types.SeverityMajor, types.SeverityMinor, types.SeverityInfo} {
+		if counts[severity] > 0 {
+			fmt.Fprintf(&summary, "\n- %s: %d", severity, counts[severity])
+		}
+	}
+	return summary.String()
+}
+
+// shortSHA abbreviates a commit hash to the length git shows.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,57 @@
+package review
+
+import (
+	"errors"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"strings"
+	"testing"
+)
+
+// TestReviewEvent tests that an incomplete review is never submitted as an approval.
+func TestReviewEvent(t *testing.T) {
+	rules := EventRules{RequestChangesAt: types.SeverityMajor, ApproveBelow: types.SeverityMinor}
+
+	tests := []struct {
+		name       string
+		report     Report
+		severities []types.Severity
+		expected   string
+	}{
+		{"complete", Report{}, nil, types.ReviewEventApprove},
+		{"failed", Report{Failures: []Failure{{Path: "a.go", Err: errors.New("unauthorized")}}}, nil, types.ReviewEventComment},
+		{"unreviewed", Report{Unreviewed: []string{"a.go"}}, nil, types.ReviewEventComment},
+		{"truncated", Report{Truncated: true, SkippedFiles: 3}, nil, types.ReviewEventComment},
+		{"failed with findings", Report{Failures: []Failure{{Path: "a.go"}}}, []types.Severity{types.SeverityCritical}, types.ReviewEventComment},
+		{"incremental", Report{Since: "abc"}, nil, types.ReviewEventComment},
+		{"incremental with findings", Report{Since: "abc"}, []types.Severity{types.SeverityCritical}, types.ReviewEventComment},
+	}
+	for _, test := range tests {
+		if event := reviewEvent(rules, test.severities, &test.report); event != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, event)
+		}
+	}
+}
+
+// TestIncompleteNote tests that the summary lists the files the review missed.
+func TestIncompleteNote(t *testing.T) {
+	if note := incompleteNote(&Report{}); note != "" {
+		t.Errorf("Expected no note for a complete review, got %q", note)
+	}
+
+	note := incompleteNote(&Report{
+		Failures:     []Failure{{Path: "a.go", Line: 1}, {Path: "a.go", Line: 9}, {Path: "b.go"}},
+		Unreviewed:   []string{"c.go"},
+		Truncated:    true,
+		SkippedFiles: 2,
+	})
+	for _, expected := range []string{
+		"The review is incomplete",
+		"could not review these files: `a.go`, `b.go`\n",
+		"before these files were reviewed: `c.go`",
+		"2 changed files were not reviewed",
+	} {
+		if !strings.Contains(note, expected) {
+			t.Errorf("Expected the note to contain %q, got %q", expected, note)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package review
+
+import (
+	"encoding/json"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+)
+
+// Report is the result of a review run.
+type Report struct {
+	// Host is the host name of the repository's remote, empty when the repository was named directly.
+	Host     string `json:"host,omitempty"`
+	Owner    string `json:"owner"`
+	Repo     string `json:"repo"`
+	PRNumber int    `json:"pr_number"`
+	// HeadSHA is the reviewed head commit of the pull request.
+	HeadSHA string `json:"head_sha,omitempty"`
+	// Since is the last reviewed commit when only the changes since it were reviewed.
+	Since string `json:"since,omitempty"`
+	// Source describes the reviewed local diff when the review is not of a pull request.
+	Source string       `json:"source,omitempty"`
+	Files  []FileReport `json:"files"`
+	// Truncated reports that the code host cut the file list at its limit; SkippedFiles counts the files left out
+	// when the host reports the total, and ListedFiles counts the files the host listed.
+	Truncated    bool `json:"truncated"`
+	SkippedFiles int  `json:"skipped_files"`
+	ListedFiles  int  `json:"listed_files,omitempty"`
+	// Failures lists the hunks the model could not review.
+	Failures []Failure `json:"failures"`
+	// Unreviewed lists the files with hunks that were not sent to the model because the budget of the
+	// run was exhausted.
+	Unreviewed []string `json:"unreviewed_files,omitempty"`
+	// Model is the model the hunks were sent to.
+	Model string      `json:"model,omitempty"`
+	Usage types.Usage `json:"usage"`
+	// Cost is the price of the usage in US dollars, 0 when the price of the model is unknown.
+	Cost float64 `json:"cost_usd"`
+	// Cached is the number of hunks answered from the cache, without a request.
+	Cached int `json:"cached_hunks"`
+}
+
+// UpToDate reports whether an incremental review found no commits since the last review.
+func (r *Report) UpToDate() bool {
+	return r.Since != "" && r.Since == r.HeadSHA
+}
+
+// Complete reports whether every changed file of the pull request was reviewed: no hunk failed or was
+// left unreviewed by the budget, and the code host did not truncate the file list.
+func (r *Report) Complete() bool {
+	return len(r.Failures) == 0 && len(r.Unreviewed) == 0 && !r.Truncated
+}
+
+// FileReport holds the findings of a single changed file.
+type FileReport struct {
+	Path      string      `json:"path"`
+	Additions int         `json:"additions"`
+	Deletions int         `json:"deletions"`
+	Findings  []Finding   `json:"findings"`
+	Usage     types.Usage `json:"usage"`
+	Cost      float64     `json:"cost_usd"`
+}
+
+// Finding is a single review comment produced by the model.
+type Finding struct {
+	Path      string         `json:"path"`
+	StartLine int            `json:"start_line"`
+	EndLine   int            `json:"end_line"`
+	Severity  types.Severity `json:"severity"`
+	Category  string         `json:"category"`
+	Title     string         `json:"title"`
+	Message   string         `json:"message"`
+	// Side is types.SideLeft when the lines are deleted lines numbered in the old version of the file,
+	// and types.SideRight or empty for lines of the new version.
+	Side string `json:"side,omitempty"`
+	// SuggestedFix is replacement code for the lines of the finding, if the model proposed any.
+	SuggestedFix string `json:"suggested_fix,omitempty"`
+	Model        string `json:"model"`
+	// Usage is the token usage of the request that produced the finding, shared by all findings of a hunk.
+	Usage types.Usage `json:"usage"`
+}
+
+// Deleted reports whether the finding is about deleted lines.
+func (f Finding) Deleted() bool {
+	return f.Side == types.SideLeft
+}
+
+// Failure describes a hunk that could not be reviewed.
+type Failure struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Err  error  `json:"-"`
+}
+
+// MarshalJSON encodes the failure with its error message.
+func (f Failure) MarshalJSON() ([]byte, error) {
+	type failure Failure
+	message := ""
+	if f.Err != nil {
+		message = f.Err.Error()
+	}
+	return json.Marshal(struct {
+		failure
+		Error string `json:"error"`
+	}{failure(f), message})
+}
+
+// File returns the report of the file with the given path, adding it if it is missing.
+func (r *Report) File(path string) *FileReport {
+	for i := range r.Files {
+		if r.Files[i].Path == path {
+			return &r.Files[i]
+		}
+	}
+	r.Files = append(r.Files, FileReport{Path: path})
+	return &r.Files[len(r.Files)-1]
+}
+
+// Findings returns the findings of every file in report order.
+func (r *Report) Findings() []Finding {
+	var findings []Finding
+	for _, file := range r.Files {
+		findings = append(findings, file.Findings...)
+	}
+	return findings
+}
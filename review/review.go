@@ -2,75 +2,419 @@ package review
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/ozgen/go-chatgpt-pr-reviewer/chatgpt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/cache"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/codehost"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
-	"github.com/ozgen/go-chatgpt-pr-reviewer/github"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/git"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/llm"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/snippet"
 	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
-	"log"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/utils"
+	"sort"
+	"sync"
 )
 
-func RunReview(localDir string, prNumber int, postComments bool) {
-	// Load configuration
-	apiKey := config.Envs.OpenAIApiKey
-	organizationId := config.Envs.OrganizationId
-	projectId := config.Envs.ProjectId
-	githubToken := config.Envs.GithubToken
-	ctx := context.Background()
+// ErrHeadMoved is returned by Run when the pull request's head is no longer the commit to review.
+var ErrHeadMoved = errors.New("pull request head moved")
 
-	// Get GitHub repository information
-	owner, repo, err := github.GetGitRemoteInfo(localDir)
-	if err != nil {
-		fmt.Printf("Error getting git remote info: %v\n", err)
-		return
+// Options configures a review run.
+type Options struct {
+	// LocalDir is the local git repository used to look up the owner and repository on the code host,
+	// and the repository diffed by RunLocal.
+	LocalDir string
+	// Remote is the git remote of LocalDir naming the repository, "origin" when empty.
+	Remote string
+	// Owner and Repo name the repository directly, skipping the lookup in LocalDir. The code host is
+	// then selected by the configuration.
+	Owner    string
+	Repo     string
+	PRNumber int
+	// HeadSHA, when set, is the head commit of the pull request to review; Run returns ErrHeadMoved
+	// without reviewing when the pull request moved on from it.
+	HeadSHA string
+	// Provider and Model select the LLM backend; an empty Model uses the provider's default.
+	Provider    string
+	Model       string
+	Temperature float64
+	// ContextWindow overrides the context window of the model in tokens; 0 uses the model registry.
+	ContextWindow int
+	// Concurrency is the number of hunks reviewed in parallel.
+	Concurrency int
+	RetryPolicy retry.Policy
+	// SystemPrompt replaces the default review instructions; ReviewPrompt is a text/template rendering
+	// each hunk with the fields .Path, .Code and .Context. Empty values use the built-in prompts.
+	SystemPrompt string
+	ReviewPrompt string
+	// Include and Exclude are glob patterns selecting the reviewed files; an empty Include selects all.
+	Include []string
+	Exclude []string
+	// SeverityThreshold drops findings below this severity from the report.
+	SeverityThreshold types.Severity
+	// Prices overrides the built-in prices of the models, see config.Config.Prices.
+	Prices map[string]config.Price
+	// MaxCost and MaxTokensTotal cap the spending of the run in US dollars and tokens; 0 means no limit.
+	// Hunks that would exceed a cap are not sent to the model.
+	MaxCost        float64
+	MaxTokensTotal int
+	// MaxComments caps the inline comments of a published review, keeping the most severe; 0 means no limit.
+	MaxComments int
+	// PostMode selects what Publish submits, see the config.PostMode constants.
+	PostMode string
+	// Context selects the code around each hunk sent to the model.
+	Context config.Context
+	// Cache configures the cache of model answers.
+	Cache config.Cache
+	// Incremental makes Run review only the commits since the last review recorded in StateDir, see Record.
+	Incremental bool
+	StateDir    string
+	// Source returns the new version of a changed file for the context of its hunks; nil sends no context.
+	// Run and RunLocal set it.
+	Source func(ctx context.Context, path string) (string, error)
+	// Config holds the credentials of the code hosts and the LLM providers.
+	Config config.Config
+}
+
+// OptionsFromConfig returns the options of a review run with the given configuration. The caller
+// sets the repository and pull request to review.
+func OptionsFromConfig(cfg config.Config) Options {
+	policy := retry.DefaultPolicy()
+	policy.MaxAttempts = cfg.MaxRetries + 1
+	policy.MaxDelay = cfg.RetryMaxDelay
+
+	severity, _ := types.ParseSeverity(cfg.SeverityThreshold)
+	return Options{
+		Remote:            cfg.Remote,
+		Provider:          cfg.Provider,
+		Model:             cfg.Model,
+		Temperature:       cfg.Temperature,
+		ContextWindow:     cfg.ContextWindow,
+		Concurrency:       cfg.Concurrency,
+		RetryPolicy:       policy,
+		SystemPrompt:      cfg.Prompts.System,
+		ReviewPrompt:      cfg.Prompts.Review,
+		Include:           cfg.Include,
+		Exclude:           cfg.Exclude,
+		SeverityThreshold: severity,
+		MaxComments:       cfg.MaxComments,
+		Prices:            cfg.Prices,
+		MaxCost:           cfg.MaxCost,
+		MaxTokensTotal:    cfg.MaxTokensTotal,
+		PostMode:          cfg.PostMode,
+		Context:           cfg.Context,
+		Cache:             cfg.Cache,
+		Incremental:       cfg.Incremental,
+		StateDir:          cfg.StateDir,
+		Config:            cfg,
 	}
+}
+
+// hunk is a request reviewing one or more adjacent modified blocks of a file. source returns the new
+// version of the file, nil when it is unavailable.
+type hunk struct {
+	path   string
+	blocks []diff.Block
+	source func() *snippet.File
+}
 
-	fmt.Printf("Owner: %s, Repo: %s\n", owner, repo)
+// line returns the first added line of the hunk in the new version of the file, or the first deleted
+// line in the old version if its first block only deletes lines.
+func (h hunk) line() int {
+	if len(h.blocks) == 0 {
+		return 0
+	}
+	start, _ := h.blocks[0].NewRange()
+	if start == 0 {
+		start, _ = h.blocks[0].OldRange()
+	}
+	return start
+}
 
-	// Set up GitHub client
-	githubClient := github.SetupGitHubClient(ctx, githubToken)
+// hunkResult holds the findings of a hunk.
+type hunkResult struct {
+	hunk
+	findings []Finding
+	usage    types.Usage
+	err      error
+}
+
+// Run fetches the changes of the pull or merge request from its code host and reviews them with
+// ReviewDiff. Incremental runs only review the hunks changed since the last recorded review.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	// Get the repository information
+	remote, err := repository(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set up the code host client
+	host, err := codehost.New(ctx, opts.Config, remote, opts.RetryPolicy)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get PR changes
-	files, err := github.GetPRChanges(ctx, githubClient, owner, repo, prNumber)
+	changes, err := host.Changes(ctx, opts.PRNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PR files: %w", err)
+	}
+	if opts.HeadSHA != "" && changes.HeadSHA != opts.HeadSHA {
+		return nil, fmt.Errorf("%w: expected %s, found %s", ErrHeadMoved, shortSHA(opts.HeadSHA), shortSHA(changes.HeadSHA))
+	}
+
+	if opts.Source == nil {
+		opts.Source = func(ctx context.Context, path string) (string, error) {
+			return host.File(ctx, changes.HeadSHA, path)
+		}
+	}
+	files, since := changes.Files, ""
+	if opts.Incremental {
+		if files, since, err = incrementalChanges(ctx, opts, host, remote, changes); err != nil {
+			return nil, err
+		}
+	}
+	report, err := ReviewDiff(ctx, opts, files)
+	if report != nil {
+		report.Host, report.Owner, report.Repo, report.PRNumber = remote.Host, remote.Owner, remote.Repo, opts.PRNumber
+		report.HeadSHA, report.Since = changes.HeadSHA, since
+		report.Truncated, report.SkippedFiles, report.ListedFiles = changes.Truncated, changes.SkippedFiles, len(changes.Files)
+	}
+	return report, err
+}
+
+// repository returns the repository named by the options, looking up the remote of the local
+// repository when the owner and name are not set.
+func repository(ctx context.Context, opts Options) (git.Remote, error) {
+	if opts.Owner != "" && opts.Repo != "" {
+		return git.Remote{Owner: opts.Owner, Repo: opts.Repo}, nil
+	}
+	name := opts.Remote
+	if name == "" {
+		name = "origin"
+	}
+	remote, err := git.GetRemote(ctx, opts.LocalDir, name)
+	if err != nil {
+		return git.Remote{}, fmt.Errorf("failed to get git remote info: %w", err)
+	}
+	return remote, nil
+}
+
+// RunLocal reviews the changes of the local repository in opts.LocalDir selected by diffOpts, without
+// talking to GitHub.
+func RunLocal(ctx context.Context, opts Options, diffOpts git.DiffOptions) (*Report, error) {
+	files, err := git.Diff(ctx, opts.LocalDir, diffOpts)
 	if err != nil {
-		log.Fatalf("Failed to get PR files: %v", err)
+		return nil, fmt.Errorf("failed to get local changes: %w", err)
 	}
 
-	// Display the files with changes
+	if opts.Source == nil {
+		opts.Source = func(ctx context.Context, path string) (string, error) {
+			return git.FileContent(ctx, opts.LocalDir, diffOpts, path)
+		}
+	}
+	report, err := ReviewDiff(ctx, opts, files)
+	if report != nil {
+		report.Source = diffOpts.String()
+	}
+	return report, err
+}
+
+// ReviewDiff reviews every modified block of the files with the configured model and returns the
+// findings. Hunks the model failed to review are listed in the report's failures. If the context is
+// cancelled, the partial report is returned together with the context's error.
+func ReviewDiff(ctx context.Context, opts Options, files []types.FileDiff) (*Report, error) {
+	report := &Report{}
+
+	// Drop the files excluded by the include and exclude patterns
+	var selected []types.FileDiff
 	for _, file := range files {
-		fmt.Printf("File: %s, Changes: +%d -%d\n", file.GetFilename(), file.GetAdditions(), file.GetDeletions())
+		ok, err := selectFile(file.Path, opts.Include, opts.Exclude)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, file)
+		}
 	}
+	files = selected
 
-	// Set up ChatGPT client
-	client := chatgpt.NewChatGPTClient(apiKey, organizationId, projectId)
+	for _, file := range files {
+		report.Files = append(report.Files, FileReport{
+			Path:      file.Path,
+			Additions: file.Additions,
+			Deletions: file.Deletions,
+		})
+	}
 
-	// Process each file and send the modified blocks to ChatGPT for review
+	prompts, err := newPrompts(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set up the LLM client for the selected provider
+	client, err := llm.NewClient(opts.Provider, opts.Config, opts.RetryPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up LLM client: %w", err)
+	}
+
+	// Plan the requests for the modified blocks of every file within the context window of the model
+	limit, err := prompts.codeBudget()
+	if err != nil {
+		return nil, err
+	}
+	spending, err := newBudget(prompts.model, opts)
+	if err != nil {
+		return nil, err
+	}
+	responses, err := openCache(opts.Cache)
+	if err != nil {
+		return nil, err
+	}
+	var hunks []hunk
 	for _, file := range files {
-		modifiedLines := github.ExtractModifiedLinesWithNumbers(file.GetPatch())
-
-		for _, modifiedLine := range modifiedLines {
-			// Send each modified block to ChatGPT
-			prompt := fmt.Sprintf("Code Review Request: Review the following block in file %s starting at line %d. Suggest any improvements:\n\n%s", file.GetFilename(), modifiedLine.LineNumber, modifiedLine.Content)
-			feedback, err := client.SendRequest(types.Payload{
-				Prompt:    prompt,
-				MaxTokens: 500,
-			})
-
-			if err != nil {
-				log.Printf("Error during ChatGPT review: %v", err)
-				continue
+		parsed, err := diff.Parse(file.Patch)
+		if err != nil {
+			report.Failures = append(report.Failures, Failure{Path: file.Path, Err: fmt.Errorf("failed to parse diff: %w", err)})
+			continue
+		}
+		source := fileSource(ctx, opts, file.Path)
+		for _, h := range planRequests(file.Path, diff.Blocks(parsed), prompts.count, limit) {
+			h.source = source
+			hunks = append(hunks, h)
+		}
+	}
+
+	// Send the requests to the model concurrently
+	results := reviewHunks(ctx, hunks, opts.Concurrency, func(ctx context.Context, h hunk) ([]Finding, types.Usage, error) {
+		return reviewHunk(ctx, client, opts, prompts, spending, responses, h)
+	})
+	if responses != nil {
+		report.Cached = responses.Hits()
+		// Eviction is best effort, entries left behind are evicted by a later run
+		responses.Prune()
+	}
+
+	report.Model = prompts.model.Name
+	for _, result := range results {
+		report.Usage = report.Usage.Add(result.usage)
+		file := report.File(result.path)
+		file.Usage = file.Usage.Add(result.usage)
+		cost := prompts.model.Cost(result.usage)
+		report.Cost += cost
+		file.Cost += cost
+		if errors.Is(result.err, ErrBudgetExceeded) {
+			if n := len(report.Unreviewed); n == 0 || report.Unreviewed[n-1] != result.path {
+				report.Unreviewed = append(report.Unreviewed, result.path)
 			}
+			continue
+		}
+		if result.err != nil {
+			report.Failures = append(report.Failures, Failure{Path: result.path, Line: result.line(), Err: result.err})
+			continue
+		}
+		for _, finding := range result.findings {
+			if finding.Severity.AtLeast(opts.SeverityThreshold) {
+				file.Findings = append(file.Findings, finding)
+			}
+		}
+	}
 
-			fmt.Printf("Feedback for file %s at line %d:\n%s\n", file.GetFilename(), modifiedLine.LineNumber, feedback)
+	return report, ctx.Err()
+}
 
-			if feedback != "" && postComments {
-				commentBody := fmt.Sprintf("ChatGPT suggests:\n%s", feedback)
-				path := file.GetFilename()
-				err = github.PostReviewComment(ctx, githubClient, owner, repo, prNumber, commentBody, path, modifiedLine.LineNumber)
-				if err != nil {
-					log.Printf("Failed to post comment: %v", err)
+// openCache opens the cache of model answers, or returns nil when it is disabled or no cache
+// directory is known.
+func openCache(settings config.Cache) (*cache.Cache, error) {
+	dir := config.CacheDir(settings.Dir)
+	if !settings.Enabled || dir == "" {
+		return nil, nil
+	}
+	responses, err := cache.Open(dir, settings.TTL, int64(settings.MaxSizeMB)<<20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the answer cache: %w", err)
+	}
+	return responses, nil
+}
+
+// fileSource returns a function fetching the new version of the file once, on the first call, for the
+// context of its hunks. Files that cannot be fetched, such as deleted files, are reviewed without context.
+func fileSource(ctx context.Context, opts Options, path string) func() *snippet.File {
+	if opts.Source == nil || opts.Context.MaxTokens <= 0 {
+		return func() *snippet.File { return nil }
+	}
+	return sync.OnceValue(func() *snippet.File {
+		content, err := opts.Source(ctx, path)
+		if err != nil {
+			return nil
+		}
+		return snippet.Parse(path, content)
+	})
+}
+
+// selectFile reports whether the path matches an include pattern, or there are none, and matches no
+// exclude pattern.
+func selectFile(path string, include, exclude []string) (bool, error) {
+	if len(include) > 0 {
+		ok, err := matchesAny(include, path)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	excluded, err := matchesAny(exclude, path)
+	return !excluded && err == nil, err
+}
+
+// matchesAny reports whether the path matches one of the glob patterns.
+func matchesAny(patterns []string, path string) (bool, error) {
+	for _, pattern := range patterns {
+		match, err := utils.MatchGlob(pattern, path)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
+// reviewHunks reviews the hunks with a bounded pool of workers and returns the results ordered by file,
+// then line, regardless of the order in which the requests complete. Hunks that were not started before
+// the context was cancelled are reported with the context error.
+func reviewHunks(ctx context.Context, hunks []hunk, concurrency int, review func(context.Context, hunk) ([]Finding, types.Usage, error)) []hunkResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]hunkResult, len(hunks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				result := hunkResult{hunk: hunks[index]}
+				if err := ctx.Err(); err != nil {
+					result.err = err
+				} else {
+					result.findings, result.usage, result.err = review(ctx, hunks[index])
 				}
+				results[index] = result
 			}
-		}
+		}()
+	}
+	for index := range hunks {
+		jobs <- index
 	}
+	close(jobs)
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].path != results[j].path {
+			return results[i].path < results[j].path
+		}
+		return results[i].line() < results[j].line()
+	})
+	return results
 }
@@ -0,0 +1,180 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/config"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/diff"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/retry"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestReviewHunksOrdering tests that results are ordered by file and line and the worker pool stays bounded.
+func TestReviewHunksOrdering(t *testing.T) {
+	hunks := []hunk{
+		{path: "b.go", blocks: []diff.Block{addedLine(10)}},
+		{path: "a.go", blocks: []diff.Block{addedLine(30)}},
+		{path: "a.go", blocks: []diff.Block{addedLine(5)}},
+		{path: "c.go", blocks: []diff.Block{addedLine(1)}},
+	}
+
+	var running, maxRunning int32
+	results := reviewHunks(context.Background(), hunks, 2, func(ctx context.Context, h hunk) ([]Finding, types.Usage, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			highest := atomic.LoadInt32(&maxRunning)
+			if current <= highest || atomic.CompareAndSwapInt32(&maxRunning, highest, current) {
+				break
+			}
+		}
+		// Finish the first hunks last to shuffle the completion order
+		time.Sleep(time.Duration(40-h.line()) * time.Millisecond)
+		return []Finding{{Message: fmt.Sprintf("%s:%d", h.path, h.line())}}, types.Usage{TotalTokens: 1}, nil
+	})
+
+	expected := []string{"a.go:5", "a.go:30", "b.go:10", "c.go:1"}
+	for i, result := range results {
+		if len(result.findings) != 1 || result.findings[0].Message != expected[i] || result.usage.TotalTokens != 1 {
+			t.Errorf("At index %d, expected %s, got %+v", i, expected[i], result)
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", maxRunning)
+	}
+}
+
+// TestReviewHunksCancelled tests that hunks are not sent once the context is cancelled.
+func TestReviewHunksCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var calls int32
+	results := reviewHunks(ctx, []hunk{{path: "a.go"}, {path: "b.go"}}, 1, func(ctx context.Context, h hunk) ([]Finding, types.Usage, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, types.Usage{}, nil
+	})
+
+	if calls != 0 {
+		t.Errorf("Expected no requests after cancellation, got %d", calls)
+	}
+	for _, result := range results {
+		if result.err != context.Canceled {
+			t.Errorf("Expected context.Canceled for %s, got %v", result.path, result.err)
+		}
+	}
+}
+
+// TestHunkLine tests the line a hunk is sorted and reported by.
+func TestHunkLine(t *testing.T) {
+	if line := testHunk.line(); line != 20 {
+		t.Errorf("Expected line 20, got %d", line)
+	}
+
+	deletion := hunk{blocks: []diff.Block{{Lines: []diff.Line{{Op: diff.Delete, Text: "removed()", OldLine: 7}}}}}
+	if line := deletion.line(); line != 7 {
+		t.Errorf("Expected the old line 7 for a deletion, got %d", line)
+	}
+}
+
+// addedLine returns a block adding a single line.
+func addedLine(number int) diff.Block {
+	return diff.Block{Lines: []diff.Line{{Op: diff.Add, Text: "code()", NewLine: number}}}
+}
+
+// TestReportFindings tests that findings are grouped per file and summarized by severity.
+func TestReportFindings(t *testing.T) {
+	report := &Report{Files: []FileReport{{Path: "a.go"}}}
+	report.File("a.go").Findings = append(report.File("a.go").Findings, Finding{Path: "a.go", Severity: types.SeverityMajor})
+	report.File("b.go").Findings = append(report.File("b.go").Findings, Finding{Path: "b.go", Severity: types.SeverityInfo})
+
+	if len(report.Files) != 2 || len(report.Findings()) != 2 {
+		t.Fatalf("Expected 2 files with one finding each, got %+v", report.Files)
+	}
+
+	expected := "PR Reviewer left 2 comments on 2 files.\n\n- major: 1\n- info: 1"
+	if summary := reviewSummary(report.Findings(), 2); summary != expected {
+		t.Errorf("Expected summary %q, got %q", expected, summary)
+	}
+}
+
+// TestInlineFindings tests that capped comments keep the most severe findings.
+func TestInlineFindings(t *testing.T) {
+	findings := []Finding{
+		{Path: "a.go", Severity: types.SeverityInfo},
+		{Path: "b.go", Severity: types.SeverityCritical},
+		{Path: "c.go", Severity: types.SeverityMinor},
+	}
+
+	inline := inlineFindings(findings, Options{MaxComments: 2})
+	if len(inline) != 2 || inline[0].Path != "b.go" || inline[1].Path != "c.go" {
+		t.Errorf("Expected the critical and minor findings, got %+v", inline)
+	}
+	if inline := inlineFindings(findings, Options{}); len(inline) != 3 {
+		t.Errorf("Expected every finding without a limit, got %+v", inline)
+	}
+	if inline := inlineFindings(findings, Options{PostMode: config.PostModeSummary}); len(inline) != 0 {
+		t.Errorf("Expected no inline comments in summary mode, got %+v", inline)
+	}
+
+	expected := "PR Reviewer found 3 issues in 3 files, 2 of them are commented inline.\n\n- critical: 1\n- minor: 1\n- info: 1"
+	if summary := reviewSummary(findings, 2); summary != expected {
+		t.Errorf("Expected summary %q, got %q", expected, summary)
+	}
+}
+
+// TestSelectFile tests the include and exclude patterns.
+func TestSelectFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		include  []string
+		exclude  []string
+		selected bool
+	}{
+		{"main.go", nil, nil, true},
+		{"vendor/x/y.go", nil, []string{"vendor/**"}, false},
+		{"review/review_test.go", []string{"*.go"}, []string{"*_test.go"}, false},
+		{"review/review.go", []string{"*.go"}, []string{"*_test.go"}, true},
+		{"README.md", []string{"*.go"}, nil, false},
+	}
+
+	for _, test := range tests {
+		selected, err := selectFile(test.path, test.include, test.exclude)
+		if err != nil || selected != test.selected {
+			t.Errorf("Expected %s selected=%v, got %v (%v)", test.path, test.selected, selected, err)
+		}
+	}
+}
+
+// TestRunHeadMoved tests that a pull request that moved on from the head to review is not reviewed.
+func TestRunHeadMoved(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/owner/repo/pulls/8":
+			w.Write([]byte(`{"head":{"sha":"newer"}}`))
+		case "/api/v1/repos/owner/repo/pulls/8/files":
+			w.Header().Set("X-Total-Count", "0")
+			w.Write([]byte(`[]`))
+		case "/api/v1/repos/owner/repo/pulls/8.diff":
+			w.Write([]byte(``))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.CodeHost, cfg.GiteaURL = "gitea", server.URL
+	opts := OptionsFromConfig(cfg)
+	opts.Owner, opts.Repo, opts.PRNumber, opts.HeadSHA = "owner", "repo", 8, "queued"
+	opts.RetryPolicy = retry.Policy{MaxAttempts: 1}
+	if _, err := Run(context.Background(), opts); !errors.Is(err, ErrHeadMoved) {
+		t.Errorf("Expected ErrHeadMoved, got %v", err)
+	}
+}
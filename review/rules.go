@@ -0,0 +1,53 @@
+package review
+
+import (
+	"fmt"
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+)
+
+// EventRules decides which review event is submitted for the severities of the collected findings.
+// An empty severity disables the corresponding rule.
+type EventRules struct {
+	// RequestChangesAt requests changes when any finding is at or above this severity.
+	RequestChangesAt types.Severity
+	// ApproveBelow approves the pull request when every finding is below this severity.
+	ApproveBelow types.Severity
+}
+
+// Event returns the review event for the given finding severities.
+func (r EventRules) Event(severities []types.Severity) string {
+	highest := types.Severity("")
+	for _, severity := range severities {
+		if severity.Rank() > highest.Rank() {
+			highest = severity
+		}
+	}
+
+	if r.RequestChangesAt != "" && highest != "" && highest.AtLeast(r.RequestChangesAt) {
+		return types.ReviewEventRequestChanges
+	}
+	if r.ApproveBelow != "" && highest.Rank() < r.ApproveBelow.Rank() {
+		return types.ReviewEventApprove
+	}
+	return types.ReviewEventComment
+}
+
+// ParseEventRules builds the event rules from severity names, rejecting unknown severities.
+func ParseEventRules(requestChangesAt, approveBelow string) (EventRules, error) {
+	var rules EventRules
+	if requestChangesAt != "" {
+		severity, ok := types.ParseSeverity(requestChangesAt)
+		if !ok {
+			return rules, fmt.Errorf("unknown severity %q", requestChangesAt)
+		}
+		rules.RequestChangesAt = severity
+	}
+	if approveBelow != "" {
+		severity, ok := types.ParseSeverity(approveBelow)
+		if !ok {
+			return rules, fmt.Errorf("unknown severity %q", approveBelow)
+		}
+		rules.ApproveBelow = severity
+	}
+	return rules, nil
+}
@@ -0,0 +1,31 @@
+package review
+
+import (
+	"github.com/ozgen/go-chatgpt-pr-reviewer/types"
+	"testing"
+)
+
+// TestEventRules tests the review event chosen for different finding severities.
+func TestEventRules(t *testing.T) {
+	rules := EventRules{RequestChangesAt: types.SeverityMajor, ApproveBelow: types.SeverityMinor}
+
+	tests := []struct {
+		severities []types.Severity
+		expected   string
+	}{
+		{nil, types.ReviewEventApprove},
+		{[]types.Severity{types.SeverityInfo}, types.ReviewEventApprove},
+		{[]types.Severity{types.SeverityInfo, types.SeverityMinor}, types.ReviewEventComment},
+		{[]types.Severity{types.SeverityMinor, types.SeverityCritical}, types.ReviewEventRequestChanges},
+	}
+
+	for _, test := range tests {
+		if event := rules.Event(test.severities); event != test.expected {
+			t.Errorf("For %v expected %s, got %s", test.severities, test.expected, event)
+		}
+	}
+
+	if event := (EventRules{}).Event([]types.Severity{types.SeverityCritical}); event != types.ReviewEventComment {
+		t.Errorf("Expected COMMENT without rules, got %s", event)
+	}
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxPayloadSize is the largest webhook payload GitHub delivers.
+const maxPayloadSize = 25 << 20
+
+// reviewedActions are the pull_request actions that trigger a review.
+var reviewedActions = map[string]bool{"opened": true, "synchronize": true, "reopened": true, "ready_for_review": true}
+
+// Job is a pull request queued for review.
+type Job struct {
+	DeliveryID string
+	Owner      string
+	Repo       string
+	PRNumber   int
+	HeadSHA    string
+	// InstallationID is the GitHub App installation that sent the event, zero for repository webhooks.
+	InstallationID int64
+}
+
+// ReviewFunc reviews the pull request of a job.
+type ReviewFunc func(ctx context.Context, job Job) error
+
+// Options configures a webhook server.
+type Options struct {
+	// Secret is the webhook secret shared with GitHub, used to verify X-Hub-Signature-256.
+	Secret string
+	// QueueSize bounds the pending jobs; deliveries arriving at a full queue are rejected with 503.
+	QueueSize int
+	// Workers is the number of pull requests reviewed in parallel.
+	Workers int
+	// MaxDeliveries is the number of recent delivery IDs remembered to drop redelivered events.
+	MaxDeliveries int
+}
+
+// Server receives GitHub pull_request webhooks and reviews the pull requests in the background.
+type Server struct {
+	opts       Options
+	review     ReviewFunc
+	queue      chan Job
+	deliveries *deliveryCache
+}
+
+// pullRequestEvent holds the fields of a pull_request webhook payload used by the server.
+type pullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Draft bool `json:"draft"`
+		Head  struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
+
+// New creates a server reviewing the queued pull requests with review.
+func New(opts Options, review ReviewFunc) *Server {
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 100
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxDeliveries < 1 {
+		opts.MaxDeliveries = 10000
+	}
+	return &Server{
+		opts:       opts,
+		review:     review,
+		queue:      make(chan Job, opts.QueueSize),
+		deliveries: newDeliveryCache(opts.MaxDeliveries),
+	}
+}
+
+// Handler returns the HTTP handler serving the webhook endpoint at /webhook and a health check at /healthz.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// Run reviews the queued jobs with the configured number of workers until the context is cancelled.
+// Jobs still queued at that point are dropped.
+func (s *Server) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.queue:
+					log.Printf("Reviewing %s/%s#%d (delivery %s)", job.Owner, job.Repo, job.PRNumber, job.DeliveryID)
+					if err := s.review(ctx, job); err != nil {
+						log.Printf("Review of %s/%s#%d failed: %v", job.Owner, job.Repo, job.PRNumber, err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// handleWebhook verifies and queues a webhook delivery.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(s.opts.Secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		io.WriteString(w, "pong\n")
+		return
+	case "pull_request":
+	default:
+		respond(w, http.StatusOK, "ignored event")
+		return
+	}
+
+	var event pullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if !reviewedActions[event.Action] || event.PullRequest.Draft {
+		respond(w, http.StatusOK, "ignored action")
+		return
+	}
+	if event.Repository.Owner.Login == "" || event.Repository.Name == "" || event.Number == 0 {
+		http.Error(w, "payload without repository or pull request", http.StatusBadRequest)
+		return
+	}
+
+	deliveryID := r.Header.Get("X-GitHub-Delivery")
+	if deliveryID != "" && !s.deliveries.add(deliveryID) {
+		respond(w, http.StatusOK, "duplicate delivery")
+		return
+	}
+
+	job := Job{
+		DeliveryID:     deliveryID,
+		Owner:          event.Repository.Owner.Login,
+		Repo:           event.Repository.Name,
+		PRNumber:       event.Number,
+		HeadSHA:        event.PullRequest.Head.SHA,
+		InstallationID: event.Installation.ID,
+	}
+	select {
+	case s.queue <- job:
+		respond(w, http.StatusAccepted, fmt.Sprintf("queued review of %s/%s#%d", job.Owner, job.Repo, job.PRNumber))
+	default:
+		// Let GitHub's redelivery retry the event
+		s.deliveries.remove(deliveryID)
+		http.Error(w, "review queue is full", http.StatusServiceUnavailable)
+	}
+}
+
+// validSignature reports whether the X-Hub-Signature-256 header is the HMAC-SHA256 of the body.
+func validSignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// respond writes a plain text response.
+func respond(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, message+"\n")
+}
+
+// deliveryCache remembers the most recent delivery IDs.
+type deliveryCache struct {
+	mu    sync.Mutex
+	ids   map[string]bool
+	order []string
+	next  int
+}
+
+// newDeliveryCache creates a cache holding up to size delivery IDs.
+func newDeliveryCache(size int) *deliveryCache {
+	return &deliveryCache{ids: make(map[string]bool), order: make([]string, size)}
+}
+
+// add records the delivery ID and reports whether it was new. The oldest ID is forgotten when the
+// cache is full.
+func (c *deliveryCache) add(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ids[id] {
+		return false
+	}
+	if oldest := c.order[c.next]; oldest != "" {
+		delete(c.ids, oldest)
+	}
+	c.order[c.next] = id
+	c.next = (c.next + 1) % len(c.order)
+	c.ids[id] = true
+	return true
+}
+
+// remove forgets the delivery ID so that a redelivery is accepted.
+func (c *deliveryCache) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ids, id)
+	for i := range c.order {
+		if c.order[i] == id {
+			c.order[i] = ""
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "webhook-secret"
+
+const testPayload = `{"action":"opened","number":7,"pull_request":{"draft":false,"head":{"sha":"abc123"}},` +
+	`"repository":{"name":"repo","owner":{"login":"owner"}},"installation":{"id":42}}`
+
+// sign returns the X-Hub-Signature-256 header of the body.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts a signed webhook to the server and returns the status code.
+func deliver(t *testing.T, handler http.Handler, event, delivery, body, signature string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", delivery)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+// TestWebhook tests signature verification, event filtering, deduplication and queueing.
+func TestWebhook(t *testing.T) {
+	s := New(Options{Secret: testSecret, QueueSize: 1}, nil)
+	handler := s.Handler()
+
+	if code := deliver(t, handler, "pull_request", "1", testPayload, sign("wrong", testPayload)); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a bad signature, got %d", code)
+	}
+	if code := deliver(t, handler, "pull_request", "1", testPayload, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without signature, got %d", code)
+	}
+	if code := deliver(t, handler, "ping", "0", `{}`, sign(testSecret, `{}`)); code != http.StatusOK {
+		t.Errorf("Expected 200 for a ping, got %d", code)
+	}
+
+	closed := strings.Replace(testPayload, `"opened"`, `"closed"`, 1)
+	if code := deliver(t, handler, "pull_request", "2", closed, sign(testSecret, closed)); code != http.StatusOK || len(s.queue) != 0 {
+		t.Errorf("Expected closed pull requests to be ignored, got %d", code)
+	}
+	draft := strings.Replace(testPayload, `"draft":false`, `"draft":true`, 1)
+	if code := deliver(t, handler, "pull_request", "3", draft, sign(testSecret, draft)); code != http.StatusOK || len(s.queue) != 0 {
+		t.Errorf("Expected drafts to be ignored, got %d", code)
+	}
+
+	if code := deliver(t, handler, "pull_request", "4", testPayload, sign(testSecret, testPayload)); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	job := <-s.queue
+	expected := Job{DeliveryID: "4", Owner: "owner", Repo: "repo", PRNumber: 7, HeadSHA: "abc123", InstallationID: 42}
+	if job != expected {
+		t.Errorf("Expected job %+v, got %+v", expected, job)
+	}
+
+	// Redeliveries are dropped
+	if code := deliver(t, handler, "pull_request", "4", testPayload, sign(testSecret, testPayload)); code != http.StatusOK || len(s.queue) != 0 {
+		t.Errorf("Expected a duplicate delivery to be dropped, got %d", code)
+	}
+
+	// A full queue rejects the delivery and accepts its redelivery later
+	deliver(t, handler, "pull_request", "5", testPayload, sign(testSecret, testPayload))
+	if code := deliver(t, handler, "pull_request", "6", testPayload, sign(testSecret, testPayload)); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a full queue, got %d", code)
+	}
+	<-s.queue
+	if code := deliver(t, handler, "pull_request", "6", testPayload, sign(testSecret, testPayload)); code != http.StatusAccepted {
+		t.Errorf("Expected the redelivery to be queued, got %d", code)
+	}
+}
+
+// TestRun tests that queued jobs are reviewed by the workers.
+func TestRun(t *testing.T) {
+	reviewed := make(chan Job, 1)
+	s := New(Options{Secret: testSecret}, func(ctx context.Context, job Job) error {
+		reviewed <- job
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	deliver(t, s.Handler(), "pull_request", "1", testPayload, sign(testSecret, testPayload))
+	select {
+	case job := <-reviewed:
+		if job.PRNumber != 7 {
+			t.Errorf("Unexpected job %+v", job)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the job to be reviewed")
+	}
+
+	cancel()
+	<-done
+}
+
+// TestDeliveryCache tests that the oldest delivery IDs are forgotten.
+func TestDeliveryCache(t *testing.T) {
+	cache := newDeliveryCache(2)
+	if !cache.add("a") || !cache.add("b") || cache.add("a") {
+		t.Fatal("Expected a and b to be added once")
+	}
+	if !cache.add("c") || !cache.add("a") {
+		t.Error("Expected a to be forgotten after c was added")
+	}
+}
@@ -0,0 +1,255 @@
+package snippet
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Range is a range of lines of a file, numbered from 1 with an inclusive end.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether the range covers every line of other.
+func (r Range) Contains(other Range) bool {
+	return r.Start <= other.Start && other.End <= r.End
+}
+
+// File is the content of a source file split into lines, with the syntax tree of Go files.
+type File struct {
+	lines []string
+	fset  *token.FileSet
+	ast   *ast.File
+}
+
+// declaration matches lines declaring a function or type in common languages.
+var declaration = regexp.MustCompile(`^(?:(?:export|default|public|private|protected|internal|static|async|abstract|final|override|virtual|sealed|pub(?:\([a-z]+\))?|unsafe|extern|inline|const)\s+)*` +
+	`(?:func|function|def|fn|fun|sub|class|struct|enum|union|interface|trait|impl|module|object|record|type|procedure|method|macro_rules!)\b`)
+
+// controlFlow matches lines opening a block that is not a declaration.
+var controlFlow = regexp.MustCompile(`^(?:\}\s*)?(?:if|else|for|foreach|while|do|switch|case|default|try|catch|finally|with|match|loop|select|return|unless|until)\b`)
+
+// importLine matches the import statements of common languages.
+var importLine = regexp.MustCompile(`^(?:import\b|from\s+\S+\s+import\b|#\s*include\b|using\s|require\b|require_relative\b|use\s|extern\s+crate\b|library\(|@import\b|(?:const|let|var)\s+.*=\s*require\()`)
+
+// Parse splits the content of the file at path into lines. Go files are also parsed; if their syntax
+// is broken the heuristics for other languages are used.
+func Parse(path, content string) *File {
+	f := &File{lines: strings.Split(strings.TrimSuffix(content, "\n"), "\n")}
+	if content == "" {
+		f.lines = nil
+	}
+	if filepath.Ext(path) == ".go" {
+		fset := token.NewFileSet()
+		if parsed, err := parser.ParseFile(fset, path, content, parser.SkipObjectResolution); err == nil {
+			f.fset, f.ast = fset, parsed
+		}
+	}
+	return f
+}
+
+// Len returns the number of lines of the file.
+func (f *File) Len() int {
+	return len(f.lines)
+}
+
+// Line returns the text of the line with the given number, or an empty string outside of the file.
+func (f *File) Line(number int) string {
+	if number < 1 || number > len(f.lines) {
+		return ""
+	}
+	return f.lines[number-1]
+}
+
+// Clip limits the range to the lines of the file.
+func (f *File) Clip(r Range) Range {
+	if r.Start < 1 {
+		r.Start = 1
+	}
+	if r.End > len(f.lines) {
+		r.End = len(f.lines)
+	}
+	return r
+}
+
+// Enclosing returns the function, method or type declaration enclosing the lines of r. Go declarations
+// are taken from the syntax tree; for other languages the closest preceding declaration with less
+// indentation is extended to its closing brace, or to the end of its indented body.
+func (f *File) Enclosing(r Range) (Range, bool) {
+	if f.ast != nil {
+		return f.enclosingDecl(r)
+	}
+	return f.scanEnclosing(r)
+}
+
+// enclosingDecl returns the top-level Go declaration containing the range.
+func (f *File) enclosingDecl(r Range) (Range, bool) {
+	for _, decl := range f.ast.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+		lines := Range{Start: f.fset.Position(decl.Pos()).Line, End: f.fset.Position(decl.End()).Line}
+		if lines.Contains(r) {
+			return lines, true
+		}
+	}
+	return Range{}, false
+}
+
+// scanEnclosing finds the declaration enclosing the range by indentation and braces.
+func (f *File) scanEnclosing(r Range) (Range, bool) {
+	r = f.Clip(r)
+	if r.Start > r.End {
+		return Range{}, false
+	}
+
+	// The header has less indentation than every line of the range
+	limit := -1
+	for number := r.Start; number <= r.End; number++ {
+		if line := f.Line(number); strings.TrimSpace(line) != "" && (limit < 0 || Indentation(line) < limit) {
+			limit = Indentation(line)
+		}
+	}
+	header := 0
+	for number := r.Start - 1; number >= 1 && limit > 0; number-- {
+		line := f.Line(number)
+		text := strings.TrimSpace(line)
+		// Braces on their own line belong to the header above them
+		if text == "" || text == "{" || Indentation(line) >= limit {
+			continue
+		}
+		limit = Indentation(line)
+		if isDeclaration(text) || limit == 0 {
+			header = number
+			break
+		}
+	}
+	if header == 0 {
+		return Range{}, false
+	}
+
+	end := f.blockEnd(header)
+	if end < r.End {
+		return Range{}, false
+	}
+	return Range{Start: header, End: end}, true
+}
+
+// blockEnd returns the last line of the block opened by the header line: its closing brace for brace
+// delimited languages, otherwise the last line indented deeper than the header.
+func (f *File) blockEnd(header int) int {
+	if next := strings.TrimSpace(f.Line(header + 1)); strings.Contains(f.Line(header), "{") || strings.HasPrefix(next, "{") {
+		depth, opened := 0, false
+		for number := header; number <= f.Len(); number++ {
+			for _, c := range f.Line(number) {
+				switch c {
+				case '{':
+					depth++
+					opened = true
+				case '}':
+					depth--
+				}
+			}
+			if opened && depth <= 0 {
+				return number
+			}
+		}
+		return f.Len()
+	}
+
+	end := header
+	for number := header + 1; number <= f.Len(); number++ {
+		line := f.Line(number)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if Indentation(line) <= Indentation(f.Line(header)) {
+			break
+		}
+		end = number
+	}
+	return end
+}
+
+// Imports returns the import block of the file. For Go files these are the import declarations; for
+// other languages the import statements at the top of the file, skipping comments and blank lines.
+func (f *File) Imports() (Range, bool) {
+	if f.ast != nil {
+		var imports Range
+		for _, decl := range f.ast.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				if imports.Start == 0 {
+					imports.Start = f.fset.Position(gen.Pos()).Line
+				}
+				imports.End = f.fset.Position(gen.End()).Line
+			}
+		}
+		return imports, imports.Start > 0
+	}
+
+	var imports Range
+	open := 0
+	for number := 1; number <= f.Len(); number++ {
+		text := strings.TrimSpace(f.Line(number))
+		switch {
+		case open > 0:
+			// Continuation of a multi-line import such as import ( ... ) or import { ... } from
+			open += strings.Count(text, "(") + strings.Count(text, "{") - strings.Count(text, ")") - strings.Count(text, "}")
+			imports.End = number
+		case importLine.MatchString(text):
+			if imports.Start == 0 {
+				imports.Start = number
+			}
+			imports.End = number
+			open = strings.Count(text, "(") + strings.Count(text, "{") - strings.Count(text, ")") - strings.Count(text, "}")
+		case text == "" || isPreamble(text):
+		default:
+			return imports, imports.Start > 0
+		}
+	}
+	return imports, imports.Start > 0
+}
+
+// isDeclaration reports whether the trimmed line declares a function or type: a declaration keyword,
+// or a call-like signature opening a block that is not a control flow statement, as in C or Java.
+func isDeclaration(text string) bool {
+	if declaration.MatchString(text) {
+		return true
+	}
+	if controlFlow.MatchString(text) || !strings.Contains(text, "(") {
+		return false
+	}
+	return strings.HasSuffix(text, "{") || strings.HasSuffix(text, ")") || strings.HasSuffix(text, ":")
+}
+
+// isPreamble reports whether the trimmed line may precede the imports of a file: comments, package
+// and module declarations and directives.
+func isPreamble(text string) bool {
+	for _, prefix := range []string{"//", "#", "/*", "*", "--", "\"\"\"", "'''", "<?php", "package ", "namespace ", "'use ", "\"use "} {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Indentation returns the width of the leading whitespace of the line, counting tabs as four spaces.
+func Indentation(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
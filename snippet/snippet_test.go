@@ -0,0 +1,119 @@
+package snippet
+
+import (
+	"testing"
+)
+
+const goSource = `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// run prints the arguments.
+func run(args []string) error {
+	for _, arg := range args {
+		fmt.Println(arg)
+	}
+	return nil
+}
+
+type config struct {
+	name string
+}
+`
+
+const pythonSource = `"""Tools."""
+import os
+from typing import List
+
+class Tool:
+    def run(self, args):
+        for arg in args:
+            print(arg)
+
+        return len(args)
+
+    def stop(self):
+        pass
+`
+
+const javaSource = `package tools;
+
+import java.util.List;
+import java.util.Map;
+
+public class Tool {
+    public int run(List<String> args)
+    {
+        if (args.isEmpty()) {
+            return 0;
+        }
+        return args.size();
+    }
+}
+`
+
+const jsSource = `'use strict';
+import {
+  readFile,
+  writeFile,
+} from 'fs';
+const path = require('path');
+
+export async function load(name) {
+  const data = await readFile(path.join('.', name));
+  return data;
+}
+`
+
+// TestEnclosing tests finding the enclosing declaration with the Go syntax tree and the heuristics.
+func TestEnclosing(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		source   string
+		lines    Range
+		expected Range
+		found    bool
+	}{
+		{"go function", "main.go", goSource, Range{11, 11}, Range{9, 14}, true},
+		{"go type", "main.go", goSource, Range{17, 17}, Range{16, 18}, true},
+		{"go imports", "main.go", goSource, Range{4, 4}, Range{}, false},
+		{"python method", "tool.py", pythonSource, Range{7, 8}, Range{6, 10}, true},
+		{"python class", "tool.py", pythonSource, Range{12, 13}, Range{5, 13}, true},
+		{"java method", "Tool.java", javaSource, Range{10, 10}, Range{7, 13}, true},
+		{"javascript function", "load.js", jsSource, Range{9, 10}, Range{8, 11}, true},
+		{"top level", "load.js", jsSource, Range{7, 7}, Range{}, false},
+	}
+	for _, test := range tests {
+		enclosing, found := Parse(test.path, test.source).Enclosing(test.lines)
+		if found != test.found || (found && enclosing != test.expected) {
+			t.Errorf("%s: expected %v (%v), got %v (%v)", test.name, test.expected, test.found, enclosing, found)
+		}
+	}
+}
+
+// TestImports tests finding the import block of Go and other files.
+func TestImports(t *testing.T) {
+	tests := []struct {
+		path     string
+		source   string
+		expected Range
+	}{
+		{"main.go", goSource, Range{3, 6}},
+		{"tool.py", pythonSource, Range{2, 3}},
+		{"Tool.java", javaSource, Range{3, 4}},
+		{"load.js", jsSource, Range{2, 6}},
+	}
+	for _, test := range tests {
+		if imports, ok := Parse(test.path, test.source).Imports(); !ok || imports != test.expected {
+			t.Errorf("%s: expected imports %v, got %v", test.path, test.expected, imports)
+		}
+	}
+
+	if _, ok := Parse("main.go", "package main\n\nfunc main() {}\n").Imports(); ok {
+		t.Error("Expected no imports in a file without any")
+	}
+}
@@ -0,0 +1,77 @@
+//go:build ignore
+
+// fetch downloads the rank files of the encodings into the vocab directory, from which they are
+// embedded into the binary. Run it with go generate ./tokenizer; files that are already downloaded
+// and match their checksum are kept.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// file is a rank file and the SHA-256 checksum that tiktoken verifies it against.
+type file struct {
+	url    string
+	sha256 string
+}
+
+var files = map[string]file{
+	"cl100k_base.tiktoken": {
+		url:    "https://openaipublic.blob.core.windows.net/encodings/cl100k_base.tiktoken",
+		sha256: "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	},
+	"o200k_base.tiktoken": {
+		url:    "https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken",
+		sha256: "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+	},
+}
+
+func main() {
+	for name, f := range files {
+		path := filepath.Join("vocab", name)
+		if data, err := os.ReadFile(path); err == nil && checksum(data) == f.sha256 {
+			continue
+		}
+		if err := fetch(f, path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// fetch downloads the file into the path, after verifying its checksum.
+func fetch(f file, path string) error {
+	resp, err := http.Get(f.url)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", f.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", f.url, resp.Status)
+	}
+
+	var data bytes.Buffer
+	if _, err := io.Copy(&data, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", f.url, err)
+	}
+	if sum := checksum(data.Bytes()); sum != f.sha256 {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", f.url, f.sha256, sum)
+	}
+	if err := os.WriteFile(path, data.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// checksum returns the hex encoded SHA-256 checksum of the data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
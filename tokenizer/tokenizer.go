@@ -0,0 +1,159 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"github.com/dlclark/regexp2"
+	"io"
+	"strconv"
+	"sync"
+)
+
+//go:generate go run fetch.go
+
+// vocab holds the rank files of the encodings, vocab/<name>.tiktoken. They are committed, so that the
+// build fails rather than counting tokens inexactly when one is missing; go generate downloads them again.
+//
+//go:embed vocab/cl100k_base.tiktoken vocab/o200k_base.tiktoken
+var vocab embed.FS
+
+// Encodings of the OpenAI models.
+const (
+	// CL100KBase is the encoding of GPT-4 and GPT-3.5.
+	CL100KBase = "cl100k_base"
+	// O200KBase is the encoding of GPT-4o, GPT-4.1 and the o-series.
+	O200KBase = "o200k_base"
+)
+
+// patterns split text into the pieces that are encoded separately, as tiktoken does.
+var patterns = map[string]string{
+	CL100KBase: `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+	O200KBase: `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`,
+}
+
+// Encoding splits text into the tokens of a byte pair encoding vocabulary.
+type Encoding struct {
+	Name    string
+	pattern *regexp2.Regexp
+	// ranks maps the bytes of every token to its rank, which is also its ID.
+	ranks map[string]int
+}
+
+var (
+	mu        sync.Mutex
+	encodings = make(map[string]*Encoding)
+)
+
+// Get returns the encoding with the given name, loading its vocabulary on first use.
+func Get(name string) (*Encoding, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if e, ok := encodings[name]; ok {
+		return e, nil
+	}
+
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	e := &Encoding{Name: name, pattern: regexp2.MustCompile(pattern, regexp2.None)}
+	file, err := vocab.Open("vocab/" + name + ".tiktoken")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the vocabulary of %s: %w", name, err)
+	}
+	defer file.Close()
+	if e.ranks, err = loadRanks(file); err != nil {
+		return nil, fmt.Errorf("failed to load the vocabulary of %s: %w", name, err)
+	}
+	encodings[name] = e
+	return e, nil
+}
+
+// loadRanks parses a tiktoken rank file, holding a base64 encoded token and its rank on each line.
+func loadRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		token, rank, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing rank", number)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		value, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		ranks[string(decoded)] = value
+	}
+	return ranks, scanner.Err()
+}
+
+// Encode returns the token IDs of the text. Special tokens such as <|endoftext|> are encoded as
+// ordinary text.
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		if rank, ok := e.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, bytePairEncode([]byte(piece), e.ranks)...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens of the text.
+func (e *Encoding) Count(text string) int {
+	return len(e.Encode(text))
+}
+
+// split splits the text into the pieces matched by the pattern of the encoding.
+func (e *Encoding) split(text string) []string {
+	var pieces []string
+	match, _ := e.pattern.FindStringMatch(text)
+	for match != nil {
+		pieces = append(pieces, match.String())
+		match, _ = e.pattern.FindNextMatch(match)
+	}
+	return pieces
+}
+
+// bytePairEncode encodes a piece by repeatedly merging the adjacent pair of parts with the lowest rank,
+// starting from single bytes, until no pair is a token.
+func bytePairEncode(piece []byte, ranks map[string]int) []int {
+	// bounds holds the start of every part and the end of the piece
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+
+	tokens := make([]int, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		tokens = append(tokens, ranks[string(piece[bounds[i]:bounds[i+1]])])
+	}
+	return tokens
+}
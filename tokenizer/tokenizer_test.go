@@ -0,0 +1,78 @@
+package tokenizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSplit tests that text is split into pieces like tiktoken does.
+func TestSplit(t *testing.T) {
+	e, err := Get(CL100KBase)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pieces := e.split("Hello world's  123456\n\tx")
+	expected := []string{"Hello", " world", "'s", " ", " ", "123", "456", "\n", "\tx"}
+	if !reflect.DeepEqual(pieces, expected) {
+		t.Errorf("Expected pieces %q, got %q", expected, pieces)
+	}
+
+	e, err = Get(O200KBase)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pieces = e.split("parseHTTPRequest(path/to)")
+	expected = []string{"parse", "HTTPRequest", "(path", "/to", ")"}
+	if !reflect.DeepEqual(pieces, expected) {
+		t.Errorf("Expected pieces %q, got %q", expected, pieces)
+	}
+
+	if _, err := Get("p50k"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
+
+// TestEncode tests the byte pair merges with a small vocabulary.
+func TestEncode(t *testing.T) {
+	ranks, err := loadRanks(strings.NewReader("YQ== 0\nYg== 1\nYw== 2\nYWI= 3\nYmM= 4\nYWJj 5\nIA== 6\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	e := &Encoding{Name: "test", pattern: mustGet(t, CL100KBase).pattern, ranks: ranks}
+
+	tokens := e.Encode("abcab ab")
+	// "abcab" merges ab, ab and abc; " ab" is a separate piece
+	if expected := []int{5, 3, 6, 3}; !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected tokens %v, got %v", expected, tokens)
+	}
+	if count := e.Count("abcab ab"); count != 4 {
+		t.Errorf("Expected 4 tokens, got %d", count)
+	}
+
+	if _, err := loadRanks(strings.NewReader("YQ==\n")); err == nil {
+		t.Error("Expected an error for a line without rank")
+	}
+}
+
+// TestEmbeddedVocabulary tests the embedded rank files against token IDs of tiktoken.
+func TestEmbeddedVocabulary(t *testing.T) {
+	for name, expected := range map[string][]int{
+		CL100KBase: {15339, 1917},
+		O200KBase:  {24912, 2375},
+	} {
+		if tokens := mustGet(t, name).Encode("hello world"); !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("%s: expected tokens %v, got %v", name, expected, tokens)
+		}
+	}
+}
+
+// mustGet returns the named encoding or fails the test.
+func mustGet(t *testing.T, name string) *Encoding {
+	t.Helper()
+	e, err := Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
@@ -1,20 +1,248 @@
 package types
 
-// Payload represents the data sent to OpenAI.
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Payload represents the data sent to OpenAI's legacy completions endpoint.
 type Payload struct {
 	Prompt    string `json:"prompt"`
 	MaxTokens int    `json:"max_tokens"`
 }
 
-// Response represents the structure of data received from OpenAI.
+// Response represents the structure of data received from OpenAI's legacy completions endpoint.
 type Response struct {
 	Choices []struct {
 		Text string `json:"text"`
 	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+// Chat message roles understood by the Chat Completions API.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ChatMessage represents a single message of a chat conversation.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest represents the data sent to OpenAI's Chat Completions API.
+type ChatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []ChatMessage   `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat selects structured output in the Chat Completions API.
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema describes the JSON document a model has to answer with.
+type JSONSchema struct {
+	Name   string          `json:"name"`
+	Strict bool            `json:"strict"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// ChatResponse represents the structure of data received from OpenAI's Chat Completions API.
+type ChatResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+// Usage represents the number of tokens consumed by a request.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add returns the sum of both usages.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// CompletionRequest represents a provider-neutral chat completion request.
+// An empty Model selects the default model of the backend. A non-nil JSONSchema asks the backend
+// for structured output following the schema.
+type CompletionRequest struct {
+	Model       string
+	Messages    []ChatMessage
+	Temperature float64
+	MaxTokens   int
+	// ContextWindow is the context window in tokens the prompt was planned for, used by backends that
+	// size the context of every request, such as Ollama; 0 keeps the backend's default.
+	ContextWindow int
+	JSONSchema    *JSONSchema
 }
 
-// ModifiedLine represents a line in the diff with its line number and content.
+// CompletionResponse represents a provider-neutral chat completion result.
+type CompletionResponse struct {
+	Content string
+	Model   string
+	Usage   Usage
+}
+
+// ModifiedLine represents a line in the diff with its line number and content. LineNumber is the first
+// added line in the modified file and OldLineNumber the first deleted line in the original file; each
+// is 0 when the block has no such lines.
 type ModifiedLine struct {
-	LineNumber int
-	Content    string
+	LineNumber    int
+	OldLineNumber int
+	Content       string
+}
+
+// FileDiff is the unified diff of a single changed file, independent of the code host or local
+// repository it comes from.
+type FileDiff struct {
+	Path string
+	// Patch holds the hunks of the file, starting at the first "@@" header; it is empty for binary files.
+	Patch     string
+	Additions int
+	Deletions int
+}
+
+// Severity describes how serious a review finding is.
+type Severity string
+
+// Supported severities, from least to most serious.
+const (
+	SeverityInfo     Severity = "info"
+	SeverityMinor    Severity = "minor"
+	SeverityMajor    Severity = "major"
+	SeverityCritical Severity = "critical"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityInfo:     1,
+	SeverityMinor:    2,
+	SeverityMajor:    3,
+	SeverityCritical: 4,
+}
+
+// ParseSeverity converts a case-insensitive severity name to a Severity.
+func ParseSeverity(value string) (Severity, bool) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(value)))
+	_, ok := severityRanks[severity]
+	return severity, ok
+}
+
+// Rank returns the ordering of the severity, zero for unknown severities.
+func (s Severity) Rank() int {
+	return severityRanks[s]
+}
+
+// AtLeast reports whether the severity is at or above the given threshold.
+func (s Severity) AtLeast(threshold Severity) bool {
+	return s.Rank() >= threshold.Rank()
+}
+
+// Pull request review events.
+const (
+	ReviewEventComment        = "COMMENT"
+	ReviewEventRequestChanges = "REQUEST_CHANGES"
+	ReviewEventApprove        = "APPROVE"
+)
+
+// Sides of a diff a review comment is anchored to.
+const (
+	// SideRight is the new version of the file, holding the added and unchanged lines.
+	SideRight = "RIGHT"
+	// SideLeft is the old version of the file, holding the deleted lines.
+	SideLeft = "LEFT"
+)
+
+// ReviewComment represents an inline comment of a pull request review. A comment spans the lines
+// StartLine to Line on its Side of the diff; a zero StartLine comments Line alone and an empty Side
+// is SideRight.
+type ReviewComment struct {
+	Path      string
+	StartLine int
+	Line      int
+	Side      string
+	Body      string
+}
+
+// Lines describes the lines of the comment for people, e.g. "lines 3-5" or "deleted line 7".
+func (c ReviewComment) Lines() string {
+	lines := fmt.Sprintf("line %d", c.Line)
+	if c.StartLine > 0 && c.StartLine < c.Line {
+		lines = fmt.Sprintf("lines %d-%d", c.StartLine, c.Line)
+	}
+	if c.Side == SideLeft {
+		return "deleted " + lines
+	}
+	return lines
+}
+
+// Review represents a pull request review submitted in a single request.
+// An empty CommitID reviews the current head of the pull request.
+type Review struct {
+	CommitID string
+	Body     string
+	Event    string
+	Comments []ReviewComment
+}
+
+// Errors reported by API clients once retries are exhausted. Test for them with errors.Is.
+var (
+	ErrRateLimited   = errors.New("rate limited")
+	ErrAuth          = errors.New("authentication failed")
+	ErrContextLength = errors.New("context length exceeded")
+)
+
+// APIError carries the status code and message of a failed API request.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// Err is one of the sentinel errors above when the failure could be classified.
+	Err error
+}
+
+// NewAPIError creates an APIError and classifies it by status code, error code and message.
+func NewAPIError(statusCode int, code, message string) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Code: code, Message: message}
+	lower := strings.ToLower(code + " " + message)
+	switch {
+	case statusCode == 429 || strings.Contains(lower, "rate limit"):
+		apiErr.Err = ErrRateLimited
+	case statusCode == 401 || statusCode == 403:
+		apiErr.Err = ErrAuth
+	case strings.Contains(lower, "context_length_exceeded") || strings.Contains(lower, "context length") ||
+		strings.Contains(lower, "prompt is too long"):
+		apiErr.Err = ErrContextLength
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v (status %d): %s", e.Err, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
 }
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"fmt"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 func GetEnv(key, defaultValue string) string {
@@ -24,3 +28,95 @@ func GetEnvAsInt(key string, fallback int64) int64 {
 
 	return fallback
 }
+
+func GetEnvAsFloat(key string, fallback float64) float64 {
+	if value, ok := os.LookupEnv(key); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fallback
+		}
+
+		return f
+	}
+
+	return fallback
+}
+
+// GetEnvAsList splits a comma separated environment variable, dropping empty entries.
+func GetEnvAsList(key string, fallback []string) []string {
+	if value, ok := os.LookupEnv(key); ok {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+
+	return fallback
+}
+
+// MatchGlob reports whether the slash separated path matches the glob pattern. Besides "*", "?" and
+// character classes, "**" matches any number of directories. A pattern without a slash is matched
+// against the base name of the path, so "*.go" matches Go files in every directory.
+func MatchGlob(pattern, name string) (bool, error) {
+	expr, err := globRegexp(pattern)
+	if err != nil {
+		return false, err
+	}
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	return expr.MatchString(name), nil
+}
+
+// globRegexp translates a glob pattern into an anchored regular expression.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if !strings.HasPrefix(pattern[i:], "**") {
+				expr.WriteString("[^/]*")
+				continue
+			}
+			i++
+			if strings.HasPrefix(pattern[i+1:], "/") {
+				i++
+				expr.WriteString("(?:.*/)?")
+			} else {
+				expr.WriteString(".*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end <= 0 {
+				return nil, fmt.Errorf("invalid glob pattern %q: unterminated character class", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 == len(pattern) {
+				return nil, fmt.Errorf("invalid glob pattern %q: trailing backslash", pattern)
+			}
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	compiled, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+	}
+	return compiled, nil
+}
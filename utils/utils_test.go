@@ -17,3 +17,45 @@ func TestGetEnv(t *testing.T) {
 	os.Unsetenv(envKey)
 	assert.Equal(t, defaultValue, GetEnv(envKey, defaultValue))
 }
+
+func TestGetEnvAsList(t *testing.T) {
+	const envKey = "TEST_ENV_LIST"
+
+	os.Setenv(envKey, "a, b,,c ")
+	assert.Equal(t, []string{"a", "b", "c"}, GetEnvAsList(envKey, nil))
+
+	os.Unsetenv(envKey)
+	assert.Equal(t, []string{"x"}, GetEnvAsList(envKey, []string{"x"}))
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "review/review.go", true},
+		{"*_test.go", "review/review_test.go", true},
+		{"review/*.go", "review/review.go", true},
+		{"review/*.go", "review/sub/review.go", false},
+		{"vendor/**", "vendor/github.com/x/y.go", true},
+		{"**/testdata/**", "review/testdata/a.json", true},
+		{"**/testdata/**", "testdata/a.json", true},
+		{"docs/**/*.md", "docs/README.md", true},
+		{"docs/**/*.md", "docs/a/b/c.md", true},
+		{"cmd/?/main.go", "cmd/a/main.go", true},
+		{"[!a]*.go", "b.go", true},
+		{"[!a]*.go", "a.go", false},
+		{"*.go", "main.go.orig", false},
+	}
+
+	for _, test := range tests {
+		match, err := MatchGlob(test.pattern, test.name)
+		assert.NoError(t, err)
+		assert.Equal(t, test.match, match, "%s ~ %s", test.pattern, test.name)
+	}
+
+	_, err := MatchGlob("[a-", "a")
+	assert.Error(t, err)
+}